@@ -0,0 +1,80 @@
+package dnssectest
+
+import (
+	"net"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Server is an in-process authoritative DNS server, serving one or more zones over UDP and TCP on an ephemeral
+// port on localhost. Each query is answered from whichever zone holds records for the name and type asked;
+// anything else gets an NXDOMAIN response.
+type Server struct {
+	Address string // IP address the server is listening on
+	Port    string // Port the server is listening on, for both UDP and TCP
+
+	zones []*Zone
+	udp   *dns.Server
+	tcp   *dns.Server
+}
+
+// NewServer starts a Server for the given zones. Call Close once finished with it.
+func NewServer(zones ...*Zone) (*Server, error) {
+	s := &Server{Address: "127.0.0.1", zones: zones}
+
+	packetConn, err := net.ListenPacket("udp", net.JoinHostPort(s.Address, "0"))
+	if err != nil {
+		return nil, err
+	}
+	_, s.Port, _ = net.SplitHostPort(packetConn.LocalAddr().String())
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.Address, s.Port))
+	if err != nil {
+		packetConn.Close()
+		return nil, err
+	}
+
+	var started sync.WaitGroup
+	started.Add(2)
+
+	s.udp = &dns.Server{PacketConn: packetConn, Handler: s, NotifyStartedFunc: started.Done}
+	s.tcp = &dns.Server{Listener: listener, Handler: s, NotifyStartedFunc: started.Done}
+
+	go s.udp.ActivateAndServe()
+	go s.tcp.ActivateAndServe()
+	started.Wait()
+
+	return s, nil
+}
+
+// Close shuts the server down.
+func (s *Server) Close() error {
+	errUdp := s.udp.Shutdown()
+	errTcp := s.tcp.Shutdown()
+	if errUdp != nil {
+		return errUdp
+	}
+	return errTcp
+}
+
+// ServeDNS answers a query authoritatively from the Server's zones.
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	msg.Authoritative = true
+	msg.Rcode = dns.RcodeNameError
+
+	if len(r.Question) == 1 {
+		question := r.Question[0]
+		for _, zone := range s.zones {
+			if records := zone.Get(question.Name, question.Qtype); len(records) > 0 {
+				msg.Answer = records
+				msg.Rcode = dns.RcodeSuccess
+				break
+			}
+		}
+	}
+
+	w.WriteMsg(msg)
+}
@@ -0,0 +1,37 @@
+package dnssectest
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	root, err := NewZone(".", nil, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+	com, err := NewZone("com", root, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+
+	server, err := NewServer(root, com)
+	require.NoError(t, err)
+	defer server.Close()
+
+	for _, network := range []string{"udp", "tcp"} {
+		client := &dns.Client{Net: network}
+
+		msg := new(dns.Msg)
+		msg.SetQuestion("com.", dns.TypeDS)
+		response, _, err := client.Exchange(msg, net.JoinHostPort(server.Address, server.Port))
+		require.NoError(t, err, network)
+		assert.True(t, response.Authoritative)
+		assert.Len(t, response.Answer, 2, network)
+
+		msg.SetQuestion("missing.com.", dns.TypeA)
+		response, _, err = client.Exchange(msg, net.JoinHostPort(server.Address, server.Port))
+		require.NoError(t, err, network)
+		assert.Equal(t, dns.RcodeNameError, response.Rcode, network)
+	}
+}
@@ -0,0 +1,279 @@
+// Package dnssectest generates DNSSEC signed zones, and serves them, for use in tests.
+//
+// Zones are signed on the fly with freshly generated keys, and chained together with DS records, so a complete
+// chain of trust from a test root zone can be built. Because every record and signature is accessible, chains
+// can also be deliberately broken: expired signatures, mismatched keys, missing RRSIGs, and so on.
+package dnssectest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"github.com/miekg/dns"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSSEC key flags
+const (
+	FlagZSK uint16 = 256 // Zone Signing Key
+	FlagKSK uint16 = 257 // Key Signing Key
+)
+
+// Zone is a DNSSEC signed zone with its own Key Signing Key (KSK) and Zone Signing Key (ZSK).
+type Zone struct {
+	Name   string // Fully qualified name of the zone
+	Parent *Zone  // The zone's parent; nil for a root zone
+
+	KSK       *dns.DNSKEY
+	KSKSigner crypto.Signer
+	ZSK       *dns.DNSKEY
+	ZSKSigner crypto.Signer
+
+	Inception  time.Time // Start of the validity period of signatures made by the zone
+	Expiration time.Time // End of the validity period of signatures made by the zone
+
+	mu     sync.RWMutex        // Guards rrsets, so records can be changed whilst the zone is being served
+	rrsets map[string][]dns.RR // Records served by the zone, including their RRSIGs, keyed by name and type
+}
+
+// NewZone generates the keys for a new zone, and publishes its signed DNSKEY RRset. If a parent is given, a signed
+// DS record for the new zone's KSK is published in the parent. Signatures are valid for an hour either side of now.
+func NewZone(name string, parent *Zone, kskAlgorithm, zskAlgorithm uint8) (*Zone, error) {
+	z := &Zone{
+		Name:       dns.CanonicalName(name),
+		Parent:     parent,
+		Inception:  time.Now().Add(-time.Hour),
+		Expiration: time.Now().Add(time.Hour),
+		rrsets:     make(map[string][]dns.RR),
+	}
+
+	if parent != nil && !dns.IsSubDomain(parent.Name, z.Name) {
+		return nil, fmt.Errorf("%s is not a subdomain of %s", z.Name, parent.Name)
+	}
+
+	var err error
+	if z.KSK, z.KSKSigner, err = GenerateKey(z.Name, FlagKSK, kskAlgorithm, 0); err != nil {
+		return nil, err
+	}
+	if z.ZSK, z.ZSKSigner, err = GenerateKey(z.Name, FlagZSK, zskAlgorithm, 0); err != nil {
+		return nil, err
+	}
+
+	if err = z.publishDNSKEY(); err != nil {
+		return nil, err
+	}
+
+	if parent != nil {
+		if err = parent.Add(z.DS(dns.SHA256)); err != nil {
+			return nil, err
+		}
+	}
+
+	return z, nil
+}
+
+// DS returns a DS record for the zone's KSK, using the given digest type.
+func (z *Zone) DS(digestType uint8) *dns.DS {
+	return z.KSK.ToDS(digestType)
+}
+
+// TrustAnchors returns the DS records to use as trust anchors for the zone, typically the root.
+func (z *Zone) TrustAnchors() []*dns.DS {
+	return []*dns.DS{z.DS(dns.SHA256)}
+}
+
+// Add adds records to the zone, signing each RRset they're added to with the ZSK.
+func (z *Zone) Add(rrs ...dns.RR) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	touched := make(map[string]bool)
+	for _, rr := range rrs {
+		if !dns.IsSubDomain(z.Name, rr.Header().Name) {
+			return fmt.Errorf("%s is not within the zone %s", rr.Header().Name, z.Name)
+		}
+		k := key(rr.Header().Name, rr.Header().Rrtype)
+		z.rrsets[k] = append(unsigned(z.rrsets[k]), rr)
+		touched[k] = true
+	}
+
+	for k := range touched {
+		if err := z.sign(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddString parses records in zone file format, then adds them to the zone.
+func (z *Zone) AddString(records ...string) error {
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return err
+		}
+		if err = z.Add(rr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set replaces the records served for a name and type exactly as given, without signing them. It's intended for
+// breaking a zone in a controlled way, e.g. serving records with a signature from the wrong key, or with none.
+// Setting no records removes the RRset.
+func (z *Zone) Set(name string, rrtype uint16, rrs ...dns.RR) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	k := key(name, rrtype)
+	if len(rrs) == 0 {
+		delete(z.rrsets, k)
+		return
+	}
+	z.rrsets[k] = rrs
+}
+
+// Get returns the records served for a name and type, including their RRSIGs.
+func (z *Zone) Get(name string, rrtype uint16) []dns.RR {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	return z.rrsets[key(name, rrtype)]
+}
+
+// Resign signs every RRset in the zone again, using the zone's current keys and validity period. Setting Inception
+// or Expiration, then calling Resign, produces a zone with signatures that are not yet, or no longer, valid.
+func (z *Zone) Resign() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if err := z.publishDNSKEY(); err != nil {
+		return err
+	}
+	for k := range z.rrsets {
+		if err := z.sign(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sign returns an RRSIG over the records, made by the given key, valid between inception and expiration.
+func Sign(records []dns.RR, key *dns.DNSKEY, signer crypto.Signer, inception, expiration time.Time) (*dns.RRSIG, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records to sign")
+	}
+
+	rrsig := &dns.RRSIG{
+		Hdr: dns.RR_Header{
+			Name:   records[0].Header().Name,
+			Rrtype: dns.TypeRRSIG,
+			Class:  dns.ClassINET,
+			Ttl:    records[0].Header().Ttl,
+		},
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+		KeyTag:     key.KeyTag(),
+		SignerName: key.Header().Name,
+		Algorithm:  key.Algorithm,
+	}
+
+	if err := rrsig.Sign(signer, records); err != nil {
+		return nil, err
+	}
+	return rrsig, nil
+}
+
+// GenerateKey generates a DNSKEY, and its private key, for the named zone. When bits is 0, a size suitable for
+// the algorithm is used.
+func GenerateKey(name string, flags uint16, algorithm uint8, bits int) (*dns.DNSKEY, crypto.Signer, error) {
+	key := &dns.DNSKEY{
+		Hdr: dns.RR_Header{
+			Name:   dns.CanonicalName(name),
+			Rrtype: dns.TypeDNSKEY,
+			Class:  dns.ClassINET,
+			Ttl:    3600,
+		},
+		Flags:     flags,
+		Algorithm: algorithm,
+		Protocol:  3,
+	}
+
+	if bits == 0 {
+		switch algorithm {
+		case dns.ECDSAP384SHA384:
+			bits = 384
+		case dns.ECDSAP256SHA256, dns.ED25519:
+			bits = 256
+		default:
+			bits = 2048
+		}
+	}
+
+	secret, err := key.Generate(bits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch signer := secret.(type) {
+	case *rsa.PrivateKey:
+		return key, signer, nil
+	case *ecdsa.PrivateKey:
+		return key, signer, nil
+	case ed25519.PrivateKey:
+		return key, signer, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported key type generated for algorithm %d", algorithm)
+}
+
+// publishDNSKEY replaces the zone's DNSKEY RRset with its current keys, signed by the KSK.
+func (z *Zone) publishDNSKEY() error {
+	keys := []dns.RR{z.ZSK, z.KSK}
+	rrsig, err := Sign(keys, z.KSK, z.KSKSigner, z.Inception, z.Expiration)
+	if err != nil {
+		return err
+	}
+	z.rrsets[key(z.Name, dns.TypeDNSKEY)] = append(keys, rrsig)
+	return nil
+}
+
+// sign replaces any RRSIG on the RRset stored under k with a new one made by the ZSK.
+// The DNSKEY RRset is signed by the KSK instead.
+func (z *Zone) sign(k string) error {
+	records := unsigned(z.rrsets[k])
+	if len(records) == 0 {
+		return nil
+	}
+	if records[0].Header().Rrtype == dns.TypeDNSKEY && strings.EqualFold(records[0].Header().Name, z.Name) {
+		return z.publishDNSKEY()
+	}
+
+	rrsig, err := Sign(records, z.ZSK, z.ZSKSigner, z.Inception, z.Expiration)
+	if err != nil {
+		return err
+	}
+	z.rrsets[k] = append(records, rrsig)
+	return nil
+}
+
+// unsigned returns the records without any RRSIGs.
+func unsigned(rrs []dns.RR) []dns.RR {
+	results := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if _, ok := rr.(*dns.RRSIG); !ok {
+			results = append(results, rr)
+		}
+	}
+	return results
+}
+
+// key returns the key the records for a name and type are stored under.
+func key(name string, rrtype uint16) string {
+	return fmt.Sprintf("%s %d", dns.CanonicalName(name), rrtype)
+}
@@ -0,0 +1,87 @@
+package dnssectest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verify checks an RRset served by a zone has a valid RRSIG, made by the given key.
+func verify(t *testing.T, rrs []dns.RR, key *dns.DNSKEY) error {
+	require.NotEmpty(t, rrs)
+	var rrsig *dns.RRSIG
+	records := make([]dns.RR, 0)
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			rrsig = sig
+		} else {
+			records = append(records, rr)
+		}
+	}
+	require.NotNil(t, rrsig)
+	if !rrsig.ValidityPeriod(time.Now()) {
+		return assert.AnError
+	}
+	return rrsig.Verify(key, records)
+}
+
+func TestNewZone(t *testing.T) {
+	root, err := NewZone(".", nil, dns.RSASHA256, dns.ED25519)
+	require.NoError(t, err)
+	com, err := NewZone("com", root, dns.ECDSAP384SHA384, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+
+	// Each DNSKEY RRset is signed by its zone's KSK.
+	assert.NoError(t, verify(t, root.Get(".", dns.TypeDNSKEY), root.KSK))
+	assert.NoError(t, verify(t, com.Get("com.", dns.TypeDNSKEY), com.KSK))
+
+	// The child's DS is published in, and signed by, the parent.
+	ds := root.Get("com.", dns.TypeDS)
+	assert.NoError(t, verify(t, ds, root.ZSK))
+	assert.Equal(t, com.DS(dns.SHA256).Digest, ds[0].(*dns.DS).Digest)
+
+	_, err = NewZone("example.net", com, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	assert.EqualError(t, err, "example.net. is not a subdomain of com.")
+}
+
+func TestZone_Add(t *testing.T) {
+	zone, err := NewZone("example.com", nil, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+
+	require.NoError(t, zone.AddString("test.example.com. 300 IN A 192.0.2.1"))
+	require.NoError(t, zone.AddString("test.example.com. 300 IN A 192.0.2.2"))
+
+	a := zone.Get("TEST.example.com", dns.TypeA)
+	assert.Len(t, a, 3) // Two A records, plus one RRSIG covering both.
+	assert.NoError(t, verify(t, a, zone.ZSK))
+
+	assert.ErrorContains(t, zone.AddString("test.example.net. 300 IN A 192.0.2.1"), "is not within the zone")
+}
+
+func TestZone_Resign(t *testing.T) {
+	zone, err := NewZone("example.com", nil, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+	require.NoError(t, zone.AddString("test.example.com. 300 IN A 192.0.2.1"))
+
+	zone.Inception = time.Now().Add(-2 * time.Hour)
+	zone.Expiration = time.Now().Add(-time.Hour)
+	require.NoError(t, zone.Resign())
+
+	assert.Error(t, verify(t, zone.Get("test.example.com.", dns.TypeA), zone.ZSK))
+	assert.Error(t, verify(t, zone.Get("example.com.", dns.TypeDNSKEY), zone.KSK))
+}
+
+func TestZone_Set(t *testing.T) {
+	zone, err := NewZone("example.com", nil, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+
+	a, _ := dns.NewRR("test.example.com. 300 IN A 192.0.2.1")
+	zone.Set("test.example.com.", dns.TypeA, a)
+	assert.Equal(t, []dns.RR{a}, zone.Get("test.example.com.", dns.TypeA))
+
+	zone.Set("test.example.com.", dns.TypeA)
+	assert.Empty(t, zone.Get("test.example.com.", dns.TypeA))
+}
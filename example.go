@@ -13,6 +13,7 @@ func main() {
 		//lookup.NewTcpNameserver("1.1.1.1", "53"),	// Unencrypted TCP example
 		lookup.NewTlsNameserver("1.1.1.1", "853", "one.one.one.one"),
 		//lookup.NewTlsNameserver("2606:4700:4700::1111", "853", "one.one.one.one"),
+		//lookup.NewHttpsNameserver("https://cloudflare-dns.com/dns-query{?dns}"),
 	})
 
 	//---
@@ -0,0 +1,98 @@
+package lookup
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-anchors-go/anchors"
+)
+
+// embeddedAnchorsGenerated is when the trust anchors embedded via dns-anchors-go were generated; the release date of
+// the version in go.mod. It should be updated whenever that dependency is.
+var embeddedAnchorsGenerated = time.Date(2024, time.July, 28, 17, 46, 47, 0, time.UTC)
+
+// TrustAnchorStatus reports whether the trust anchors in use might need updating.
+type TrustAnchorStatus struct {
+	Embedded          bool      // The trust anchors embedded in this module are in use
+	Generated         time.Time // When the embedded trust anchors were generated
+	Stale             bool      // The embedded trust anchors are older than TrustAnchorMaxAge
+	UnmatchedRootKeys []uint16  // Key tags of root Key Signing Keys, seen during validation, with no matching trust anchor
+}
+
+// rootKeyCheck records the root Key Signing Keys that don't match a trust anchor.
+type rootKeyCheck struct {
+	mu        sync.Mutex
+	unmatched []uint16
+}
+
+// TrustAnchorStatus checks the age of the embedded trust anchors, if they're in use, and reports any root Key
+// Signing Keys seen during validation that no trust anchor matches. Such a key usually means a root KSK roll is
+// underway, and validation will start to fail once the old key is withdrawn.
+func (d *DnsLookup) TrustAnchorStatus() TrustAnchorStatus {
+	status := TrustAnchorStatus{
+		Embedded: equalDS(d.RootDNSSECRecords, anchors.GetAllFromEmbedded()),
+	}
+	if status.Embedded {
+		status.Generated = embeddedAnchorsGenerated
+		status.Stale = d.TrustAnchorMaxAge > 0 && time.Since(embeddedAnchorsGenerated) > d.TrustAnchorMaxAge
+	}
+
+	d.rootKeys.mu.Lock()
+	status.UnmatchedRootKeys = slices.Clone(d.rootKeys.unmatched)
+	d.rootKeys.mu.Unlock()
+
+	return status
+}
+
+// warnIfTrustAnchorsStale logs a warning if the embedded trust anchors are older than TrustAnchorMaxAge.
+func (d *DnsLookup) warnIfTrustAnchorsStale() {
+	if status := d.TrustAnchorStatus(); status.Stale {
+		logger := d.componentLogger(LogComponentValidation)
+		logger.Warn().Time("generated", status.Generated).
+			Msg("The embedded trust anchors are stale. Update this module, or supply current anchors, before the next root KSK roll.")
+	}
+}
+
+// checkRootKeys compares the Key Signing Keys in an authenticated root DNSKEY set against the trust anchors, and
+// records, and logs, any that don't match.
+func (d *DnsLookup) checkRootKeys(keys []*dns.DNSKEY) {
+	unmatched := make([]uint16, 0)
+	for _, key := range keys {
+		if key.Flags&dns.SEP == 0 || key.Flags&dns.REVOKE != 0 {
+			continue
+		}
+		if !slices.ContainsFunc(d.rootDNSSECRecords(), func(ds *dns.DS) bool {
+			keyDS := key.ToDS(ds.DigestType)
+			return keyDS != nil && ds.KeyTag == keyDS.KeyTag && ds.Algorithm == keyDS.Algorithm && strings.EqualFold(ds.Digest, keyDS.Digest)
+		}) {
+			unmatched = append(unmatched, key.KeyTag())
+		}
+	}
+
+	d.rootKeys.mu.Lock()
+	changed := !slices.Equal(d.rootKeys.unmatched, unmatched)
+	d.rootKeys.unmatched = unmatched
+	d.rootKeys.mu.Unlock()
+
+	if changed && len(unmatched) > 0 {
+		logger := d.componentLogger(LogComponentValidation)
+		logger.Warn().Interface("key-tags", unmatched).
+			Msg("The root DNSKEY set contains Key Signing Keys with no matching trust anchor. A root KSK roll may be underway; update the trust anchors.")
+	}
+}
+
+// equalDS reports whether two sets of DS records contain the same records, in any order.
+func equalDS(a, b []*dns.DS) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		if !slices.ContainsFunc(b, func(y *dns.DS) bool { return dns.IsDuplicate(x, y) }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-anchors-go/anchors"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustAnchorStatus_Embedded(t *testing.T) {
+	d := NewDnsLookup(nil)
+
+	status := d.TrustAnchorStatus()
+	assert.True(t, status.Embedded)
+	assert.Equal(t, embeddedAnchorsGenerated, status.Generated)
+
+	d.TrustAnchorMaxAge = time.Since(embeddedAnchorsGenerated) + time.Hour
+	assert.False(t, d.TrustAnchorStatus().Stale)
+
+	d.TrustAnchorMaxAge = time.Hour
+	assert.True(t, d.TrustAnchorStatus().Stale)
+
+	// Anchors supplied by the user aren't reported as stale.
+	d.RootDNSSECRecords = anchors.GetValidFromEmbedded()[:1]
+	status = d.TrustAnchorStatus()
+	assert.False(t, status.Embedded)
+	assert.False(t, status.Stale)
+}
+
+func TestTrustAnchorStatus_UnmatchedRootKey(t *testing.T) {
+	zones := newTestChain(t)
+	root := zones[0]
+
+	// Publish a second root KSK, as happens at the start of a KSK roll, signed by the current one.
+	next, _, err := dnssectest.GenerateKey(".", dnssectest.FlagKSK, dns.ECDSAP256SHA256, 0)
+	require.NoError(t, err)
+	keys := []dns.RR{root.KSK, root.ZSK, next}
+	rrsig, err := dnssectest.Sign(keys, root.KSK, root.KSKSigner, root.Inception, root.Expiration)
+	require.NoError(t, err)
+	root.Set(".", dns.TypeDNSKEY, append(keys, rrsig)...)
+
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = root.TrustAnchors()
+
+	assert.Empty(t, d.TrustAnchorStatus().UnmatchedRootKeys)
+
+	_, err = d.QueryA("test.example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, []uint16{next.KeyTag()}, d.TrustAnchorStatus().UnmatchedRootKeys)
+}
@@ -0,0 +1,21 @@
+package lookup
+
+import (
+	"context"
+)
+
+// QueryAsync performs a DNS query in the background, as Query does. The channel returned receives the Result once
+// the query completes, then is closed.
+func (d *DnsLookup) QueryAsync(name string, rrtype uint16) <-chan Result {
+	return d.QueryAsyncContext(context.Background(), name, rrtype)
+}
+
+// QueryAsyncContext performs a DNS query in the background, stopping when ctx is done.
+func (d *DnsLookup) QueryAsyncContext(ctx context.Context, name string, rrtype uint16) <-chan Result {
+	results := make(chan Result, 1)
+	go func() {
+		defer close(results)
+		results <- d.QueryResultContext(ctx, name, rrtype)
+	}()
+	return results
+}
@@ -0,0 +1,53 @@
+package lookup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDnsLookup_QueryAsync(t *testing.T) {
+	ns := &delayedNameServer{name: "slow", delay: 20 * time.Millisecond, response: newLookupResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	// The queries run concurrently, so take about as long as one.
+	start := time.Now()
+	var pending []<-chan Result
+	for i := 0; i < 10; i++ {
+		pending = append(pending, d.QueryAsync("example.com.", dns.TypeA))
+	}
+	for _, results := range pending {
+		result := <-results
+		require.NoError(t, result.Err)
+		assert.Len(t, result.Msg.Answer, 1)
+
+		_, open := <-results
+		assert.False(t, open)
+	}
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestDnsLookup_QueryAsyncContext(t *testing.T) {
+	ns := &delayedNameServer{name: "slow", delay: 5 * time.Second, response: newLookupResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := d.QueryAsyncContext(ctx, "example.com.", dns.TypeA)
+	cancel()
+
+	select {
+	case result := <-results:
+		assert.ErrorIs(t, result.Err, context.Canceled)
+		assert.Nil(t, result.Msg)
+	case <-time.After(time.Second):
+		t.Fatal("query wasn't cancelled")
+	}
+}
@@ -0,0 +1,56 @@
+package lookup
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBatchConcurrency is how many of a batch's queries are made at once, when BatchConcurrency isn't set.
+const DefaultBatchConcurrency = 16
+
+// Question is a name and record type to query for, as part of a batch.
+type Question struct {
+	Name   string
+	Rrtype uint16
+}
+
+// QueryBatch queries for each question, making up to BatchConcurrency queries at once. The results are in the same
+// order as the questions, each with its own error.
+func (d *DnsLookup) QueryBatch(questions []Question) []Result {
+	return d.QueryBatchContext(context.Background(), questions)
+}
+
+// QueryBatchContext queries for each question, stopping when ctx is done. Questions not yet queried by then have
+// ctx's error as their result.
+func (d *DnsLookup) QueryBatchContext(ctx context.Context, questions []Question) []Result {
+	concurrency := d.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	results := make([]Result, len(questions))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, question := range questions {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(questions); j++ {
+				results[j] = Result{Name: questions[j].Name, Rrtype: questions[j].Rrtype, Err: ctx.Err(), Rcode: -1}
+			}
+			wg.Wait()
+			return results
+		}
+
+		wg.Add(1)
+		go func(i int, question Question) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = d.QueryResultContext(ctx, question.Name, question.Rrtype)
+		}(i, question)
+	}
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,110 @@
+package lookup
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingNameServer answers A queries for example.com, tracking the most queries it had in flight at once.
+type countingNameServer struct {
+	delay    time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (n *countingNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	current := n.inFlight.Add(1)
+	defer n.inFlight.Add(-1)
+	for {
+		peak := n.peak.Load()
+		if current <= peak || n.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+	time.Sleep(n.delay)
+
+	if name != "example.com." {
+		return newLookupResponseMsgWithAD(dns.RcodeNameError, true), n.delay, newRcodeError(name, rrtype, n.String(), dns.RcodeNameError)
+	}
+	return newLookupResponseMsgWithAD(dns.RcodeSuccess, true), n.delay, nil
+}
+
+func (n *countingNameServer) String() string {
+	return "counting"
+}
+
+func TestDnsLookup_QueryBatch(t *testing.T) {
+	ns := &countingNameServer{delay: 10 * time.Millisecond}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.BatchConcurrency = 3
+
+	questions := make([]Question, 10)
+	for i := range questions {
+		questions[i] = Question{Name: "example.com.", Rrtype: dns.TypeA}
+	}
+	questions[4].Name = "missing.example.com."
+
+	results := d.QueryBatch(questions)
+	require.Len(t, results, 10)
+	for i, result := range results {
+		if i == 4 {
+			assert.ErrorContains(t, result.Err, "missing.example.com. A query to counting returned NXDOMAIN")
+			continue
+		}
+		require.NoError(t, result.Err)
+		assert.Len(t, result.Msg.Answer, 1)
+	}
+	assert.Equal(t, int32(3), ns.peak.Load())
+}
+
+func TestDnsLookup_QueryBatchContext(t *testing.T) {
+	ns := &countingNameServer{delay: 50 * time.Millisecond}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.BatchConcurrency = 1
+
+	questions := []Question{{"example.com.", dns.TypeA}, {"example.com.", dns.TypeA}, {"example.com.", dns.TypeA}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results := d.QueryBatchContext(ctx, questions)
+
+	require.Len(t, results, 3)
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, context.DeadlineExceeded)
+		assert.Equal(t, -1, result.Rcode)
+		assert.Equal(t, "example.com.", result.Name)
+		assert.Equal(t, dns.TypeA, result.Rrtype)
+	}
+}
+
+func TestDnsLookup_QueryBatchCancelled(t *testing.T) {
+	ns := &countingNameServer{}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.BatchConcurrency = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Questions that are never sent have no response, and say which question they were for.
+	results := d.QueryBatchContext(ctx, []Question{{"example.com.", dns.TypeA}, {"example.net.", dns.TypeMX}})
+	require.Len(t, results, 2)
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, context.Canceled)
+		assert.Equal(t, -1, result.Rcode)
+		assert.Nil(t, result.Msg)
+	}
+	assert.Equal(t, "example.net.", results[1].Name)
+	assert.Equal(t, dns.TypeMX, results[1].Rrtype)
+}
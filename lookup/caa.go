@@ -0,0 +1,109 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// caaCritical is the flag marking a CAA property that must be understood for issuance to be permitted.
+const caaCritical = 128
+
+// CAAEvaluation is the outcome of checking whether a CA may issue a certificate for a domain, as RFC 8659 describes.
+type CAAEvaluation struct {
+	Permitted bool       // Whether the CA may issue the certificate
+	Domain    string     // Where the relevant CAA records were found; empty if there were none
+	Records   []*dns.CAA // The relevant CAA records
+	Iodef     []string   // Where the domain asks to be told of requests that violate its policy
+}
+
+// EvaluateCAA checks whether the CA identified by caDomain, e.g. "letsencrypt.org", may issue a certificate for a
+// domain, following RFC 8659. The relevant CAA records are found by climbing from the domain towards the root, and
+// using the first that has any. A domain starting "*." is checked as a wildcard, so issuewild properties take
+// precedence over issue properties.
+//
+// Issuance is permitted if no records are found. An error is returned if the records couldn't be fetched, in which
+// case a CA mustn't issue.
+func (d *DnsLookup) EvaluateCAA(domain, caDomain string) (*CAAEvaluation, error) {
+	return d.EvaluateCAAContext(context.Background(), domain, caDomain)
+}
+
+// EvaluateCAAContext checks whether a CA may issue a certificate for a domain, stopping when ctx is done.
+func (d *DnsLookup) EvaluateCAAContext(ctx context.Context, domain, caDomain string) (*CAAEvaluation, error) {
+	wildcard := strings.HasPrefix(domain, "*.")
+	domain = dns.Fqdn(strings.TrimPrefix(domain, "*."))
+
+	found, records, err := d.relevantCAA(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation := &CAAEvaluation{Domain: found, Records: records}
+	evaluation.Permitted = caaPermits(records, caDomain, wildcard)
+	for _, record := range records {
+		if strings.EqualFold(record.Tag, "iodef") {
+			evaluation.Iodef = append(evaluation.Iodef, record.Value)
+		}
+	}
+	return evaluation, nil
+}
+
+// relevantCAA finds the relevant CAA records for a domain: those of the domain, or failing that the closest ancestor
+// that has any, stopping before the root. A name that doesn't exist is treated as having none.
+func (d *DnsLookup) relevantCAA(ctx context.Context, domain string) (string, []*dns.CAA, error) {
+	labels := dns.SplitDomainName(domain)
+	for i := range labels {
+		name := dns.Fqdn(strings.Join(labels[i:], "."))
+
+		msg, _, err := d.QueryContext(ctx, name, dns.TypeCAA)
+		if errors.Is(err, ErrNXDomain) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if records := extractRecordsOfType[*dns.CAA](msg.Answer); len(records) > 0 {
+			return name, records, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// caaPermits applies the issue and issuewild properties of the relevant records to the CA. An unknown property marked
+// critical forbids issuance, as the CA can't know what it requires.
+func caaPermits(records []*dns.CAA, caDomain string, wildcard bool) bool {
+	var issue, issuewild []*dns.CAA
+	for _, record := range records {
+		switch strings.ToLower(record.Tag) {
+		case "issue":
+			issue = append(issue, record)
+		case "issuewild":
+			issuewild = append(issuewild, record)
+		case "iodef", "contactemail", "contactphone":
+		default:
+			if record.Flag&caaCritical != 0 {
+				return false
+			}
+		}
+	}
+
+	properties := issue
+	if wildcard && len(issuewild) > 0 {
+		properties = issuewild
+	}
+	if len(properties) == 0 {
+		return true
+	}
+
+	caDomain = strings.TrimSuffix(caDomain, ".")
+	for _, property := range properties {
+		issuer, _, _ := strings.Cut(property.Value, ";")
+		issuer = strings.TrimSuffix(strings.TrimSpace(issuer), ".")
+		if issuer != "" && strings.EqualFold(issuer, caDomain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCAALookup returns a DnsLookup whose nameserver answers CAA queries with the given records, keyed by name. Names
+// without records are answered with an empty answer.
+func newCAALookup(t *testing.T, records map[string][]string) *DnsLookup {
+	ns := &namedMockNameServer{name: "mock"}
+	for name, rrs := range records {
+		ns.On("Query", name, dns.TypeCAA).Return(newAnswerMsg(t, rrs...), time.Millisecond, nil)
+	}
+	return &DnsLookup{nameservers: []NameServer{ns}}
+}
+
+func TestEvaluateCAA(t *testing.T) {
+	d := newCAALookup(t, map[string][]string{
+		"www.example.com.": {},
+		"example.com.": {
+			`example.com. 300 IN CAA 0 issue "letsencrypt.org; validationmethods=dns-01"`,
+			`example.com. 300 IN CAA 0 issuewild ";"`,
+			`example.com. 300 IN CAA 0 iodef "mailto:security@example.com"`,
+		},
+	})
+
+	evaluation, err := d.EvaluateCAA("www.example.com", "letsencrypt.org")
+	require.NoError(t, err)
+	assert.True(t, evaluation.Permitted)
+	assert.Equal(t, "example.com.", evaluation.Domain)
+	assert.Len(t, evaluation.Records, 3)
+	assert.Equal(t, []string{"mailto:security@example.com"}, evaluation.Iodef)
+
+	evaluation, err = d.EvaluateCAA("www.example.com", "ca.example.net")
+	require.NoError(t, err)
+	assert.False(t, evaluation.Permitted)
+
+	// Wildcard issuance is forbidden outright.
+	evaluation, err = d.EvaluateCAA("*.www.example.com", "letsencrypt.org")
+	require.NoError(t, err)
+	assert.False(t, evaluation.Permitted)
+}
+
+func TestEvaluateCAA_NoRecords(t *testing.T) {
+	d := newCAALookup(t, map[string][]string{"example.org.": {}, "org.": {}})
+
+	evaluation, err := d.EvaluateCAA("example.org", "letsencrypt.org")
+	require.NoError(t, err)
+	assert.True(t, evaluation.Permitted)
+	assert.Empty(t, evaluation.Domain)
+}
+
+func TestCaaPermits(t *testing.T) {
+	records := func(rrs ...string) []*dns.CAA {
+		return extractRecordsOfType[*dns.CAA](newAnswerMsg(t, rrs...).Answer)
+	}
+
+	// Without issue properties, issuewild applies only to wildcards, and otherwise any CA may issue.
+	wildOnly := records(`example.com. 300 IN CAA 0 issuewild "ca.example.net"`)
+	assert.True(t, caaPermits(wildOnly, "letsencrypt.org", false))
+	assert.False(t, caaPermits(wildOnly, "letsencrypt.org", true))
+	assert.True(t, caaPermits(wildOnly, "CA.example.net.", true))
+
+	// Wildcards fall back to issue properties.
+	issueOnly := records(`example.com. 300 IN CAA 0 issue "letsencrypt.org"`)
+	assert.True(t, caaPermits(issueOnly, "letsencrypt.org", true))
+
+	// An unknown critical property forbids issuance; an unknown property that isn't critical is ignored.
+	assert.False(t, caaPermits(records(
+		`example.com. 300 IN CAA 0 issue "letsencrypt.org"`,
+		`example.com. 300 IN CAA 128 tbs "unknown"`,
+	), "letsencrypt.org", false))
+	assert.True(t, caaPermits(records(
+		`example.com. 300 IN CAA 0 issue "letsencrypt.org"`,
+		`example.com. 300 IN CAA 0 tbs "unknown"`,
+	), "letsencrypt.org", false))
+}
@@ -0,0 +1,325 @@
+package lookup
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultCacheSize is a reasonable number of responses for a Cache to hold.
+const DefaultCacheSize = 10000
+
+// DefaultMaxNegativeTTL is the longest a negative response is cached for by default. RFC 2308 (section 5) suggests
+// one to three hours.
+const DefaultMaxNegativeTTL = 3 * time.Hour
+
+// Cache holds validated responses until their TTLs expire, so repeated queries don't need to go to the nameservers.
+// When full, the least recently used response is evicted. It's safe for concurrent use.
+//
+// The DS and DNSKEY responses fetched whilst authenticating an answer are cached too, once it's been authenticated, so
+// later answers from the same zones don't fetch the chain of trust again. Their signatures are still verified each time
+// they're used.
+//
+// Negative responses, NXDOMAIN and NODATA, are cached as described in RFC 2308: for the lower of the TTL of the SOA
+// record in the authority section and its MINIMUM field. Negative responses without an SOA record aren't cached.
+// NXDOMAIN responses can't be authenticated locally, so a DnsLookup only caches them when LocallyAuthenticateData
+// isn't set, and, with RemotelyAuthenticateData, when the nameserver set the AD flag.
+type Cache struct {
+	MaxNegativeTTL time.Duration // The longest a negative response is cached for, regardless of its SOA record
+
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[cacheKey]*list.Element
+	lru        *list.List // Most recently used at the front
+	now        func() time.Time
+	stats      CacheStats
+}
+
+// CacheStats counts the lookups made in a Cache.
+type CacheStats struct {
+	Hits   uint64 // Lookups that found an unexpired response
+	Misses uint64 // Lookups that didn't
+}
+
+// cacheKey identifies a cached response by its question.
+type cacheKey struct {
+	name   string
+	rrtype uint16
+	class  uint16
+}
+
+type cacheEntry struct {
+	key        cacheKey
+	msg        *dns.Msg
+	stored     time.Time
+	expires    time.Time
+	validation ValidationStatus
+}
+
+// CacheEntry describes a cached response, as returned by Cache.Entries.
+type CacheEntry struct {
+	Name       string           // The name queried
+	Rrtype     uint16           // The record type queried
+	Rcode      int              // The response's rcode; NXDOMAIN responses are cached too
+	TTL        time.Duration    // How much longer the response will be cached for
+	Validation ValidationStatus // How the response was validated when it was cached
+	Answer     []dns.RR         // The response's answer, with its TTLs reduced by the time it's been cached
+}
+
+// NewCache creates a Cache holding up to maxEntries responses.
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		MaxNegativeTTL: DefaultMaxNegativeTTL,
+		maxEntries:     maxEntries,
+		entries:        make(map[cacheKey]*list.Element),
+		lru:            list.New(),
+		now:            time.Now,
+	}
+}
+
+func newCacheKey(name string, rrtype uint16) cacheKey {
+	return cacheKey{name: strings.ToLower(dns.Fqdn(name)), rrtype: rrtype, class: dns.ClassINET}
+}
+
+// Get returns a copy of the cached response to a question, with its TTLs reduced by the time it has been cached.
+func (c *Cache) Get(name string, rrtype uint16) (*dns.Msg, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[newCacheKey(name, rrtype)]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	now := c.now()
+	if !now.Before(entry.expires) {
+		c.remove(element)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.stats.Hits++
+
+	return entry.response(now), true
+}
+
+// response returns a copy of the entry's response, with its TTLs reduced by the time it has been cached.
+func (e *cacheEntry) response(now time.Time) *dns.Msg {
+	msg := e.msg.Copy()
+	elapsed := uint32(now.Sub(e.stored) / time.Second)
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			if rr.Header().Rrtype != dns.TypeOPT {
+				rr.Header().Ttl -= min(elapsed, rr.Header().Ttl)
+			}
+		}
+	}
+	return msg
+}
+
+// Set caches a response to a question for the lowest TTL of its records, or, for a negative response, its negative
+// caching TTL. Responses with a TTL of zero, and errors other than NXDOMAIN, aren't cached.
+func (c *Cache) Set(name string, rrtype uint16, msg *dns.Msg) {
+	c.setValidated(name, rrtype, msg, ValidationIndeterminate)
+}
+
+// setValidated caches a response, as Set does, recording how it was validated.
+func (c *Cache) setValidated(name string, rrtype uint16, msg *dns.Msg, validation ValidationStatus) {
+	if msg == nil || c.maxEntries < 1 {
+		return
+	}
+
+	ttl := c.ttl(msg)
+	if ttl == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := newCacheKey(name, rrtype)
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	now := c.now()
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:        key,
+		msg:        msg.Copy(),
+		stored:     now,
+		expires:    now.Add(time.Duration(ttl) * time.Second),
+		validation: validation,
+	})
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Len returns the number of responses cached, including any that have expired but not yet been removed.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the number of hits and misses since the cache was created.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Entries returns the responses cached, and not yet expired, ordered by name and type. Looking at them doesn't count
+// as a hit, or make them more recently used.
+func (c *Cache) Entries() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	entries := make([]CacheEntry, 0, c.lru.Len())
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*cacheEntry)
+		if !now.Before(entry.expires) {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Name:       entry.key.name,
+			Rrtype:     entry.key.rrtype,
+			Rcode:      entry.msg.Rcode,
+			TTL:        entry.expires.Sub(now).Truncate(time.Second),
+			Validation: entry.validation,
+			Answer:     entry.response(now).Answer,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Rrtype < entries[j].Rrtype
+	})
+	return entries
+}
+
+// Dump writes the responses cached, as returned by Entries, to w in a readable form: a line for each response giving
+// its name, type, rcode, remaining TTL and validation status, followed by its answer's records, indented.
+func (c *Cache) Dump(w io.Writer) error {
+	for _, entry := range c.Entries() {
+		rcode, ok := dns.RcodeToString[entry.Rcode]
+		if !ok {
+			rcode = fmt.Sprintf("RCODE%d", entry.Rcode)
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %s ttl=%s validation=%s\n", entry.Name, rrtypeToString(entry.Rrtype), rcode, entry.TTL, entry.Validation); err != nil {
+			return err
+		}
+		for _, rr := range entry.Answer {
+			if _, err := fmt.Fprintf(w, "  %s\n", tabsToSpaces(rr.String())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush removes the cached responses, of every type, to queries for name, returning how many were removed.
+func (c *Cache) Flush(name string) int {
+	name = newCacheKey(name, 0).name
+	return c.removeMatching(func(key cacheKey) bool {
+		return key.name == name
+	})
+}
+
+// FlushType removes the cached response to a query for name and rrtype, returning whether there was one.
+func (c *Cache) FlushType(name string, rrtype uint16) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[newCacheKey(name, rrtype)]
+	if ok {
+		c.remove(element)
+	}
+	return ok
+}
+
+// FlushZone removes the cached responses to queries for zone, and for every name below it, returning how many were
+// removed. Flushing the root zone, ".", removes every response, as Clear does.
+func (c *Cache) FlushZone(zone string) int {
+	zone = newCacheKey(zone, 0).name
+	return c.removeMatching(func(key cacheKey) bool {
+		return dns.IsSubDomain(zone, key.name)
+	})
+}
+
+// removeMatching removes the cached responses whose keys match, returning how many were removed.
+func (c *Cache) removeMatching(match func(cacheKey) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, element := range c.entries {
+		if match(key) {
+			c.remove(element)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Clear removes every cached response.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[cacheKey]*list.Element)
+	c.lru.Init()
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// ttl returns how many seconds a response can be cached for; zero if it can't be.
+func (c *Cache) ttl(msg *dns.Msg) uint32 {
+	switch {
+	case msg.Rcode == dns.RcodeSuccess && len(msg.Answer) > 0:
+		return minTTL(msg)
+	case msg.Rcode == dns.RcodeSuccess, msg.Rcode == dns.RcodeNameError:
+		soa := extractRecordsOfType[*dns.SOA](msg.Ns)
+		if len(soa) == 0 {
+			return 0
+		}
+		// The TTL of the SOA record, and of any NSEC or NSEC3 records proving the denial, is also respected.
+		ttl := min(minTTL(msg), soa[0].Minttl)
+		if c.MaxNegativeTTL > 0 {
+			ttl = min(ttl, uint32(c.MaxNegativeTTL/time.Second))
+		}
+		return ttl
+	default:
+		return 0
+	}
+}
+
+// minTTL returns the lowest TTL of the records in a response, ignoring the OPT record.
+func minTTL(msg *dns.Msg) uint32 {
+	ttl, found := uint32(0), false
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			if !found || rr.Header().Ttl < ttl {
+				ttl, found = rr.Header().Ttl, true
+			}
+		}
+	}
+	return ttl
+}
@@ -0,0 +1,318 @@
+package lookup
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheExpiresWithTTL(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(10)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("Example.com", dns.TypeA, newAnswerMsg(t,
+		"example.com. 300 IN A 192.0.2.1",
+		"example.com. 60 IN A 192.0.2.2",
+	))
+
+	now = now.Add(20 * time.Second)
+	msg, ok := cache.Get("example.com.", dns.TypeA)
+	require.True(t, ok)
+	assert.Equal(t, uint32(280), msg.Answer[0].Header().Ttl)
+	assert.Equal(t, uint32(40), msg.Answer[1].Header().Ttl)
+
+	_, ok = cache.Get("example.com.", dns.TypeAAAA)
+	assert.False(t, ok)
+
+	// The entry expires with the lowest TTL.
+	now = now.Add(40 * time.Second)
+	_, ok = cache.Get("example.com.", dns.TypeA)
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Len())
+
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2}, cache.Stats())
+}
+
+func TestCacheReturnsCopies(t *testing.T) {
+	cache := NewCache(10)
+	cache.Set("example.com.", dns.TypeA, newAnswerMsg(t, "example.com. 300 IN A 192.0.2.1"))
+
+	msg, ok := cache.Get("example.com.", dns.TypeA)
+	require.True(t, ok)
+	msg.Answer = nil
+
+	msg, ok = cache.Get("example.com.", dns.TypeA)
+	require.True(t, ok)
+	assert.Len(t, msg.Answer, 1)
+}
+
+func TestCacheEntries(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(10)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("example.net.", dns.TypeA, newAnswerMsg(t, "example.net. 300 IN A 192.0.2.1"))
+	cache.setValidated("example.com.", dns.TypeAAAA, newAnswerMsg(t, "example.com. 300 IN AAAA 2001:db8::1"), ValidationSecure)
+	cache.Set("example.com.", dns.TypeA, newAnswerMsg(t, "example.com. 30 IN A 192.0.2.2"))
+
+	now = now.Add(10 * time.Second)
+	entries := cache.Entries()
+	require.Len(t, entries, 3)
+
+	// Ordered by name, then type.
+	assert.Equal(t, "example.com.", entries[0].Name)
+	assert.Equal(t, dns.TypeA, entries[0].Rrtype)
+	assert.Equal(t, 20*time.Second, entries[0].TTL)
+	assert.Equal(t, ValidationIndeterminate, entries[0].Validation)
+	require.Len(t, entries[0].Answer, 1)
+	assert.Equal(t, uint32(20), entries[0].Answer[0].Header().Ttl)
+	assert.Equal(t, dns.TypeAAAA, entries[1].Rrtype)
+	assert.Equal(t, ValidationSecure, entries[1].Validation)
+	assert.Equal(t, "example.net.", entries[2].Name)
+
+	// Expired responses are left out, and looking doesn't count towards the stats.
+	now = now.Add(20 * time.Second)
+	assert.Len(t, cache.Entries(), 2)
+	assert.Equal(t, CacheStats{}, cache.Stats())
+
+	var buf bytes.Buffer
+	require.NoError(t, cache.Dump(&buf))
+	assert.Equal(t, "example.com. AAAA NOERROR ttl=4m30s validation=secure\n"+
+		"  example.com. 270 IN AAAA 2001:db8::1\n"+
+		"example.net. A NOERROR ttl=4m30s validation=indeterminate\n"+
+		"  example.net. 270 IN A 192.0.2.1\n", buf.String())
+}
+
+func TestCacheFlush(t *testing.T) {
+	cache := NewCache(10)
+	for _, name := range []string{"example.com.", "www.example.com.", "a.b.example.com.", "example.net.", "notexample.com."} {
+		cache.Set(name, dns.TypeA, newAnswerMsg(t, name+" 300 IN A 192.0.2.1"))
+		cache.Set(name, dns.TypeAAAA, newAnswerMsg(t, name+" 300 IN AAAA 2001:db8::1"))
+	}
+	require.Equal(t, 10, cache.Len())
+
+	assert.True(t, cache.FlushType("WWW.example.com", dns.TypeAAAA))
+	assert.False(t, cache.FlushType("www.example.com.", dns.TypeAAAA))
+	_, ok := cache.Get("www.example.com.", dns.TypeA)
+	assert.True(t, ok)
+
+	assert.Equal(t, 2, cache.Flush("example.net"))
+	assert.Zero(t, cache.Flush("example.net."))
+	assert.Equal(t, 7, cache.Len())
+
+	// A zone's own name, and every name below it, but not names that only end with the same characters.
+	assert.Equal(t, 5, cache.FlushZone("example.com"))
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get("notexample.com.", dns.TypeA)
+	assert.True(t, ok)
+
+	assert.Equal(t, 2, cache.FlushZone("."))
+	assert.Zero(t, cache.Len())
+}
+
+func TestCacheSkipsUncacheableResponses(t *testing.T) {
+	cache := NewCache(10)
+	cache.Set("example.com.", dns.TypeA, newAnswerMsg(t))
+	cache.Set("example.net.", dns.TypeA, newAnswerMsg(t, "example.net. 0 IN A 192.0.2.1"))
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(2)
+	cache.Set("a.example.", dns.TypeA, newAnswerMsg(t, "a.example. 300 IN A 192.0.2.1"))
+	cache.Set("b.example.", dns.TypeA, newAnswerMsg(t, "b.example. 300 IN A 192.0.2.2"))
+
+	_, ok := cache.Get("a.example.", dns.TypeA)
+	require.True(t, ok)
+
+	cache.Set("c.example.", dns.TypeA, newAnswerMsg(t, "c.example. 300 IN A 192.0.2.3"))
+	assert.Equal(t, 2, cache.Len())
+
+	_, ok = cache.Get("b.example.", dns.TypeA)
+	assert.False(t, ok)
+	_, ok = cache.Get("a.example.", dns.TypeA)
+	assert.True(t, ok)
+
+	cache.Clear()
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestQueryUsesCache(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil).Once()
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.Cache = NewCache(DefaultCacheSize)
+
+	for i := 0; i < 3; i++ {
+		records, err := d.QueryA("example.com.")
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+	}
+	ns.AssertNumberOfCalls(t, "Query", 1)
+}
+
+func TestCacheNegativeResponses(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(10)
+	cache.now = func() time.Time { return now }
+
+	soa, err := dns.NewRR("example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300")
+	require.NoError(t, err)
+
+	nxdomain := newAnswerMsg(t)
+	nxdomain.Rcode = dns.RcodeNameError
+	nxdomain.Ns = []dns.RR{soa}
+	cache.Set("missing.example.com.", dns.TypeA, nxdomain)
+
+	nodata := newAnswerMsg(t)
+	nodata.Ns = []dns.RR{soa}
+	cache.Set("example.com.", dns.TypeMX, nodata)
+
+	servfail := newAnswerMsg(t)
+	servfail.Rcode = dns.RcodeServerFailure
+	servfail.Ns = []dns.RR{soa}
+	cache.Set("broken.example.com.", dns.TypeA, servfail)
+
+	assert.Equal(t, 2, cache.Len())
+
+	msg, ok := cache.Get("missing.example.com.", dns.TypeA)
+	require.True(t, ok)
+	assert.Equal(t, dns.RcodeNameError, msg.Rcode)
+
+	// Negative responses are cached for the SOA's MINIMUM field, as it's lower than the SOA's TTL.
+	now = now.Add(299 * time.Second)
+	_, ok = cache.Get("example.com.", dns.TypeMX)
+	assert.True(t, ok)
+	now = now.Add(time.Second)
+	_, ok = cache.Get("example.com.", dns.TypeMX)
+	assert.False(t, ok)
+
+	// The SOA's fields are capped by MaxNegativeTTL.
+	cache.MaxNegativeTTL = time.Minute
+	cache.Set("missing.example.com.", dns.TypeA, nxdomain)
+	now = now.Add(time.Minute)
+	_, ok = cache.Get("missing.example.com.", dns.TypeA)
+	assert.False(t, ok)
+}
+
+func TestQueryCachesNXDOMAIN(t *testing.T) {
+	soa, err := dns.NewRR("example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300")
+	require.NoError(t, err)
+
+	nxdomain := newAnswerMsg(t)
+	nxdomain.Rcode = dns.RcodeNameError
+	nxdomain.Ns = []dns.RR{soa}
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "missing.example.com.", dns.TypeA).Return(
+		nxdomain, time.Millisecond, newRcodeError("missing.example.com.", dns.TypeA, "mock", dns.RcodeNameError)).Once()
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.Cache = NewCache(DefaultCacheSize)
+
+	for i := 0; i < 3; i++ {
+		_, err := d.QueryA("missing.example.com.")
+		assert.ErrorIs(t, err, ErrNXDomain)
+		assert.ErrorContains(t, err, "missing.example.com. A query to")
+	}
+	ns.AssertNumberOfCalls(t, "Query", 1)
+}
+
+func TestQueryDoesNotCacheUntrustedNXDOMAIN(t *testing.T) {
+	soa, err := dns.NewRR("example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		local         bool
+		authenticated bool
+	}{
+		// The denial can't be authenticated locally, so isn't cached.
+		{name: "validated locally", local: true, authenticated: true},
+		// The nameserver didn't vouch for it.
+		{name: "no AD flag", local: false, authenticated: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nxdomain := newAnswerMsg(t)
+			nxdomain.Rcode = dns.RcodeNameError
+			nxdomain.Ns = []dns.RR{soa}
+			nxdomain.AuthenticatedData = tt.authenticated
+
+			ns := &namedMockNameServer{name: "mock"}
+			ns.On("Query", "missing.example.com.", dns.TypeA).Return(
+				nxdomain, time.Millisecond, newRcodeError("missing.example.com.", dns.TypeA, "mock", dns.RcodeNameError))
+
+			d := NewDnsLookup([]NameServer{ns})
+			d.LocallyAuthenticateData = tt.local
+			d.Cache = NewCache(DefaultCacheSize)
+
+			for i := 0; i < 2; i++ {
+				_, err := d.QueryA("missing.example.com.")
+				assert.ErrorIs(t, err, ErrNXDomain)
+			}
+			ns.AssertNumberOfCalls(t, "Query", 2)
+			assert.Zero(t, d.Cache.Len())
+		})
+	}
+}
+
+// recordingNameServer records the questions asked of the NameServer it wraps.
+type recordingNameServer struct {
+	NameServer
+	mu        sync.Mutex
+	questions []string
+}
+
+func (n *recordingNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	n.mu.Lock()
+	n.questions = append(n.questions, name+" "+dns.TypeToString[rrtype])
+	n.mu.Unlock()
+	return n.NameServer.Query(name, rrtype)
+}
+
+func TestQueryCachesAuthenticationQueries(t *testing.T) {
+	zones := newTestChain(t)
+	require.NoError(t, zones[2].AddString("other.example.com. 300 IN A 192.0.2.2"))
+	server := newTestServer(t, zones)
+
+	ns := &recordingNameServer{NameServer: NewUdpNameserver(server.Address, server.Port)}
+	d := NewDnsLookup([]NameServer{ns})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+	d.Cache = NewCache(DefaultCacheSize)
+
+	_, err := d.QueryA("test.example.com.")
+	require.NoError(t, err)
+	assert.Contains(t, ns.questions, "example.com. DNSKEY")
+	assert.Contains(t, ns.questions, "example.com. DS")
+
+	// The DS and DNSKEY sets fetched to authenticate the first answer are reused for the second.
+	ns.questions = nil
+	_, err = d.QueryA("other.example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other.example.com. A"}, ns.questions)
+
+	// Each of them was authenticated before it was cached.
+	for _, entry := range d.Cache.Entries() {
+		assert.Equal(t, ValidationSecure, entry.Validation, entry.Name)
+	}
+
+	// An answer that fails authentication doesn't add what was fetched for it.
+	d.Cache = NewCache(DefaultCacheSize)
+	d.RootDNSSECRecords = nil
+	_, err = d.QueryA("test.example.com.")
+	require.ErrorIs(t, err, ErrDNSSECBogus)
+	assert.Zero(t, d.Cache.Len())
+}
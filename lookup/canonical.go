@@ -0,0 +1,159 @@
+package lookup
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/miekg/dns"
+)
+
+// CanonicalRR returns a copy of a record in canonical form, as defined in RFC 4034, section 6.2: the owner name,
+// and any domain names within the RDATA of the types listed there (as amended by RFC 6840), are lowercased, and
+// the TTL is set to originalTTL, normally the Original TTL field of the RRSIG covering the record.
+func CanonicalRR(rr dns.RR, originalTTL uint32) dns.RR {
+	rr = dns.Copy(rr)
+	h := rr.Header()
+	h.Name = dns.CanonicalName(h.Name)
+	h.Ttl = originalTTL
+
+	switch x := rr.(type) {
+	case *dns.NS:
+		x.Ns = dns.CanonicalName(x.Ns)
+	case *dns.MD:
+		x.Md = dns.CanonicalName(x.Md)
+	case *dns.MF:
+		x.Mf = dns.CanonicalName(x.Mf)
+	case *dns.CNAME:
+		x.Target = dns.CanonicalName(x.Target)
+	case *dns.SOA:
+		x.Ns = dns.CanonicalName(x.Ns)
+		x.Mbox = dns.CanonicalName(x.Mbox)
+	case *dns.MB:
+		x.Mb = dns.CanonicalName(x.Mb)
+	case *dns.MG:
+		x.Mg = dns.CanonicalName(x.Mg)
+	case *dns.MR:
+		x.Mr = dns.CanonicalName(x.Mr)
+	case *dns.PTR:
+		x.Ptr = dns.CanonicalName(x.Ptr)
+	case *dns.MINFO:
+		x.Rmail = dns.CanonicalName(x.Rmail)
+		x.Email = dns.CanonicalName(x.Email)
+	case *dns.MX:
+		x.Mx = dns.CanonicalName(x.Mx)
+	case *dns.RP:
+		x.Mbox = dns.CanonicalName(x.Mbox)
+		x.Txt = dns.CanonicalName(x.Txt)
+	case *dns.AFSDB:
+		x.Hostname = dns.CanonicalName(x.Hostname)
+	case *dns.RT:
+		x.Host = dns.CanonicalName(x.Host)
+	case *dns.SIG:
+		x.SignerName = dns.CanonicalName(x.SignerName)
+	case *dns.PX:
+		x.Map822 = dns.CanonicalName(x.Map822)
+		x.Mapx400 = dns.CanonicalName(x.Mapx400)
+	case *dns.NAPTR:
+		x.Replacement = dns.CanonicalName(x.Replacement)
+	case *dns.KX:
+		x.Exchanger = dns.CanonicalName(x.Exchanger)
+	case *dns.SRV:
+		x.Target = dns.CanonicalName(x.Target)
+	case *dns.DNAME:
+		x.Target = dns.CanonicalName(x.Target)
+	case *dns.RRSIG:
+		x.SignerName = dns.CanonicalName(x.SignerName)
+	}
+	return rr
+}
+
+// CanonicalRdata returns the RDATA of a record in wire format, which is what records are ordered by within an RRset.
+// The record should already be in canonical form.
+func CanonicalRdata(rr dns.RR) ([]byte, error) {
+	rr = dns.Copy(rr)
+	buf := make([]byte, dns.Len(rr)+1)
+	off, err := dns.PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to pack %s: %w", tabsToSpaces(rr.String()), err)
+	}
+	return buf[off-int(rr.Header().Rdlength) : off], nil
+}
+
+// CanonicalRRset returns a copy of an RRset in canonical form and canonical order, as defined in RFC 4034,
+// sections 6.2 and 6.3: each record is put into canonical form, then they're sorted by their RDATA, treated as
+// left-justified unsigned octet sequences, with duplicates removed.
+func CanonicalRRset(rrset []dns.RR, originalTTL uint32) ([]dns.RR, error) {
+	type wire struct {
+		rr    dns.RR
+		rdata []byte
+	}
+
+	wires := make([]wire, 0, len(rrset))
+	for _, rr := range rrset {
+		rr = CanonicalRR(rr, originalTTL)
+		rdata, err := CanonicalRdata(rr)
+		if err != nil {
+			return nil, err
+		}
+		wires = append(wires, wire{rr, rdata})
+	}
+
+	slices.SortStableFunc(wires, func(a, b wire) int {
+		return bytes.Compare(a.rdata, b.rdata)
+	})
+	wires = slices.CompactFunc(wires, func(a, b wire) bool {
+		return bytes.Equal(a.rdata, b.rdata)
+	})
+
+	results := make([]dns.RR, len(wires))
+	for i, w := range wires {
+		results[i] = w.rr
+	}
+	return results, nil
+}
+
+// CompareCanonicalNames compares two names in canonical DNS name order, as defined in RFC 4034, section 6.1. Names
+// are compared label by label, starting from the root, as lowercased octet strings; a name sorts before the names
+// beneath it. The result is -1 if a sorts before b, 0 if they're equal, and +1 if a sorts after b.
+func CompareCanonicalNames(a, b string) int {
+	la, lb := canonicalLabels(a), canonicalLabels(b)
+	for i := 0; i < len(la) && i < len(lb); i++ {
+		if c := bytes.Compare(la[len(la)-1-i], lb[len(lb)-1-i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(la) < len(lb):
+		return -1
+	case len(la) > len(lb):
+		return 1
+	}
+	return 0
+}
+
+// SortCanonicalNames sorts names into canonical DNS name order.
+func SortCanonicalNames(names []string) {
+	slices.SortStableFunc(names, CompareCanonicalNames)
+}
+
+// canonicalLabels returns the lowercased labels of a name as the octets they represent, with escapes resolved.
+// Names that can't be packed are split as given.
+func canonicalLabels(name string) [][]byte {
+	name = dns.CanonicalName(name)
+	buf := make([]byte, 256)
+	end, err := dns.PackDomainName(name, buf, 0, nil, false)
+	if err != nil {
+		labels := make([][]byte, 0)
+		for _, label := range dns.SplitDomainName(name) {
+			labels = append(labels, []byte(label))
+		}
+		return labels
+	}
+
+	labels := make([][]byte, 0)
+	for off := 0; off < end && buf[off] != 0; off += int(buf[off]) + 1 {
+		labels = append(labels, bytes.ToLower(buf[off+1:off+1+int(buf[off])]))
+	}
+	return labels
+}
@@ -0,0 +1,62 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortCanonicalNames(t *testing.T) {
+	// The example from RFC 4034, section 6.1.
+	expected := []string{
+		"example.",
+		"a.example.",
+		"yljkjljk.a.example.",
+		"Z.a.example.",
+		"zABC.a.EXAMPLE.",
+		"z.example.",
+		`\001.z.example.`,
+		"*.z.example.",
+		`\200.z.example.`,
+	}
+
+	names := []string{
+		`\200.z.example.`, "z.example.", "zABC.a.EXAMPLE.", "example.", `\001.z.example.`,
+		"yljkjljk.a.example.", "*.z.example.", "a.example.", "Z.a.example.",
+	}
+	SortCanonicalNames(names)
+	assert.Equal(t, expected, names)
+
+	assert.Equal(t, 0, CompareCanonicalNames("Example.COM.", "example.com"))
+}
+
+func TestCanonicalRRset(t *testing.T) {
+	rrset := make([]dns.RR, 0)
+	for _, s := range []string{
+		"Example.COM. 300 IN MX 20 Mail2.Example.COM.",
+		"example.com. 60 IN MX 10 mail.example.com.",
+		"example.com. 300 IN MX 20 mail2.example.com.",
+	} {
+		rr, err := dns.NewRR(s)
+		require.NoError(t, err)
+		rrset = append(rrset, rr)
+	}
+
+	canonical, err := CanonicalRRset(rrset, 3600)
+	require.NoError(t, err)
+
+	// Sorted by RDATA, with the duplicate that differed only in case removed.
+	require.Len(t, canonical, 2)
+	assert.Equal(t, "example.com.\t3600\tIN\tMX\t10 mail.example.com.", canonical[0].String())
+	assert.Equal(t, "example.com.\t3600\tIN\tMX\t20 mail2.example.com.", canonical[1].String())
+
+	// The records given are left unchanged.
+	assert.Equal(t, "Example.COM.", rrset[0].Header().Name)
+	assert.Equal(t, uint32(300), rrset[0].Header().Ttl)
+
+	rdata, err := CanonicalRdata(canonical[0])
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 10, 4, 'm', 'a', 'i', 'l', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0}, rdata)
+}
@@ -0,0 +1,62 @@
+package lookup
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+// WithClientSubnet adds an EDNS Client Subnet option (RFC 7871) to queries sent to the nameserver, so resolvers
+// that support it can return answers tailored to that network, as CDNs do. Only the prefix's leading bits are sent.
+//
+// A prefix length of zero, e.g. 0.0.0.0/0, asks the resolver not to use the client's own subnet either, for privacy.
+func WithClientSubnet(prefix netip.Prefix) NameServerOption {
+	return func(n *NameServerConcrete) {
+		subnet, err := newClientSubnet(prefix)
+		if err != nil {
+			n.err = err
+		}
+		n.clientSubnet = subnet
+	}
+}
+
+// WithHttpClientSubnet adds an EDNS Client Subnet option (RFC 7871) to queries sent to the DoH nameserver.
+// See WithClientSubnet.
+func WithHttpClientSubnet(prefix netip.Prefix) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		subnet, err := newClientSubnet(prefix)
+		if err != nil {
+			n.err = err
+		}
+		n.clientSubnet = subnet
+	}
+}
+
+// newClientSubnet creates the EDNS0 option for a prefix, with the host bits cleared.
+func newClientSubnet(prefix netip.Prefix) (*dns.EDNS0_SUBNET, error) {
+	if !prefix.IsValid() {
+		return nil, fmt.Errorf("invalid client subnet %s", prefix)
+	}
+
+	prefix = prefix.Masked()
+	family := uint16(1)
+	if prefix.Addr().Is6() {
+		family = 2
+	}
+
+	return &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        family,
+		SourceNetmask: uint8(prefix.Bits()),
+		Address:       net.IP(prefix.Addr().AsSlice()),
+	}, nil
+}
+
+// addClientSubnet adds a client subnet option to a message's OPT record. It has no effect if either is nil.
+func addClientSubnet(msg *dns.Msg, subnet *dns.EDNS0_SUBNET) {
+	if opt := msg.IsEdns0(); opt != nil && subnet != nil {
+		opt.Option = append(opt.Option, subnet)
+	}
+}
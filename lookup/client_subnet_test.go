@@ -0,0 +1,69 @@
+package lookup
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithClientSubnet(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		family  uint16
+		netmask uint8
+		address net.IP
+	}{
+		{prefix: "192.0.2.77/24", family: 1, netmask: 24, address: net.ParseIP("192.0.2.0").To4()},
+		{prefix: "2001:db8:1234:5678::1/48", family: 2, netmask: 48, address: net.ParseIP("2001:db8:1234::")},
+		{prefix: "0.0.0.0/0", family: 1, netmask: 0, address: net.ParseIP("0.0.0.0").To4()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+			ns := NewUdpNameserver("192.0.2.1", "53", WithClientSubnet(netip.MustParsePrefix(tt.prefix))).(*NameServerConcrete)
+			ns.client = client
+
+			_, _, err := ns.Query("example.com.", dns.TypeA)
+			require.NoError(t, err)
+
+			opt := client.lastMsg.IsEdns0()
+			require.NotNil(t, opt)
+			require.Len(t, opt.Option, 1)
+			subnet, ok := opt.Option[0].(*dns.EDNS0_SUBNET)
+			require.True(t, ok)
+			assert.Equal(t, tt.family, subnet.Family)
+			assert.Equal(t, tt.netmask, subnet.SourceNetmask)
+			assert.Equal(t, uint8(0), subnet.SourceScope)
+			assert.True(t, tt.address.Equal(subnet.Address))
+
+			// The option must survive being packed.
+			_, err = client.lastMsg.Pack()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWithClientSubnet_Invalid(t *testing.T) {
+	ns := NewUdpNameserver("192.0.2.1", "53", WithClientSubnet(netip.Prefix{}))
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "invalid client subnet")
+
+	doh := NewHttpsNameserver("https://dns.example/dns-query", WithHttpClientSubnet(netip.Prefix{}))
+	_, _, err = doh.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "invalid client subnet")
+}
+
+func TestWithClientSubnet_None(t *testing.T) {
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = client
+
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Empty(t, client.lastMsg.IsEdns0().Option)
+}
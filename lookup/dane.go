@@ -0,0 +1,111 @@
+package lookup
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TLSA certificate usages, as defined in RFC 7218.
+const (
+	DanePkixTA uint8 = 0 // The CA certificate must match, and the chain pass PKIX validation
+	DanePkixEE uint8 = 1 // The end entity certificate must match, and the chain pass PKIX validation
+	DaneTA     uint8 = 2 // The chain must be issued by the matching trust anchor
+	DaneEE     uint8 = 3 // The end entity certificate must match
+)
+
+// Errors returned by VerifyDANE.
+var (
+	ErrNoTLSARecords   = errors.New("no tlsa records found")              // The service doesn't use DANE
+	ErrTLSAUnvalidated = errors.New("tlsa records are not dnssec secure") // The records can't be trusted for DANE
+	ErrDANEMismatch    = errors.New("no tlsa record matches the certificate chain")
+)
+
+// VerifyDANE fetches the TLSA records of a TLS service over TCP, e.g. ("example.com", 443), and checks the certificate
+// chain a server presented against them, as described in RFC 6698 and RFC 7671. The chain is in the order presented,
+// starting with the server's own certificate. A nil error means at least one record matched.
+//
+// The records must be DNSSEC secure, so LocallyAuthenticateData or RemotelyAuthenticateData must be set. For the
+// PKIX-TA and PKIX-EE usages, only the match is checked here; the chain must still pass the usual PKIX validation.
+func (d *DnsLookup) VerifyDANE(host string, port int, certChain []*x509.Certificate) error {
+	return d.VerifyDANEContext(context.Background(), host, port, certChain)
+}
+
+// VerifyDANEContext checks a certificate chain against a service's TLSA records, stopping when ctx is done.
+func (d *DnsLookup) VerifyDANEContext(ctx context.Context, host string, port int, certChain []*x509.Certificate) error {
+	if len(certChain) == 0 {
+		return fmt.Errorf("no certificates to verify")
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+	name, err := TLSAName(host, uint16(port), "tcp")
+	if err != nil {
+		return err
+	}
+
+	result := d.QueryResultContext(ctx, name, dns.TypeTLSA)
+	if result.Err != nil {
+		return result.Err
+	}
+	if !daneSecure(d, result) {
+		return fmt.Errorf("%w: %s is %s", ErrTLSAUnvalidated, name, result.Validation)
+	}
+
+	records := Answers[*dns.TLSA](result)
+	if len(records) == 0 {
+		return fmt.Errorf("%w for %s", ErrNoTLSARecords, name)
+	}
+
+	for _, record := range records {
+		if matchesTLSA(record, certChain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w for %s", ErrDANEMismatch, name)
+}
+
+// daneSecure reports whether a result can be trusted for DANE: either it was validated locally, or by a resolver
+// that was required to set the AD flag.
+func daneSecure(d *DnsLookup, result Result) bool {
+	if result.Validation == ValidationSecure {
+		return true
+	}
+	return d.RemotelyAuthenticateData && result.Flags.AuthenticatedData && result.Validation != ValidationInsecure
+}
+
+// matchesTLSA reports whether a TLSA record matches the certificate chain, according to its usage. Records with a
+// usage, selector or matching type that isn't known never match, as RFC 6698, section 4.1 requires.
+func matchesTLSA(record *dns.TLSA, chain []*x509.Certificate) bool {
+	switch record.Usage {
+	case DanePkixEE, DaneEE:
+		return matchesCertificate(record, chain[0])
+	case DanePkixTA:
+		for _, cert := range chain[1:] {
+			if matchesCertificate(record, cert) {
+				return true
+			}
+		}
+	case DaneTA:
+		// The trust anchor must have issued the chain, through any intermediates before it.
+		for i := 1; i < len(chain); i++ {
+			if chain[i-1].CheckSignatureFrom(chain[i]) != nil {
+				return false
+			}
+			if matchesCertificate(record, chain[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesCertificate reports whether a TLSA record's association data matches the certificate.
+func matchesCertificate(record *dns.TLSA, cert *x509.Certificate) bool {
+	data, err := dns.CertificateToDANE(record.Selector, record.MatchingType, cert)
+	return err == nil && strings.EqualFold(data, record.Certificate)
+}
@@ -0,0 +1,109 @@
+package lookup
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDaneCertificate creates a certificate for name, signed by the issuer, or self-signed if issuer is nil.
+func newDaneCertificate(t *testing.T, name string, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  issuer == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if issuer == nil {
+		issuer, issuerKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// newTLSALookup returns a DnsLookup that answers the TLSA query for example.com port 443 with a record of the
+// given usage, matching cert. The answer has the AD flag set, and the resolver is trusted to set it.
+func newTLSALookup(t *testing.T, usage uint8, cert *x509.Certificate) *DnsLookup {
+	record := &dns.TLSA{Hdr: dns.RR_Header{Name: "_443._tcp.example.com.", Class: dns.ClassINET, Ttl: 300}}
+	require.NoError(t, record.Sign(int(usage), 1, 1, cert))
+
+	response := newAnswerMsg(t)
+	response.Answer = []dns.RR{record}
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "_443._tcp.example.com.", dns.TypeTLSA).Return(response, time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.RemotelyAuthenticateData = true
+	return d
+}
+
+func TestVerifyDANE(t *testing.T) {
+	ca, caKey := newDaneCertificate(t, "Test CA", nil, nil)
+	leaf, _ := newDaneCertificate(t, "example.com", ca, caKey)
+	other, _ := newDaneCertificate(t, "other.example.com", ca, caKey)
+	chain := []*x509.Certificate{leaf, ca}
+
+	assert.NoError(t, newTLSALookup(t, DaneEE, leaf).VerifyDANE("example.com", 443, chain))
+	assert.NoError(t, newTLSALookup(t, DaneTA, ca).VerifyDANE("example.com", 443, chain))
+	assert.NoError(t, newTLSALookup(t, DanePkixTA, ca).VerifyDANE("example.com", 443, chain))
+	assert.NoError(t, newTLSALookup(t, DanePkixEE, leaf).VerifyDANE("example.com", 443, chain))
+
+	err := newTLSALookup(t, DaneEE, other).VerifyDANE("example.com", 443, chain)
+	assert.ErrorIs(t, err, ErrDANEMismatch)
+
+	// The end entity certificate doesn't match a trust anchor record.
+	err = newTLSALookup(t, DaneTA, leaf).VerifyDANE("example.com", 443, chain)
+	assert.ErrorIs(t, err, ErrDANEMismatch)
+
+	// A trust anchor that didn't issue the chain doesn't match.
+	otherCA, _ := newDaneCertificate(t, "Other CA", nil, nil)
+	err = newTLSALookup(t, DaneTA, otherCA).VerifyDANE("example.com", 443, []*x509.Certificate{leaf, otherCA})
+	assert.ErrorIs(t, err, ErrDANEMismatch)
+}
+
+func TestVerifyDANE_Unvalidated(t *testing.T) {
+	ca, caKey := newDaneCertificate(t, "Test CA", nil, nil)
+	leaf, _ := newDaneCertificate(t, "example.com", ca, caKey)
+
+	d := newTLSALookup(t, DaneEE, leaf)
+	d.RemotelyAuthenticateData = false
+
+	err := d.VerifyDANE("example.com", 443, []*x509.Certificate{leaf})
+	assert.ErrorIs(t, err, ErrTLSAUnvalidated)
+}
+
+func TestVerifyDANE_NoRecords(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "_25._tcp.mail.example.com.", dns.TypeTLSA).Return(newAnswerMsg(t), time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	ca, _ := newDaneCertificate(t, "Test CA", nil, nil)
+	err := d.VerifyDANE("mail.example.com", 25, []*x509.Certificate{ca})
+	assert.ErrorIs(t, err, ErrNoTLSARecords)
+
+	assert.ErrorContains(t, d.VerifyDANE("mail.example.com", 0, []*x509.Certificate{ca}), "invalid port")
+	assert.ErrorContains(t, d.VerifyDANE("mail.example.com", 25, nil), "no certificates")
+}
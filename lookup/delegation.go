@@ -0,0 +1,100 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DelegationReport compares a zone's Key Signing Keys with the DS records published for it in its parent.
+type DelegationReport struct {
+	Zone     string
+	Keys     []*dns.DNSKEY // The zone's Key Signing Keys (DNSKEYs with the SEP flag set, and not revoked)
+	Matched  []*dns.DS     // DS records in the parent that match one of the zone's Key Signing Keys
+	Missing  []*dns.DS     // Candidate SHA-256 DS records for Key Signing Keys that no DS record in the parent matches
+	Orphaned []*dns.DS     // DS records in the parent that match none of the zone's keys
+}
+
+// Consistent reports whether every Key Signing Key has a DS record, and every DS record has a key.
+func (r *DelegationReport) Consistent() bool {
+	return len(r.Matched) > 0 && len(r.Missing) == 0 && len(r.Orphaned) == 0
+}
+
+// CheckDelegation fetches a zone's DNSKEY set and its parent's DS set, and reports which DS records match the
+// zone's keys, which keys have no DS record, and which DS records have no key; the check to run after a key
+// rollover. The records are compared as published, without being validated, as an inconsistent delegation would
+// fail validation.
+func (d *DnsLookup) CheckDelegation(zone string) (*DelegationReport, error) {
+	zone = dns.CanonicalName(zone)
+	if err := checkNameLength(zone); err != nil {
+		return nil, err
+	}
+	if zone == "." {
+		return nil, fmt.Errorf("the root zone has no parent to check a delegation in")
+	}
+
+	ctx := context.Background()
+
+	keysMsg, _, err := d.query(zone, dns.TypeDNSKEY, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the DNSKEY records for %s: %w", zone, err)
+	}
+	dsMsg, _, err := d.query(zone, dns.TypeDS, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the DS records for %s: %w", zone, err)
+	}
+
+	report := &DelegationReport{
+		Zone:     zone,
+		Keys:     make([]*dns.DNSKEY, 0),
+		Matched:  make([]*dns.DS, 0),
+		Missing:  make([]*dns.DS, 0),
+		Orphaned: make([]*dns.DS, 0),
+	}
+
+	keys := extractRecordsOfType[*dns.DNSKEY](keysMsg.Answer)
+	for _, key := range keys {
+		if key.Flags&dns.SEP != 0 && key.Flags&dns.REVOKE == 0 {
+			report.Keys = append(report.Keys, key)
+		}
+	}
+
+	dsRecords := extractRecordsOfType[*dns.DS](dsMsg.Answer)
+	for _, ds := range dsRecords {
+		if _, ok := matchDS(ds, keys); ok {
+			report.Matched = append(report.Matched, ds)
+		} else {
+			report.Orphaned = append(report.Orphaned, ds)
+		}
+	}
+
+	for _, key := range report.Keys {
+		matched := false
+		for _, ds := range report.Matched {
+			if _, ok := matchDS(ds, []*dns.DNSKEY{key}); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			if candidate := key.ToDS(dns.SHA256); candidate != nil {
+				report.Missing = append(report.Missing, candidate)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// matchDS returns the key a DS record was computed from, if it's one of the keys given.
+func matchDS(ds *dns.DS, keys []*dns.DNSKEY) (*dns.DNSKEY, bool) {
+	for _, key := range keys {
+		keyDS := key.ToDS(ds.DigestType)
+		if keyDS != nil && ds.KeyTag == keyDS.KeyTag && ds.Algorithm == keyDS.Algorithm && strings.EqualFold(ds.Digest, keyDS.Digest) {
+			return key, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,51 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDelegation(t *testing.T) {
+	zones := newTestChain(t)
+	com, example := zones[1], zones[2]
+
+	// A second KSK, without a DS record, and a DS record without a key, as mid-rollover.
+	next, _, err := dnssectest.GenerateKey("example.com.", dnssectest.FlagKSK, dns.ECDSAP256SHA256, 0)
+	require.NoError(t, err)
+	keys := []dns.RR{example.KSK, example.ZSK, next}
+	rrsig, err := dnssectest.Sign(keys, example.KSK, example.KSKSigner, example.Inception, example.Expiration)
+	require.NoError(t, err)
+	example.Set("example.com.", dns.TypeDNSKEY, append(keys, rrsig)...)
+
+	old, _, err := dnssectest.GenerateKey("example.com.", dnssectest.FlagKSK, dns.ECDSAP256SHA256, 0)
+	require.NoError(t, err)
+	require.NoError(t, com.Add(old.ToDS(dns.SHA256)))
+
+	server := newTestServer(t, zones)
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+
+	report, err := d.CheckDelegation("Example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com.", report.Zone)
+	assert.Len(t, report.Keys, 2)
+	require.Len(t, report.Matched, 1)
+	assert.Equal(t, example.KSK.KeyTag(), report.Matched[0].KeyTag)
+	require.Len(t, report.Missing, 1)
+	assert.Equal(t, next.KeyTag(), report.Missing[0].KeyTag)
+	require.Len(t, report.Orphaned, 1)
+	assert.Equal(t, old.KeyTag(), report.Orphaned[0].KeyTag)
+	assert.False(t, report.Consistent())
+
+	report, err = d.CheckDelegation("com.")
+	require.NoError(t, err)
+	assert.True(t, report.Consistent())
+
+	_, err = d.CheckDelegation(".")
+	assert.ErrorContains(t, err, "no parent")
+}
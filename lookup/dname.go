@@ -0,0 +1,78 @@
+package lookup
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// withoutSynthesizedCNAMEs returns the records without any unsigned CNAMEs synthesised from a DNAME among them. A
+// nameserver answering a query below a DNAME synthesises the CNAME on the fly, so it has no RRSIG; the DNAME it was
+// synthesised from is signed, and authenticating that is enough to trust the CNAME, as RFC 6672, section 5.3.3
+// describes. A CNAME that doesn't match the DNAME is kept, so fails authentication.
+func withoutSynthesizedCNAMEs(rrs []dns.RR) []dns.RR {
+	dnames := extractRecordsOfType[*dns.DNAME](rrs)
+	if len(dnames) == 0 {
+		return rrs
+	}
+
+	signed := make(map[string]bool)
+	for _, sig := range extractRecordsOfType[*dns.RRSIG](rrs) {
+		if sig.TypeCovered == dns.TypeCNAME {
+			signed[dns.CanonicalName(sig.Hdr.Name)] = true
+		}
+	}
+
+	result := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if cname, ok := rr.(*dns.CNAME); ok && !signed[dns.CanonicalName(cname.Hdr.Name)] && synthesizedFromAny(cname, dnames) {
+			continue
+		}
+		result = append(result, rr)
+	}
+	return result
+}
+
+// synthesizedFromAny reports whether the CNAME is the one synthesised from any of the DNAMEs.
+func synthesizedFromAny(cname *dns.CNAME, dnames []*dns.DNAME) bool {
+	for _, dname := range dnames {
+		if target, ok := dnameTarget(cname.Hdr.Name, dname); ok && strings.EqualFold(target, cname.Target) {
+			return true
+		}
+	}
+	return false
+}
+
+// dnameTarget returns the name a DNAME redirects name to, as defined in RFC 6672, section 2.2: the DNAME's owner is
+// replaced by its target. It returns false if name isn't below the DNAME's owner.
+func dnameTarget(name string, dname *dns.DNAME) (string, bool) {
+	owner := dns.Fqdn(dname.Hdr.Name)
+	name = dns.Fqdn(name)
+	if !dns.IsSubDomain(owner, name) || dns.CountLabel(name) == dns.CountLabel(owner) {
+		return "", false
+	}
+
+	labels := dns.SplitDomainName(name)
+	prefix := strings.Join(labels[:len(labels)-dns.CountLabel(owner)], ".")
+	if dname.Target == "." {
+		return prefix + ".", true
+	}
+	return prefix + "." + dns.Fqdn(dname.Target), true
+}
+
+//-----
+
+// QueryDNAME performs a DNS query for DNAME records
+func (d *DnsLookup) QueryDNAME(name string) ([]*dns.DNAME, error) {
+	return d.QueryDNAMEContext(context.Background(), name)
+}
+
+// QueryDNAMEContext performs a DNS query for DNAME records, stopping when ctx is done
+func (d *DnsLookup) QueryDNAMEContext(ctx context.Context, name string) ([]*dns.DNAME, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeDNAME)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.DNAME](msg.Answer), nil
+}
@@ -0,0 +1,87 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDnameTarget(t *testing.T) {
+	dname := &dns.DNAME{Hdr: dns.RR_Header{Name: "old.example.com."}, Target: "new.example.net."}
+
+	target, ok := dnameTarget("www.OLD.example.com.", dname)
+	assert.True(t, ok)
+	assert.Equal(t, "www.new.example.net.", target)
+
+	// The DNAME's owner itself isn't redirected.
+	_, ok = dnameTarget("old.example.com.", dname)
+	assert.False(t, ok)
+
+	_, ok = dnameTarget("www.example.com.", dname)
+	assert.False(t, ok)
+}
+
+// newDnameLookup serves the test chain with the DNAME old.example.com. -> new.example.com. added. An A query for
+// www.old.example.com. is answered with the DNAME, a CNAME synthesised to cnameTarget, and the A record of
+// www.new.example.com., as a nameserver would answer it.
+func newDnameLookup(t *testing.T, cnameTarget string) *DnsLookup {
+	zones := newTestChain(t)
+	example := zones[2]
+	require.NoError(t, example.AddString(
+		"old.example.com. 300 IN DNAME new.example.com.",
+		"www.new.example.com. 300 IN A 192.0.2.1",
+	))
+	server := newTestServer(t, zones)
+
+	response := new(dns.Msg)
+	response.SetQuestion("www.old.example.com.", dns.TypeA)
+	response.Response = true
+	response.Answer = append(response.Answer, example.Get("old.example.com.", dns.TypeDNAME)...)
+	response.Answer = append(response.Answer, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "www.old.example.com.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+		Target: cnameTarget,
+	})
+	response.Answer = append(response.Answer, example.Get("www.new.example.com.", dns.TypeA)...)
+
+	ns := &delayedNameServer{name: "dname", response: response, next: NewUdpNameserver(server.Address, server.Port)}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+	return d
+}
+
+func TestAuthenticateSynthesizedCNAME(t *testing.T) {
+	d := newDnameLookup(t, "www.new.example.com.")
+
+	answers, err := d.QueryA("www.old.example.com.")
+	require.NoError(t, err)
+	require.Len(t, answers, 1)
+	assert.Equal(t, "192.0.2.1", answers[0].A.String())
+}
+
+func TestAuthenticateSynthesizedCNAMEMismatch(t *testing.T) {
+	// A CNAME that doesn't follow from the DNAME isn't covered by its signature.
+	d := newDnameLookup(t, "www.elsewhere.example.com.")
+
+	_, err := d.QueryA("www.old.example.com.")
+	assert.ErrorIs(t, err, ErrDNSSECBogus)
+	assert.ErrorContains(t, err, "was unable to be assigned to any RRSIG")
+}
+
+func TestQueryDNAME(t *testing.T) {
+	zones := newTestChain(t)
+	require.NoError(t, zones[2].AddString("old.example.com. 300 IN DNAME new.example.com."))
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	records, err := d.QueryDNAME("old.example.com.")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "new.example.com.", records[0].Target)
+}
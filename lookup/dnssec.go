@@ -2,9 +2,11 @@ package lookup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/miekg/dns"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,11 +19,49 @@ const (
 type contextKey string
 
 const (
-	contextTrace  contextKey = "trace"  // Context key for recursion depth
-	contextDepth  contextKey = "depth"  // Context key for recursion depth
-	initialDomain contextKey = "domain" // Context key for the initial domain
+	contextTrace      contextKey = "trace"      // Context key for the query's Observer
+	contextDepth      contextKey = "depth"      // Context key for recursion depth
+	initialDomain     contextKey = "domain"     // Context key for the initial domain
+	contextQueries    contextKey = "queries"    // Context key for the responses fetched whilst authenticating
+	contextNoCache    contextKey = "nocache"    // Context key to bypass the response cache
+	contextInfo       contextKey = "info"       // Context key for where a query's answer came from, for its Result
+	contextQueryTrace contextKey = "querytrace" // Context key for the Trace a query records to, set by ContextWithTrace
 )
 
+// authenticationQueries holds the DNSKEY and DS responses fetched during a single Authenticate call, keyed by question.
+// It's safe for concurrent use; concurrent requests for the same question share a single query.
+type authenticationQueries struct {
+	mu      sync.Mutex
+	results map[string]*authenticationQueryResult
+}
+
+// authenticationQueryResult is the outcome of a single query made whilst authenticating.
+type authenticationQueryResult struct {
+	name   string
+	rrtype uint16
+	done   chan struct{} // Closed once msg and err are set
+	msg    *dns.Msg
+	err    error
+	cached bool // Whether msg came from the Cache
+}
+
+// cache adds the responses fetched to the cache. It must only be called once the answer they were fetched for has
+// been authenticated, so each of them has been too.
+func (q *authenticationQueries) cache(cache *Cache) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, result := range q.results {
+		select {
+		case <-result.done:
+		default:
+			continue
+		}
+		if result.err == nil && !result.cached && result.msg != nil && result.msg.Rcode == dns.RcodeSuccess {
+			cache.setValidated(result.name, result.rrtype, result.msg, ValidationSecure)
+		}
+	}
+}
+
 // SignatureSets represents a collection of SignatureSet pointers
 type SignatureSets []*SignatureSet
 
@@ -115,6 +155,9 @@ func (d *DnsLookup) Authenticate(msg *dns.Msg, ctx context.Context) error {
 	if msg == nil {
 		return fmt.Errorf("no DNS message provided")
 	}
+	if len(msg.Question) == 0 {
+		return fmt.Errorf("DNS message has no question")
+	}
 
 	// Retrieve the depth from the context, default to 0 if not found
 	depth, ok := ctx.Value(contextDepth).(uint8)
@@ -135,7 +178,14 @@ func (d *DnsLookup) Authenticate(msg *dns.Msg, ctx context.Context) error {
 		ctx = context.WithValue(ctx, initialDomain, domain)
 	}
 
-	logger := d.logger.With().
+	// Ensure every query made for this authentication shares the same set of fetched responses
+	if _, ok := ctx.Value(contextQueries).(*authenticationQueries); !ok {
+		ctx = context.WithValue(ctx, contextQueries, &authenticationQueries{
+			results: make(map[string]*authenticationQueryResult),
+		})
+	}
+
+	logger := d.componentLogger(LogComponentValidation).With().
 		Str("domain", msg.Question[0].Name).
 		Uint8("depth", depth).
 		Logger()
@@ -154,15 +204,19 @@ func (d *DnsLookup) Authenticate(msg *dns.Msg, ctx context.Context) error {
 		if kss.signature.SignerName == "." {
 			logger.Info().Str("zone", kss.signature.SignerName).Msg("Using root DS digest anchor")
 
-			for _, answer := range d.RootDNSSECRecords {
+			for _, answer := range d.rootDNSSECRecords() {
 				keyDS := kss.key.ToDS(answer.DigestType)
 				// Case-insensitive string match for DS digest
 				if answer.KeyTag == keyDS.KeyTag && answer.Algorithm == keyDS.Algorithm && strings.EqualFold(answer.Digest, keyDS.Digest) {
 					logger.Info().
 						Str("digest", answer.Digest).
 						Msg("Key Signing Key authenticated at root.")
-					if trace, ok := ctx.Value(contextTrace).(*Trace); ok {
-						trace.Add(newTraceDelegationSignerCheck(depth, msg.Question[0].Name, kss.signature.SignerName, keyDS.Digest))
+					if observer := observerFrom(ctx); observer != nil {
+						observer.OnDelegationCheck(newTraceDelegationSignerCheck(depth, msg.Question[0].Name, kss.signature.SignerName, keyDS.Digest))
+					}
+					// The root DNSKEY set is now authenticated, so can be checked for keys the anchors don't cover.
+					if keyMsg, err := d.authenticationQuery(".", dns.TypeDNSKEY, ctx); err == nil {
+						d.checkRootKeys(extractRecordsOfType[*dns.DNSKEY](keyMsg.Answer))
 					}
 					return nil
 				}
@@ -174,8 +228,7 @@ func (d *DnsLookup) Authenticate(msg *dns.Msg, ctx context.Context) error {
 			// Check the parent DS digest
 			logger.Info().Str("zone", kss.signature.SignerName).Msg("Checking parent DS digest")
 
-			//answers, dsMsg, _, err := d.QueryDS(kss.signature.SignerName)
-			dsMsg, _, err := d.query(kss.signature.SignerName, dns.TypeDS, ctx)
+			dsMsg, err := d.authenticationQuery(kss.signature.SignerName, dns.TypeDS, ctx)
 			if err != nil {
 				return err
 			}
@@ -189,8 +242,8 @@ func (d *DnsLookup) Authenticate(msg *dns.Msg, ctx context.Context) error {
 						Str("digest", answer.Digest).
 						Str("zone", kss.signature.SignerName).
 						Msg("Key Signing Key authenticated at parent. Next authenticating parent's zone.")
-					if trace, ok := ctx.Value(contextTrace).(*Trace); ok {
-						trace.Add(newTraceDelegationSignerCheck(depth, msg.Question[0].Name, kss.signature.SignerName, keyDS.Digest))
+					if observer := observerFrom(ctx); observer != nil {
+						observer.OnDelegationCheck(newTraceDelegationSignerCheck(depth, msg.Question[0].Name, kss.signature.SignerName, keyDS.Digest))
 					}
 					return d.Authenticate(dsMsg, context.WithValue(ctx, contextDepth, depth+1))
 				}
@@ -214,96 +267,177 @@ func (d *DnsLookup) authenticateZoneSigningKey(msg *dns.Msg, ctx context.Context
 		return nil, fmt.Errorf("missing depth from context")
 	}
 
-	logger := d.logger.With().Uint8("depth", depth).Str("domain", msg.Question[0].Name).Logger()
+	logger := d.componentLogger(LogComponentValidation).With().Uint8("depth", depth).Str("domain", msg.Question[0].Name).Logger()
 
-	// Create signature sets from the DNS response
-	zoneSignatureSets, err := newSignatureSets(msg.Answer)
+	// Create signature sets from the DNS response. CNAMEs synthesised from a DNAME aren't signed, so are trusted by
+	// authenticating the DNAME instead.
+	zoneSignatureSets, err := newSignatureSets(withoutSynthesizedCNAMEs(msg.Answer))
 	if err != nil {
 		return nil, err
 	}
 
 	logger.Info().Int("number-of-signatures", len(zoneSignatureSets)).Msg("Authenticating zone's ZSK and KSK")
 
-	for _, zss := range zoneSignatureSets {
-		// Request DNSKEY Records for the signer name
-		//keys, keysMsg, _, err := d.QueryDNSKEY(zss.signature.SignerName)
-		keysMsg, _, err := d.query(zss.signature.SignerName, dns.TypeDNSKEY, ctx)
-		if err != nil {
-			return nil, err
-		}
-		keys := extractRecordsOfType[*dns.DNSKEY](keysMsg.Answer)
+	// Each signature set is independent, so they're authenticated concurrently.
+	results := make([][]*SignatureSet, len(zoneSignatureSets))
+	errs := make([]error, len(zoneSignatureSets))
+
+	var wg sync.WaitGroup
+	for i, zss := range zoneSignatureSets {
+		wg.Add(1)
+		go func(i int, zss *SignatureSet) {
+			defer wg.Done()
+			results[i], errs[i] = d.authenticateSignatureSet(msg, zss, depth, ctx)
+		}(i, zss)
+	}
+	wg.Wait()
+
+	if err = errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		allValidKeysSignatureSets = append(allValidKeysSignatureSets, result...)
+	}
+
+	return allValidKeysSignatureSets, nil
+}
+
+// authenticateSignatureSet verifies a single signature set using its zone's ZSK, then verifies the zone's DNSKEY set
+// using its KSK. The verified key signature sets are returned.
+func (d *DnsLookup) authenticateSignatureSet(msg *dns.Msg, zss *SignatureSet, depth uint8, ctx context.Context) ([]*SignatureSet, error) {
+	validKeysSignatureSets := make([]*SignatureSet, 0)
+
+	logger := d.componentLogger(LogComponentValidation).With().Uint8("depth", depth).Str("domain", msg.Question[0].Name).Logger()
 
-		// Add matching Zone Signing Key (ZSK)
+	// Request DNSKEY Records for the signer name
+	keysMsg, err := d.authenticationQuery(zss.signature.SignerName, dns.TypeDNSKEY, ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := extractRecordsOfType[*dns.DNSKEY](keysMsg.Answer)
+
+	// Add matching Zone Signing Key (ZSK)
+	for _, key := range keys {
+		if zss.addKey(key, DNSKEY_ZSK) {
+			break
+		}
+	}
+	// When the answer is itself a DNSKEY set, it's signed by the Key Signing Key (KSK)
+	if zss.key == nil && zss.signature.TypeCovered == dns.TypeDNSKEY {
 		for _, key := range keys {
-			if zss.addKey(key, DNSKEY_ZSK) {
+			if zss.addKey(key, DNSKEY_KSK) {
 				break
 			}
 		}
-		if zss.key == nil {
-			return nil, fmt.Errorf("%s does not have a matching key", zss.signature.String())
-		}
+	}
+	if zss.key == nil {
+		return nil, fmt.Errorf("%s does not have a matching key", zss.signature.String())
+	}
 
-		// Verify the signature with the ZSK
-		err = zss.verify()
+	// Verify the signature with the ZSK
+	err = zss.verify()
 
-		if trace, ok := ctx.Value(contextTrace).(*Trace); ok {
-			trace.Add(
-				newTraceSignatureValidation(depth, msg.Question[0].Name, zss.signature.SignerName, "zsk", zss.key, zss.signature, zss.records, err),
-			)
+	if observer := observerFrom(ctx); observer != nil {
+		observer.OnSignatureValidation(
+			newTraceSignatureValidation(depth, msg.Question[0].Name, zss.signature.SignerName, "zsk", zss.key, zss.signature, zss.records, err),
+		)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify %s; received %s", zss.signature.String(), err.Error())
+	}
+
+	logger.Info().Str("flag", "zsk").
+		Str("zone", zss.signature.SignerName).
+		Str("key", tabsToSpaces(zss.key.String())).
+		Str("signature", tabsToSpaces(zss.signature.String())).
+		Msg("Signature verified with Zone Signing Key")
+
+	// Create signature sets from the DNSKEY response
+	keysSignatureSets, err := newSignatureSets(keysMsg.Answer)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kss := range keysSignatureSets {
+		// Add matching Key Signing Key (KSK)
+		for _, key := range keys {
+			if kss.addKey(key, DNSKEY_KSK) {
+				break
+			}
 		}
 
-		if err != nil {
-			return nil, fmt.Errorf("unable to verify %s; received %s", zss.signature.String(), err.Error())
+		if kss.key == nil {
+			return nil, fmt.Errorf("%s does not have a matching key", tabsToSpaces(kss.signature.String()))
 		}
 
-		logger.Info().Str("flag", "zsk").
-			Str("zone", zss.signature.SignerName).
-			Str("key", tabsToSpaces(zss.key.String())).
-			Str("signature", tabsToSpaces(zss.signature.String())).
-			Msg("Signature verified with Zone Signing Key")
+		// Verify the signature with the KSK
+		err = kss.verify()
+
+		if observer := observerFrom(ctx); observer != nil {
+			observer.OnSignatureValidation(
+				newTraceSignatureValidation(depth, msg.Question[0].Name, kss.signature.SignerName, "ksk", kss.key, kss.signature, kss.records, err),
+			)
+		}
 
-		// Create signature sets from the DNSKEY response
-		keysSignatureSets, err := newSignatureSets(keysMsg.Answer)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("unable to verify %s; received %s", tabsToSpaces(kss.signature.String()), err.Error())
 		}
 
-		for _, kss := range keysSignatureSets {
-			// Add matching Key Signing Key (KSK)
-			for _, key := range keys {
-				if kss.addKey(key, DNSKEY_KSK) {
-					break
-				}
-			}
+		logger.Info().Str("flag", "ksk").
+			Str("zone", kss.signature.SignerName).
+			Str("key", tabsToSpaces(kss.key.String())).
+			Str("signature", tabsToSpaces(kss.signature.String())).
+			Msg("Signature verified with Key Signing Key")
 
-			if kss.key == nil {
-				return nil, fmt.Errorf("%s does not have a matching key", tabsToSpaces(kss.signature.String()))
-			}
+		validKeysSignatureSets = append(validKeysSignatureSets, kss)
+	}
 
-			// Verify the signature with the KSK
-			err = kss.verify()
+	return validKeysSignatureSets, nil
+}
 
-			if trace, ok := ctx.Value(contextTrace).(*Trace); ok {
-				trace.Add(
-					newTraceSignatureValidation(depth, msg.Question[0].Name, kss.signature.SignerName, "ksk", kss.key, kss.signature, kss.records, err),
-				)
-			}
+// authenticationQuery performs a query needed whilst authenticating, reusing the response if the same
+// question has already been asked within the current Authenticate call, or if it's in the Cache. Responses from the
+// cache are verified along with the rest of the chain, as those from nameservers are.
+func (d *DnsLookup) authenticationQuery(name string, rrtype uint16, ctx context.Context) (*dns.Msg, error) {
+	queries, ok := ctx.Value(contextQueries).(*authenticationQueries)
+	if !ok {
+		msg, _, err := d.query(name, rrtype, ctx)
+		return msg, err
+	}
 
-			if err != nil {
-				return nil, fmt.Errorf("unable to verify %s; received %s", tabsToSpaces(kss.signature.String()), err.Error())
-			}
+	key := fmt.Sprintf("%s %d", strings.ToLower(dns.Fqdn(name)), rrtype)
 
-			logger.Info().Str("flag", "ksk").
-				Str("zone", kss.signature.SignerName).
-				Str("key", tabsToSpaces(kss.key.String())).
-				Str("signature", tabsToSpaces(kss.signature.String())).
-				Msg("Signature verified with Key Signing Key")
+	queries.mu.Lock()
+	result, found := queries.results[key]
+	if !found {
+		result = &authenticationQueryResult{name: dns.Fqdn(name), rrtype: rrtype, done: make(chan struct{})}
+		queries.results[key] = result
+	}
+	queries.mu.Unlock()
+
+	if found {
+		// Another signature set has already asked this question; wait for its answer.
+		select {
+		case <-result.done:
+			return result.msg, result.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 
-			allValidKeysSignatureSets = append(allValidKeysSignatureSets, kss)
+	if d.useCache(ctx) {
+		if msg, ok := d.Cache.Get(name, rrtype); ok && msg.Rcode == dns.RcodeSuccess && len(msg.Answer) > 0 {
+			result.msg, result.cached = msg, true
 		}
 	}
+	if !result.cached {
+		result.msg, _, result.err = d.query(name, rrtype, ctx)
+	}
+	close(result.done)
 
-	return allValidKeysSignatureSets, nil
+	return result.msg, result.err
 }
 
 // countLabels counts the number of labels in a domain name
@@ -1,6 +1,7 @@
 package lookup
 
 import (
+	"context"
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -40,7 +41,8 @@ func TestAuthenticateFailMaxDepth(t *testing.T) {
 	//---
 
 	_, err := d.QueryA("test.example.com")
-	assert.EqualError(t, err, "maximum authentication depth of 2 reached")
+	assert.ErrorIs(t, err, ErrDNSSECBogus)
+	assert.ErrorContains(t, err, "mock-nameserver: maximum authentication depth of 2 reached")
 }
 
 func TestAuthenticateSignatureExpired(t *testing.T) {
@@ -196,3 +198,37 @@ func TestAuthenticateSignatureKeyMissMatch(t *testing.T) {
 		"does not have a matching key",
 	)
 }
+
+func TestAuthenticateReusesZoneQueries(t *testing.T) {
+	ns := new(mockNameServer).buildFullChain().prepFullChain()
+
+	d := &DnsLookup{
+		nameservers:              []NameServer{ns},
+		maxAuthenticationDepth:   3,
+		RemotelyAuthenticateData: false,
+		LocallyAuthenticateData:  true,
+		RootDNSSECRecords:        []*dns.DS{ns.rootDS},
+	}
+
+	//---
+
+	// Two RRsets, both signed by example.com., so both need example.com.'s DNSKEY set.
+	txt, _ := dns.NewRR("test.example.com. 0 IN TXT \"hello\"")
+	inception := time.Now().Unix() - 60
+	expiration := time.Now().Unix() + 60
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("test.example.com.", dns.TypeANY)
+	msg.Answer = []dns.RR{
+		*ns.zoneExampleCom.a,
+		ns.zoneExampleCom.aRrsig,
+		txt,
+		ns.zoneExampleCom.rrsigZSK([]dns.RR{txt}, inception, expiration),
+	}
+
+	err := d.Authenticate(msg, context.Background())
+	assert.NoError(t, err)
+
+	// DNSKEY example.com., DS example.com., DNSKEY com., DS com., DNSKEY .
+	ns.AssertNumberOfCalls(t, "Query", 5)
+}
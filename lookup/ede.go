@@ -0,0 +1,80 @@
+package lookup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ExtendedDNSError wraps the error from a nameserver whose response included Extended DNS Errors (RFC 8914). These
+// give the reason a resolver failed the query, e.g. DNSSEC Bogus, Blocked or Network Error. Use errors.As to retrieve it.
+type ExtendedDNSError struct {
+	Errors []*dns.EDNS0_EDE // The extended errors, in the order the response gave them
+	Err    error            // The error the response was returned with
+}
+
+func (e *ExtendedDNSError) Error() string {
+	return fmt.Sprintf("%s (extended dns error: %s)", e.Err, strings.Join(extendedErrorStrings(e.Errors), "; "))
+}
+
+func (e *ExtendedDNSError) Unwrap() error {
+	return e.Err
+}
+
+// Has reports whether any of the extended errors has the info code, e.g. dns.ExtendedErrorCodeBlocked.
+func (e *ExtendedDNSError) Has(code uint16) bool {
+	for _, ede := range e.Errors {
+		if ede.InfoCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// withExtendedErrors wraps err in an ExtendedDNSError if the response included any extended errors.
+func withExtendedErrors(msg *dns.Msg, err error) error {
+	if errors := extendedErrors(msg); err != nil && len(errors) > 0 {
+		return &ExtendedDNSError{Errors: errors, Err: err}
+	}
+	return err
+}
+
+// extendedErrors returns the Extended DNS Error options from a message's OPT record.
+func extendedErrors(msg *dns.Msg) []*dns.EDNS0_EDE {
+	if msg == nil {
+		return nil
+	}
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+
+	var errors []*dns.EDNS0_EDE
+	for _, option := range opt.Option {
+		if ede, ok := option.(*dns.EDNS0_EDE); ok {
+			errors = append(errors, ede)
+		}
+	}
+	return errors
+}
+
+// extendedErrorStrings formats extended errors for errors and traces, e.g. "Blocked (15): blocked by policy".
+func extendedErrorStrings(errors []*dns.EDNS0_EDE) []string {
+	if len(errors) == 0 {
+		return nil
+	}
+
+	strs := make([]string, len(errors))
+	for i, ede := range errors {
+		name, ok := dns.ExtendedErrorCodeToString[ede.InfoCode]
+		if !ok {
+			name = "Unknown"
+		}
+		strs[i] = fmt.Sprintf("%s (%d)", name, ede.InfoCode)
+		if ede.ExtraText != "" {
+			strs[i] += ": " + ede.ExtraText
+		}
+	}
+	return strs
+}
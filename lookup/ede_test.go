@@ -0,0 +1,60 @@
+package lookup
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newExtendedErrorMsg creates a response with an rcode, and an Extended DNS Error.
+func newExtendedErrorMsg(rcode int, code uint16, text string) *dns.Msg {
+	msg := newLookupResponseMsgWithAD(rcode, rcode == dns.RcodeSuccess)
+	msg.SetEdns0(4096, true)
+	opt := msg.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_EDE{InfoCode: code, ExtraText: text})
+	return msg
+}
+
+func TestExtendedErrorStrings(t *testing.T) {
+	msg := newExtendedErrorMsg(dns.RcodeServerFailure, dns.ExtendedErrorCodeDNSBogus, "")
+	msg.IsEdns0().Option = append(msg.IsEdns0().Option, &dns.EDNS0_EDE{InfoCode: 9999, ExtraText: "custom"})
+
+	assert.Equal(t, []string{"DNSSEC Bogus (6)", "Unknown (9999): custom"}, extendedErrorStrings(extendedErrors(msg)))
+	assert.Nil(t, extendedErrors(nil))
+	assert.Nil(t, extendedErrorStrings(extendedErrors(newLookupResponseMsgWithAD(dns.RcodeSuccess, true))))
+}
+
+func TestDnsLookup_QueryExtendedError(t *testing.T) {
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = &MockDNSClient{response: newExtendedErrorMsg(dns.RcodeRefused, dns.ExtendedErrorCodeBlocked, "blocked by policy")}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.EnableTrace = true
+
+	_, _, err := d.Query("example.com.", dns.TypeA)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "example.com. A query to udp://192.0.2.1:53 returned REFUSED (extended dns error: Blocked (15): blocked by policy)")
+
+	var ede *ExtendedDNSError
+	require.True(t, errors.As(err, &ede))
+	assert.True(t, ede.Has(dns.ExtendedErrorCodeBlocked))
+	assert.False(t, ede.Has(dns.ExtendedErrorCodeDNSBogus))
+
+	require.Len(t, d.Trace.Records, 1)
+	record := d.Trace.Records[0].(TraceLookup)
+	assert.Equal(t, []string{"Blocked (15): blocked by policy"}, record.ExtendedErrors)
+	assert.ErrorAs(t, record.Err, &ede)
+
+	// Extended errors on a successful response, such as a stale answer, are only traced.
+	ns.client = &MockDNSClient{response: newExtendedErrorMsg(dns.RcodeSuccess, dns.ExtendedErrorCodeStaleAnswer, "")}
+	d.Trace = nil
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	record = d.Trace.Records[0].(TraceLookup)
+	assert.Equal(t, []string{"Stale Answer (3)"}, record.ExtendedErrors)
+	assert.NoError(t, record.Err)
+}
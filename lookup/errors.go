@@ -0,0 +1,123 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Errors that a query's error can be checked against with errors.Is. A *QueryError, retrieved with errors.As, holds
+// the name, type and nameserver of the query that failed.
+var (
+	ErrNXDomain    = errors.New("name does not exist")            // A nameserver responded with NXDOMAIN
+	ErrServFail    = errors.New("server failure")                 // A nameserver responded with SERVFAIL
+	ErrTimeout     = errors.New("query timed out")                // A nameserver didn't respond in time
+	ErrNoAnswer    = errors.New("no answer found")                // None of the nameservers gave a usable answer
+	ErrDNSSECBogus = errors.New("dnssec authentication failed")   // The answer couldn't be authenticated
+	ErrNoData      = errors.New("no records of the type queried") // The answer was an empty, NODATA, response
+)
+
+// QueryError is returned when a query to a nameserver fails, either with an rcode other than NOERROR, or with no
+// response at all.
+type QueryError struct {
+	Name       string // The name queried
+	Rrtype     uint16 // The record type queried
+	Nameserver string // The nameserver queried
+	Rcode      int    // The rcode of the response, or -1 if there wasn't one
+	Err        error  // Why there was no response; nil if there was one
+
+	response *dns.Msg // The response, when it was NXDOMAIN, so it can be cached
+}
+
+func (e *QueryError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s query to %s failed: %s", e.Name, rrtypeToString(e.Rrtype), e.Nameserver, e.Err)
+	}
+	rcode, ok := dns.RcodeToString[e.Rcode]
+	if !ok {
+		rcode = fmt.Sprintf("RCODE%d", e.Rcode)
+	}
+	return fmt.Sprintf("%s %s query to %s returned %s", e.Name, rrtypeToString(e.Rrtype), e.Nameserver, rcode)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error matches ErrNXDomain, ErrServFail or ErrTimeout.
+func (e *QueryError) Is(target error) bool {
+	switch target {
+	case ErrNXDomain:
+		return e.Rcode == dns.RcodeNameError
+	case ErrServFail:
+		return e.Rcode == dns.RcodeServerFailure
+	case ErrTimeout:
+		return isTimeout(e.Err)
+	}
+	return false
+}
+
+// newRcodeError returns the error for a response with an rcode other than NOERROR.
+func newRcodeError(name string, rrtype uint16, nameserver string, rcode int) *QueryError {
+	return &QueryError{Name: dns.Fqdn(name), Rrtype: rrtype, Nameserver: nameserver, Rcode: rcode}
+}
+
+// asQueryError wraps an error from a nameserver in a QueryError, unless it already holds one.
+func asQueryError(name string, rrtype uint16, nameserver string, err error) error {
+	var queryErr *QueryError
+	if err == nil || errors.As(err, &queryErr) {
+		return err
+	}
+	return &QueryError{Name: dns.Fqdn(name), Rrtype: rrtype, Nameserver: nameserver, Rcode: -1, Err: err}
+}
+
+// nxdomainResponse returns an NXDOMAIN response held by any QueryError within err, or nil if there's none. A query
+// that failed on every nameserver joins their errors, so each is checked.
+func nxdomainResponse(err error) *dns.Msg {
+	switch e := err.(type) {
+	case *QueryError:
+		return e.response
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			if msg := nxdomainResponse(err); msg != nil {
+				return msg
+			}
+		}
+	case interface{ Unwrap() error }:
+		return nxdomainResponse(e.Unwrap())
+	}
+	return nil
+}
+
+// isTimeout checks if an error is from a network timeout, or a context deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// bogusError marks an error from authenticating an answer as matching ErrDNSSECBogus. If the answer was a NODATA
+// response, which can't be authenticated locally, it matches ErrNoData too.
+type bogusError struct {
+	err    error
+	noData bool
+}
+
+func (e *bogusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *bogusError) Unwrap() error {
+	return e.err
+}
+
+func (e *bogusError) Is(target error) bool {
+	return target == ErrDNSSECBogus || (e.noData && target == ErrNoData)
+}
+
+// isNoData checks if a response is NODATA: NOERROR, with no records in its answer section.
+func isNoData(msg *dns.Msg) bool {
+	return msg != nil && msg.Rcode == dns.RcodeSuccess && len(msg.Answer) == 0
+}
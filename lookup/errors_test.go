@@ -0,0 +1,75 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryError_Is(t *testing.T) {
+	nxdomain := newRcodeError("example.com", dns.TypeA, "mock", dns.RcodeNameError)
+	assert.ErrorIs(t, nxdomain, ErrNXDomain)
+	assert.NotErrorIs(t, nxdomain, ErrServFail)
+	assert.NotErrorIs(t, nxdomain, ErrTimeout)
+	assert.Equal(t, "example.com.", nxdomain.Name)
+	assert.EqualError(t, nxdomain, "example.com. A query to mock returned NXDOMAIN")
+	assert.EqualError(t, newRcodeError("example.com.", 65280, "mock", 3841), "example.com. TYPE65280 query to mock returned RCODE3841")
+
+	assert.ErrorIs(t, newRcodeError("example.com.", dns.TypeA, "mock", dns.RcodeServerFailure), ErrServFail)
+
+	timeout := asQueryError("example.com.", dns.TypeA, "mock", context.DeadlineExceeded)
+	assert.ErrorIs(t, timeout, ErrTimeout)
+	assert.ErrorIs(t, timeout, context.DeadlineExceeded)
+	assert.NotErrorIs(t, timeout, ErrNXDomain)
+	assert.EqualError(t, timeout, "example.com. A query to mock failed: context deadline exceeded")
+
+	// An error already holding a QueryError isn't wrapped again.
+	wrapped := &ExtendedDNSError{Err: nxdomain}
+	assert.Same(t, error(wrapped), asQueryError("example.com.", dns.TypeA, "other", wrapped))
+}
+
+func TestDnsLookup_QueryErrors(t *testing.T) {
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeNameError, true)}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	_, _, err := d.Query("missing.example.com", dns.TypeA)
+	assert.ErrorIs(t, err, ErrNXDomain)
+	assert.ErrorIs(t, err, ErrNoAnswer)
+
+	var queryErr *QueryError
+	require.True(t, errors.As(err, &queryErr))
+	assert.Equal(t, "missing.example.com.", queryErr.Name)
+	assert.Equal(t, dns.TypeA, queryErr.Rrtype)
+	assert.Equal(t, ns.String(), queryErr.Nameserver)
+	assert.Equal(t, dns.RcodeNameError, queryErr.Rcode)
+
+	// A response without the AD bit is bogus.
+	ns.client = &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, false)}
+	_, _, err = d.Query("example.com", dns.TypeA)
+	assert.ErrorIs(t, err, ErrDNSSECBogus)
+	assert.NotErrorIs(t, err, ErrNXDomain)
+
+	// As is one that fails local authentication, here for want of signatures.
+	ns.client = &MockDNSClient{response: newLookupResponseMsgWithAD(dns.RcodeSuccess, true)}
+	d.LocallyAuthenticateData = true
+	_, _, err = d.Query("example.com", dns.TypeA)
+	assert.ErrorIs(t, err, ErrDNSSECBogus)
+
+	// And a nameserver that doesn't respond within the retry policy's timeout is a timeout.
+	slow := &delayedNameServer{name: "slow", delay: time.Second, response: newLookupResponseMsgWithAD(dns.RcodeSuccess, true)}
+	d = NewDnsLookup([]NameServer{slow})
+	d.RetryPolicy = &RetryPolicy{Attempts: 1, Timeout: 10 * time.Millisecond}
+	_, _, err = d.Query("example.com", dns.TypeA)
+	assert.ErrorIs(t, err, ErrTimeout)
+	require.True(t, errors.As(err, &queryErr))
+	assert.Equal(t, "slow", queryErr.Nameserver)
+	assert.Equal(t, -1, queryErr.Rcode)
+}
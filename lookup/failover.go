@@ -0,0 +1,43 @@
+package lookup
+
+import (
+	"errors"
+)
+
+// FailoverAction is what's done after a query to a nameserver fails.
+type FailoverAction uint8
+
+const (
+	FailoverNext FailoverAction = iota // Try the next nameserver
+	FailoverStop                       // Return the failure straight away, without trying the other nameservers
+)
+
+// FailoverPolicy sets which failures from a nameserver move a query on to the next nameserver, and which end it. For
+// example, NXDOMAIN from one resolver will usually be the same from the others, so there's little point asking them:
+//
+//	client.FailoverPolicy = &lookup.FailoverPolicy{Rcodes: map[int]lookup.FailoverAction{dns.RcodeNameError: lookup.FailoverStop}}
+//
+// The zero value, like a nil policy, tries the next nameserver after any failure. Whatever the policy, answers that
+// fail authentication, either locally or because the nameserver didn't set the AD flag, move the query on to the next
+// nameserver, as do responses rejected by Hardening. This is the same whether nameservers are queried in turn, or
+// fanned out with FanOut.
+type FailoverPolicy struct {
+	NoResponse FailoverAction         // When the nameserver didn't respond, e.g. it timed out
+	Rcodes     map[int]FailoverAction // By the rcode of the response, e.g. dns.RcodeServerFailure
+	Default    FailoverAction         // For rcodes not in Rcodes
+}
+
+// action returns what to do after a query to a nameserver failed with err.
+func (p *FailoverPolicy) action(err error) FailoverAction {
+	var queryErr *QueryError
+	if p == nil || !errors.As(err, &queryErr) {
+		return FailoverNext
+	}
+	if queryErr.Rcode < 0 {
+		return p.NoResponse
+	}
+	if action, ok := p.Rcodes[queryErr.Rcode]; ok {
+		return action
+	}
+	return p.Default
+}
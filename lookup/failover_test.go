@@ -0,0 +1,126 @@
+package lookup
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverPolicy_Action(t *testing.T) {
+	policy := &FailoverPolicy{
+		NoResponse: FailoverStop,
+		Rcodes:     map[int]FailoverAction{dns.RcodeNameError: FailoverStop},
+	}
+
+	assert.Equal(t, FailoverStop, policy.action(newRcodeError("example.com.", dns.TypeA, "mock", dns.RcodeNameError)))
+	assert.Equal(t, FailoverNext, policy.action(newRcodeError("example.com.", dns.TypeA, "mock", dns.RcodeServerFailure)))
+	assert.Equal(t, FailoverStop, policy.action(asQueryError("example.com.", dns.TypeA, "mock", errors.New("timeout"))))
+	assert.Equal(t, FailoverNext, policy.action(errResolverAuthentication))
+
+	policy.Default = FailoverStop
+	assert.Equal(t, FailoverStop, policy.action(newRcodeError("example.com.", dns.TypeA, "mock", dns.RcodeRefused)))
+
+	var none *FailoverPolicy
+	assert.Equal(t, FailoverNext, none.action(newRcodeError("example.com.", dns.TypeA, "mock", dns.RcodeNameError)))
+}
+
+func TestDnsLookup_QueryFailoverPolicy(t *testing.T) {
+	nxdomain := newLookupResponseMsgWithAD(dns.RcodeNameError, true)
+	servfail := newLookupResponseMsgWithAD(dns.RcodeServerFailure, true)
+
+	first := &namedMockNameServer{name: "first"}
+	first.On("Query", "missing.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("missing.example.com.", dns.TypeA, "first", dns.RcodeNameError))
+	first.On("Query", "broken.example.com.", dns.TypeA).Return(servfail, time.Millisecond, newRcodeError("broken.example.com.", dns.TypeA, "first", dns.RcodeServerFailure))
+	second := &namedMockNameServer{name: "second"}
+	second.On("Query", "broken.example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "broken.example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+
+	d := &DnsLookup{
+		nameservers:    []NameServer{first, second},
+		FailoverPolicy: &FailoverPolicy{Rcodes: map[int]FailoverAction{dns.RcodeNameError: FailoverStop}},
+	}
+
+	// NXDOMAIN isn't asked of the second nameserver.
+	_, _, err := d.Query("missing.example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, ErrNXDomain)
+	assert.NotErrorIs(t, err, ErrNoAnswer)
+	assert.ErrorContains(t, err, "first: missing.example.com. A query to first returned NXDOMAIN")
+	second.AssertNotCalled(t, "Query", "missing.example.com.", dns.TypeA)
+
+	// SERVFAIL still is.
+	msg, _, err := d.Query("broken.example.com.", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Len(t, msg.Answer, 1)
+
+	// As is NXDOMAIN, without a policy.
+	d.FailoverPolicy = nil
+	second.On("Query", "missing.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("missing.example.com.", dns.TypeA, "second", dns.RcodeNameError))
+	_, _, err = d.Query("missing.example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, ErrNoAnswer)
+	second.AssertCalled(t, "Query", "missing.example.com.", dns.TypeA)
+}
+
+func TestDnsLookup_QueryFailoverOnAuthenticationFailure(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	// Policies don't apply to authentication failures, so even stopping on every failure still fails over.
+	stop := &FailoverPolicy{NoResponse: FailoverStop, Default: FailoverStop}
+
+	for _, fanOut := range []int{0, FanOutAll} {
+		t.Run(fmt.Sprintf("resolver authentication, fan out %d", fanOut), func(t *testing.T) {
+			unauthenticated := newAnswerMsg(t, "example.com. 300 IN A 192.0.2.1")
+			unauthenticated.AuthenticatedData = false
+
+			first := &namedMockNameServer{name: "first"}
+			first.On("Query", "example.com.", dns.TypeA).Return(unauthenticated, time.Millisecond, nil)
+			second := &namedMockNameServer{name: "second"}
+			second.On("Query", "example.com.", dns.TypeA).Return(
+				newAnswerMsg(t, "example.com. 300 IN A 192.0.2.2"), 10*time.Millisecond, nil)
+
+			d := &DnsLookup{
+				nameservers:              []NameServer{first, second},
+				RemotelyAuthenticateData: true,
+				FanOut:                   fanOut,
+				FailoverPolicy:           stop,
+			}
+
+			msg, _, err := d.Query("example.com.", dns.TypeA)
+			require.NoError(t, err)
+			assert.Equal(t, "192.0.2.2", msg.Answer[0].(*dns.A).A.String())
+		})
+
+		t.Run(fmt.Sprintf("local authentication, fan out %d", fanOut), func(t *testing.T) {
+			// The first nameserver's A answer is unsigned; its other answers, fetched whilst authenticating, are fine.
+			signed := NewUdpNameserver(server.Address, server.Port)
+			unsigned := &delayedNameServer{name: "unsigned", response: newAnswerMsg(t, "test.example.com. 300 IN A 192.0.2.1"), next: signed}
+
+			d := NewDnsLookup([]NameServer{unsigned, signed})
+			d.RandomNameserver = false
+			d.RemotelyAuthenticateData = false
+			d.RootDNSSECRecords = zones[0].TrustAnchors()
+			d.FanOut = fanOut
+			d.FailoverPolicy = stop
+
+			result := d.QueryResult("test.example.com.", dns.TypeA)
+			require.NoError(t, result.Err)
+			assert.Equal(t, ValidationSecure, result.Validation)
+			assert.Equal(t, signed.String(), result.Nameserver)
+		})
+	}
+
+	// When every answer fails authentication, the failures are returned.
+	unsigned := &namedMockNameServer{name: "unsigned"}
+	unsigned.On("Query", "test.example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "test.example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+	d := NewDnsLookup([]NameServer{unsigned})
+	d.RemotelyAuthenticateData = false
+	_, _, err := d.Query("test.example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, ErrDNSSECBogus)
+	assert.ErrorIs(t, err, ErrNoAnswer)
+}
@@ -0,0 +1,43 @@
+package lookup
+
+import (
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// Flags holds the header flags, and response code, of a DNS response.
+type Flags struct {
+	Authoritative      bool   // AA: the answer came from a nameserver authoritative for the zone
+	Truncated          bool   // TC: the response was truncated, and should be retried over TCP
+	RecursionDesired   bool   // RD: recursion was requested
+	RecursionAvailable bool   // RA: the nameserver supports recursion
+	AuthenticatedData  bool   // AD: the nameserver validated the answer with DNSSEC
+	CheckingDisabled   bool   // CD: the nameserver was asked not to validate the answer
+	Rcode              int    // The response code, e.g. dns.RcodeNameError
+	RcodeName          string // The response code's name, e.g. NXDOMAIN
+}
+
+// NewFlags returns the flags of a DNS response. When a response is unpacked, extended response codes carried in its
+// EDNS(0) OPT record are already merged into its Rcode.
+func NewFlags(msg *dns.Msg) Flags {
+	if msg == nil {
+		return Flags{}
+	}
+
+	name, ok := dns.RcodeToString[msg.Rcode]
+	if !ok {
+		name = "RCODE" + strconv.Itoa(msg.Rcode)
+	}
+
+	return Flags{
+		Authoritative:      msg.Authoritative,
+		Truncated:          msg.Truncated,
+		RecursionDesired:   msg.RecursionDesired,
+		RecursionAvailable: msg.RecursionAvailable,
+		AuthenticatedData:  msg.AuthenticatedData,
+		CheckingDisabled:   msg.CheckingDisabled,
+		Rcode:              msg.Rcode,
+		RcodeName:          name,
+	}
+}
@@ -0,0 +1,41 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFlags(t *testing.T) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeA)
+	msg.Response = true
+	msg.Truncated = true
+	msg.RecursionAvailable = true
+	msg.AuthenticatedData = true
+	msg.Rcode = dns.RcodeNameError
+
+	assert.Equal(t, Flags{
+		Truncated:          true,
+		RecursionDesired:   true,
+		RecursionAvailable: true,
+		AuthenticatedData:  true,
+		Rcode:              dns.RcodeNameError,
+		RcodeName:          "NXDOMAIN",
+	}, NewFlags(msg))
+
+	// Extended response codes are carried in the OPT record, and merged when unpacked.
+	msg.SetEdns0(4096, true)
+	msg.Rcode = dns.RcodeBadCookie
+	packed, err := msg.Pack()
+	assert.NoError(t, err)
+	unpacked := new(dns.Msg)
+	assert.NoError(t, unpacked.Unpack(packed))
+	assert.Equal(t, "BADCOOKIE", NewFlags(unpacked).RcodeName)
+
+	msg.Rcode = 3000
+	assert.Equal(t, "RCODE3000", NewFlags(msg).RcodeName)
+
+	assert.Equal(t, Flags{}, NewFlags(nil))
+}
@@ -0,0 +1,90 @@
+package lookup
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// HardeningLimits bound what's accepted in a response when hardened parsing is enabled, for use when the nameservers,
+// or the authoritative servers behind them, can't be trusted to send well-formed responses.
+type HardeningLimits struct {
+	MaxRecords    int // The most records accepted across all sections of a response
+	MaxRdataSize  int // The largest RDATA accepted for any record, in octets
+	MaxTXTSize    int // The largest total size of a TXT record's strings, in octets
+	MaxLabels     int // The most labels accepted in an owner name
+	MaxSignatures int // The most RRSIGs accepted in a response, bounding the signature verification work it can cause
+}
+
+// DefaultHardeningLimits are generous enough for real-world responses, including large DNSKEY sets and the 34 label
+// names used for IPv6 reverse lookups.
+var DefaultHardeningLimits = HardeningLimits{
+	MaxRecords:    256,
+	MaxRdataSize:  4096,
+	MaxTXTSize:    4096,
+	MaxLabels:     64,
+	MaxSignatures: 32,
+}
+
+// harden re-packs a response and strictly parses it again, so only what survives a round trip through the wire
+// format is used, then checks it against the limits. Records of unknown types are rejected, as they can't be
+// parsed strictly.
+func harden(msg *dns.Msg, limits *HardeningLimits) (*dns.Msg, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("hardened parsing: unable to re-pack response: %w", err)
+	}
+
+	parsed := new(dns.Msg)
+	if err = parsed.Unpack(packed); err != nil {
+		return nil, fmt.Errorf("hardened parsing: unable to re-parse response: %w", err)
+	}
+
+	sections := [][]dns.RR{parsed.Answer, parsed.Ns, parsed.Extra}
+
+	records, signatures := 0, 0
+	for _, section := range sections {
+		for _, rr := range section {
+			records++
+
+			if _, ok := rr.(*dns.RFC3597); ok {
+				return nil, fmt.Errorf("hardened parsing: record of unknown type %d for %s", rr.Header().Rrtype, rr.Header().Name)
+			}
+
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+
+			if labels := dns.CountLabel(rr.Header().Name); limits.MaxLabels > 0 && labels > limits.MaxLabels {
+				return nil, fmt.Errorf("hardened parsing: %s has %d labels, over the limit of %d", rr.Header().Name, labels, limits.MaxLabels)
+			}
+
+			if size := int(rr.Header().Rdlength); limits.MaxRdataSize > 0 && size > limits.MaxRdataSize {
+				return nil, fmt.Errorf("hardened parsing: %s %s record has %d octets of RDATA, over the limit of %d",
+					rr.Header().Name, rrtypeToString(rr.Header().Rrtype), size, limits.MaxRdataSize)
+			}
+
+			switch x := rr.(type) {
+			case *dns.TXT:
+				size := 0
+				for _, s := range x.Txt {
+					size += len(s)
+				}
+				if limits.MaxTXTSize > 0 && size > limits.MaxTXTSize {
+					return nil, fmt.Errorf("hardened parsing: %s TXT record is %d octets, over the limit of %d", x.Hdr.Name, size, limits.MaxTXTSize)
+				}
+			case *dns.RRSIG:
+				signatures++
+			}
+		}
+	}
+
+	if limits.MaxRecords > 0 && records > limits.MaxRecords {
+		return nil, fmt.Errorf("hardened parsing: response has %d records, over the limit of %d", records, limits.MaxRecords)
+	}
+	if limits.MaxSignatures > 0 && signatures > limits.MaxSignatures {
+		return nil, fmt.Errorf("hardened parsing: response has %d RRSIGs, over the limit of %d", signatures, limits.MaxSignatures)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,69 @@
+package lookup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarden(t *testing.T) {
+	limits := &HardeningLimits{MaxRecords: 3, MaxRdataSize: 100, MaxTXTSize: 10, MaxLabels: 4, MaxSignatures: 1}
+
+	tests := []struct {
+		name    string
+		records []string
+		err     string
+	}{
+		{"valid", []string{"example.com. 300 IN A 192.0.2.1", `example.com. 300 IN TXT "short"`}, ""},
+		{"unknown type", []string{`example.com. 300 IN TYPE65400 \# 2 abcd`}, "record of unknown type 65400"},
+		{"too many records", strings.Split(strings.Repeat("example.com. 300 IN A 192.0.2.1,", 4), ",")[:4], "4 records, over the limit of 3"},
+		{"oversized rdata", []string{"example.com. 300 IN NULL \\# 101 " + strings.Repeat("00", 101)}, "101 octets of RDATA, over the limit of 100"},
+		{"large txt", []string{`example.com. 300 IN TXT "abcdef" "ghijkl"`}, "TXT record is 12 octets, over the limit of 10"},
+		{"too many labels", []string{"a.b.c.example.com. 300 IN A 192.0.2.1"}, "has 5 labels, over the limit of 4"},
+		{"too many signatures", []string{
+			"example.com. 300 IN RRSIG A 13 2 300 20300101000000 20200101000000 1 example.com. AAAA",
+			"example.com. 300 IN RRSIG A 13 2 300 20300101000000 20200101000000 2 example.com. AAAA",
+		}, "2 RRSIGs, over the limit of 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newAnswerMsg(t, tt.records...)
+			parsed, err := harden(msg, limits)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotSame(t, msg, parsed)
+			assert.Len(t, parsed.Answer, len(tt.records))
+		})
+	}
+}
+
+func TestQueryHardened(t *testing.T) {
+	hostile := &namedMockNameServer{name: "hostile"}
+	hostile.On("Query", "example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, `example.com. 300 IN TYPE65400 \# 2 abcd`), time.Millisecond, nil)
+	good := &namedMockNameServer{name: "good"}
+	good.On("Query", "example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{hostile, good}}
+
+	msg, _, err := d.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Len(t, msg.Answer, 1)
+	good.AssertNotCalled(t, "Query", "example.com.", dns.TypeA)
+
+	d.Hardening = &DefaultHardeningLimits
+
+	msg, _, err = d.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.IsType(t, &dns.A{}, msg.Answer[0])
+	good.AssertNumberOfCalls(t, "Query", 1)
+}
@@ -0,0 +1,124 @@
+package lookup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NameServerHealth describes what has been learnt about a nameserver from the responses it has returned.
+type NameServerHealth struct {
+	Nameserver   string
+	StripsDNSSEC bool      // The nameserver returned a response without the DNSSEC records that were requested
+	Reason       string    // Why the nameserver was flagged as stripping DNSSEC records
+	Checked      time.Time // When the nameserver's DNSSEC support was last determined
+}
+
+// nameserverHealth tracks the health of each nameserver, keyed on the nameserver's String().
+type nameserverHealth struct {
+	mu      sync.Mutex
+	entries map[string]NameServerHealth
+}
+
+func (h *nameserverHealth) set(nameserver string, stripsDNSSEC bool, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.entries == nil {
+		h.entries = make(map[string]NameServerHealth)
+	}
+	h.entries[nameserver] = NameServerHealth{
+		Nameserver:   nameserver,
+		StripsDNSSEC: stripsDNSSEC,
+		Reason:       reason,
+		Checked:      time.Now(),
+	}
+}
+
+func (h *nameserverHealth) stripsDNSSEC(nameserver string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.entries[nameserver].StripsDNSSEC
+}
+
+//-----
+
+// NameserverHealth returns the health status of each configured nameserver. Nameservers that have not yet
+// returned a response are reported as healthy, with a zero Checked time.
+func (d *DnsLookup) NameserverHealth() []NameServerHealth {
+	d.health.mu.Lock()
+	defer d.health.mu.Unlock()
+
+	results := make([]NameServerHealth, len(d.nameservers))
+	for i, nameserver := range d.nameservers {
+		if entry, ok := d.health.entries[nameserver.String()]; ok {
+			results[i] = entry
+		} else {
+			results[i] = NameServerHealth{Nameserver: nameserver.String()}
+		}
+	}
+	return results
+}
+
+// ProbeDNSSEC queries every configured nameserver for the root DNSKEY RRset, which is always signed, and records
+// whether each one returns the RRSIG records for it. The updated health status is returned.
+func (d *DnsLookup) ProbeDNSSEC() []NameServerHealth {
+	logger := d.componentLogger(LogComponentQuery)
+
+	for _, nameserver := range d.nameservers {
+		result, _, err := nameserver.Query(".", dns.TypeDNSKEY)
+		if err != nil {
+			// Failing to answer says nothing about DNSSEC support, so the previous status is kept.
+			logger.Warn().Str("nameserver", nameserver.String()).Err(err).Msg("Unable to probe nameserver for DNSSEC support")
+			continue
+		}
+
+		reason := dnssecStrippedReason(result)
+		if reason == "" && len(extractRecordsOfType[*dns.RRSIG](result.Answer)) == 0 {
+			reason = "no RRSIG records returned for the root DNSKEY RRset"
+		}
+		d.recordDNSSECSupport(nameserver.String(), reason)
+	}
+
+	return d.NameserverHealth()
+}
+
+// recordDNSSECSupport updates a nameserver's health status. An empty reason marks the nameserver as returning
+// DNSSEC records.
+func (d *DnsLookup) recordDNSSECSupport(nameserver, reason string) {
+	if reason != "" && !d.health.stripsDNSSEC(nameserver) {
+		logger := d.componentLogger(LogComponentQuery)
+		logger.Warn().Str("nameserver", nameserver).Str("reason", reason).
+			Msg("Nameserver appears to strip DNSSEC records")
+	}
+	d.health.set(nameserver, reason != "", reason)
+}
+
+// dnssecStrippedReason checks a response for signs that the DNSSEC records were stripped. A resolver that
+// supports DNSSEC copies the DO bit from the query into its response (RFC 3225, section 3).
+func dnssecStrippedReason(msg *dns.Msg) string {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return "response contained no EDNS(0) OPT record"
+	}
+	if !opt.Do() {
+		return "response had the DO bit cleared"
+	}
+	return ""
+}
+
+// excludeDNSSECStripping removes the nameservers known to strip DNSSEC records. An error is returned if that
+// leaves none.
+func (d *DnsLookup) excludeDNSSECStripping(nameservers []NameServer) ([]NameServer, error) {
+	results := make([]NameServer, 0, len(nameservers))
+	for _, nameserver := range nameservers {
+		if !d.health.stripsDNSSEC(nameserver.String()) {
+			results = append(results, nameserver)
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("all configured nameservers strip DNSSEC records, so answers cannot be validated locally")
+	}
+	return results, nil
+}
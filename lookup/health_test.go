@@ -0,0 +1,93 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRootDNSKEYResponse returns a root DNSKEY response, optionally with an RRSIG and the DO bit set.
+func newRootDNSKEYResponse(signed bool, do bool) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(".", dns.TypeDNSKEY)
+	msg.Response = true
+	msg.Answer = []dns.RR{&dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: ".", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+		PublicKey: "AwEAAQ==",
+	}}
+	if signed {
+		msg.Answer = append(msg.Answer, &dns.RRSIG{
+			Hdr:         dns.RR_Header{Name: ".", Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+			TypeCovered: dns.TypeDNSKEY,
+		})
+	}
+	msg.SetEdns0(4096, do)
+	return msg
+}
+
+func TestProbeDNSSEC(t *testing.T) {
+	good := &namedMockNameServer{name: "good"}
+	good.On("Query", ".", dns.TypeDNSKEY).Return(newRootDNSKEYResponse(true, true), time.Millisecond, nil)
+
+	noRRSIG := &namedMockNameServer{name: "no-rrsig"}
+	noRRSIG.On("Query", ".", dns.TypeDNSKEY).Return(newRootDNSKEYResponse(false, true), time.Millisecond, nil)
+
+	noDO := &namedMockNameServer{name: "no-do"}
+	noDO.On("Query", ".", dns.TypeDNSKEY).Return(newRootDNSKEYResponse(true, false), time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{good, noRRSIG, noDO})
+
+	health := d.ProbeDNSSEC()
+	require.Len(t, health, 3)
+
+	assert.Equal(t, "good", health[0].Nameserver)
+	assert.False(t, health[0].StripsDNSSEC)
+	assert.False(t, health[0].Checked.IsZero())
+
+	assert.True(t, health[1].StripsDNSSEC)
+	assert.Contains(t, health[1].Reason, "no RRSIG records")
+
+	assert.True(t, health[2].StripsDNSSEC)
+	assert.Contains(t, health[2].Reason, "DO bit cleared")
+}
+
+func TestQueryDetectsDNSSECStripping(t *testing.T) {
+	stripping := &namedMockNameServer{name: "stripping"}
+	stripping.On("Query", "example.com.", dns.TypeA).Return(newLookupResponseMsgWithAD(dns.RcodeSuccess, false), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{stripping}}
+
+	assert.False(t, d.NameserverHealth()[0].StripsDNSSEC)
+
+	_, _, err := d.Query("example.com.", dns.TypeA)
+	assert.NoError(t, err)
+
+	health := d.NameserverHealth()[0]
+	assert.True(t, health.StripsDNSSEC)
+	assert.Contains(t, health.Reason, "no EDNS(0) OPT record")
+}
+
+func TestQueryExcludesDNSSECStripping(t *testing.T) {
+	stripping := &namedMockNameServer{name: "stripping"}
+	good := &namedMockNameServer{name: "good"}
+	good.On("Query", "example.com.", dns.TypeA).Return(newLookupResponseMsgWithAD(dns.RcodeSuccess, false), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{stripping, good}, LocallyAuthenticateData: true, ExcludeDNSSECStripping: true}
+	d.recordDNSSECSupport("stripping", "response had the DO bit cleared")
+
+	nameservers, err := d.excludeDNSSECStripping(d.getNameservers())
+	require.NoError(t, err)
+	assert.Equal(t, []NameServer{good}, nameservers)
+
+	d.recordDNSSECSupport("good", "response had the DO bit cleared")
+
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "all configured nameservers strip DNSSEC records")
+	stripping.AssertNotCalled(t, "Query", "example.com.", dns.TypeA)
+}
@@ -0,0 +1,147 @@
+package lookup
+
+import (
+	"bufio"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultHostsPath is the hosts file read by NewHosts when no path is given.
+const DefaultHostsPath = "/etc/hosts"
+
+// hostsRecheckInterval is how often the hosts file is checked for changes, at most.
+const hostsRecheckInterval = 5 * time.Second
+
+// Hosts answers A, AAAA and PTR queries from a hosts file, so local overrides take effect as they do with the system
+// resolver. The file is re-read when it changes.
+type Hosts struct {
+	path string
+
+	mu      sync.Mutex
+	names   map[string][]netip.Addr // Addresses by lowercase, fully qualified name
+	reverse map[string][]string     // Names by the address's reverse name, e.g. 1.2.0.192.in-addr.arpa.
+	modTime time.Time
+	checked time.Time
+	now     func() time.Time
+}
+
+// NewHosts returns Hosts that reads the hosts file at path, or DefaultHostsPath if path is empty. A file that doesn't
+// exist, or can't be read, is treated as empty.
+func NewHosts(path string) *Hosts {
+	if path == "" {
+		path = DefaultHostsPath
+	}
+	return &Hosts{path: path, now: time.Now}
+}
+
+// lookup answers a query from the hosts file. It returns false if the file has no records of rrtype for the name.
+// Answers have a TTL of zero, and aren't authenticated.
+func (h *Hosts) lookup(name string, rrtype uint16) (*dns.Msg, bool) {
+	if rrtype != dns.TypeA && rrtype != dns.TypeAAAA && rrtype != dns.TypePTR {
+		return nil, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reload()
+
+	name = dns.Fqdn(name)
+	key := strings.ToLower(name)
+	header := dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET}
+
+	var answer []dns.RR
+	switch rrtype {
+	case dns.TypeA:
+		for _, addr := range h.names[key] {
+			if addr.Is4() {
+				answer = append(answer, &dns.A{Hdr: header, A: addr.AsSlice()})
+			}
+		}
+	case dns.TypeAAAA:
+		for _, addr := range h.names[key] {
+			if addr.Is6() {
+				answer = append(answer, &dns.AAAA{Hdr: header, AAAA: addr.AsSlice()})
+			}
+		}
+	case dns.TypePTR:
+		for _, target := range h.reverse[key] {
+			answer = append(answer, &dns.PTR{Hdr: header, Ptr: target})
+		}
+	}
+	if len(answer) == 0 {
+		return nil, false
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, rrtype)
+	msg.Response = true
+	msg.Answer = answer
+	return msg, true
+}
+
+// reload re-reads the hosts file if it's changed since it was last read. It's checked at most every
+// hostsRecheckInterval. h.mu must be held.
+func (h *Hosts) reload() {
+	now := h.now()
+	if !h.checked.IsZero() && now.Sub(h.checked) < hostsRecheckInterval {
+		return
+	}
+	h.checked = now
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		h.names, h.reverse, h.modTime = nil, nil, time.Time{}
+		return
+	}
+	if h.names != nil && info.ModTime().Equal(h.modTime) {
+		return
+	}
+
+	file, err := os.Open(h.path)
+	if err != nil {
+		h.names, h.reverse, h.modTime = nil, nil, time.Time{}
+		return
+	}
+	defer file.Close()
+
+	names := make(map[string][]netip.Addr)
+	reverse := make(map[string][]string)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap().WithZone("")
+		reverseName, err := dns.ReverseAddr(addr.String())
+		if err != nil {
+			continue
+		}
+
+		for _, host := range fields[1:] {
+			if _, ok := dns.IsDomainName(host); !ok {
+				continue
+			}
+			host = dns.Fqdn(strings.ToLower(host))
+			names[host] = append(names[host], addr)
+			reverse[reverseName] = append(reverse[reverseName], host)
+		}
+	}
+
+	h.names, h.reverse, h.modTime = names, reverse, info.ModTime()
+}
@@ -0,0 +1,106 @@
+package lookup
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHostsFile = `# A comment
+127.0.0.1	localhost
+::1		localhost ip6-localhost
+192.0.2.10	Intranet.example.com intranet	# trailing comment
+fe80::1%eth0	router.example.com
+not-an-ip	ignored.example.com
+`
+
+func newTestHosts(t *testing.T, content string) (*Hosts, string) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return NewHosts(path), path
+}
+
+func TestHosts_Lookup(t *testing.T) {
+	hosts, _ := newTestHosts(t, testHostsFile)
+
+	msg, ok := hosts.lookup("intranet.EXAMPLE.com", dns.TypeA)
+	require.True(t, ok)
+	require.Len(t, msg.Answer, 1)
+	assert.Equal(t, "intranet.EXAMPLE.com.", msg.Answer[0].Header().Name)
+	assert.True(t, net.ParseIP("192.0.2.10").Equal(msg.Answer[0].(*dns.A).A))
+
+	msg, ok = hosts.lookup("localhost.", dns.TypeAAAA)
+	require.True(t, ok)
+	assert.True(t, net.IPv6loopback.Equal(msg.Answer[0].(*dns.AAAA).AAAA))
+
+	msg, ok = hosts.lookup("router.example.com.", dns.TypeAAAA)
+	require.True(t, ok)
+	assert.True(t, net.ParseIP("fe80::1").Equal(msg.Answer[0].(*dns.AAAA).AAAA))
+
+	msg, ok = hosts.lookup("10.2.0.192.in-addr.arpa.", dns.TypePTR)
+	require.True(t, ok)
+	require.Len(t, msg.Answer, 2)
+	assert.Equal(t, "intranet.example.com.", msg.Answer[0].(*dns.PTR).Ptr)
+	assert.Equal(t, "intranet.", msg.Answer[1].(*dns.PTR).Ptr)
+
+	// Names, or types, the file doesn't have are left to the nameservers.
+	_, ok = hosts.lookup("intranet.example.com.", dns.TypeAAAA)
+	assert.False(t, ok)
+	_, ok = hosts.lookup("intranet.example.com.", dns.TypeMX)
+	assert.False(t, ok)
+	_, ok = hosts.lookup("ignored.example.com.", dns.TypeA)
+	assert.False(t, ok)
+}
+
+func TestHosts_Reload(t *testing.T) {
+	hosts, path := newTestHosts(t, "192.0.2.1 host.example.com\n")
+	now := time.Now()
+	hosts.now = func() time.Time { return now }
+
+	msg, ok := hosts.lookup("host.example.com.", dns.TypeA)
+	require.True(t, ok)
+	assert.True(t, net.ParseIP("192.0.2.1").Equal(msg.Answer[0].(*dns.A).A))
+
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.2 host.example.com\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, now.Add(time.Minute), now.Add(time.Minute)))
+
+	// The change isn't seen until the file is next checked.
+	msg, _ = hosts.lookup("host.example.com.", dns.TypeA)
+	assert.True(t, net.ParseIP("192.0.2.1").Equal(msg.Answer[0].(*dns.A).A))
+
+	now = now.Add(hostsRecheckInterval)
+	msg, _ = hosts.lookup("host.example.com.", dns.TypeA)
+	assert.True(t, net.ParseIP("192.0.2.2").Equal(msg.Answer[0].(*dns.A).A))
+
+	// A missing file is treated as empty.
+	require.NoError(t, os.Remove(path))
+	now = now.Add(hostsRecheckInterval)
+	_, ok = hosts.lookup("host.example.com.", dns.TypeA)
+	assert.False(t, ok)
+}
+
+func TestDnsLookup_QueryHosts(t *testing.T) {
+	hosts, _ := newTestHosts(t, testHostsFile)
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "www.example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "www.example.com. 300 IN A 192.0.2.80"), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}, RemotelyAuthenticateData: true, Hosts: hosts}
+
+	answers, err := d.QueryA("intranet.example.com.")
+	require.NoError(t, err)
+	require.Len(t, answers, 1)
+	assert.True(t, net.ParseIP("192.0.2.10").Equal(answers[0].A))
+
+	answers, err = d.QueryA("www.example.com.")
+	require.NoError(t, err)
+	require.Len(t, answers, 1)
+	ns.AssertNumberOfCalls(t, "Query", 1)
+}
@@ -0,0 +1,136 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Validate authenticates a response, as Authenticate does, but reports the outcome as a ValidationStatus. When the
+// response is unsigned, its zone is checked for an insecure delegation: a DS query for the zone, or one of its
+// ancestors, that's proven to have no DS records by a validated NSEC or NSEC3 record from the parent zone. Such a
+// response is ValidationInsecure.
+//
+// An error is returned for ValidationBogus and ValidationIndeterminate responses, explaining why.
+func (d *DnsLookup) Validate(msg *dns.Msg, ctx context.Context) (ValidationStatus, error) {
+	err := d.Authenticate(msg, ctx)
+	switch {
+	case err == nil:
+		return ValidationSecure, nil
+	case errors.Is(err, errNotInChain), ctx.Err() != nil:
+		return ValidationIndeterminate, err
+	case msg == nil || len(msg.Question) == 0 || isSigned(msg):
+		return ValidationBogus, err
+	}
+
+	status, proofErr := d.proveInsecure(msg.Question[0].Name, ctx)
+	if status == ValidationInsecure {
+		logger := d.componentLogger(LogComponentValidation)
+		logger.Info().Str("domain", msg.Question[0].Name).Msg("Answer is from an unsigned zone, below an insecure delegation")
+		return status, nil
+	}
+	if proofErr != nil {
+		return status, fmt.Errorf("%w; %w", err, proofErr)
+	}
+	return status, err
+}
+
+// proveInsecure walks up from name, looking for the delegation that makes it unsigned. If a signed delegation is
+// found first, the name should have been signed, so ValidationBogus is returned.
+//
+// A chain of trust given to Verify only holds records at the zones within it, so names it has nothing for are passed
+// over on the way up. If no proof is found above them, the outcome is ValidationIndeterminate, as the missing records
+// may have held one.
+func (d *DnsLookup) proveInsecure(name string, ctx context.Context) (ValidationStatus, error) {
+	var missing error
+
+	labels := dns.SplitDomainName(name)
+	for i := range labels {
+		zone := dns.Fqdn(strings.Join(labels[i:], "."))
+
+		msg, _, err := d.query(zone, dns.TypeDS, ctx)
+		if errors.Is(err, errNotInChain) {
+			missing = fmt.Errorf("unable to check for an insecure delegation at %s: %w", zone, err)
+			continue
+		}
+		if err != nil {
+			return ValidationIndeterminate, fmt.Errorf("unable to check for an insecure delegation at %s: %w", zone, err)
+		}
+
+		if len(extractRecordsOfType[*dns.DS](msg.Answer)) > 0 {
+			if missing != nil {
+				return ValidationIndeterminate, missing
+			}
+			return ValidationBogus, fmt.Errorf("%s is a signed delegation, so %s should be signed", zone, name)
+		}
+
+		proof := deniesDS(msg, zone)
+		if len(proof) == 0 {
+			// The chain holds records for this name, so it's taken as the closest a delegation could be; the names
+			// below it that the chain has nothing for no longer leave the outcome in doubt.
+			missing = nil
+			continue
+		}
+
+		// The denial must be validated, as it's what allows the answer to go unsigned.
+		denial := new(dns.Msg)
+		denial.SetQuestion(proof[0].Header().Name, proof[0].Header().Rrtype)
+		denial.Answer = proof
+		if err = d.Authenticate(denial, ctx); err != nil {
+			return ValidationBogus, fmt.Errorf("unable to authenticate the proof of an insecure delegation at %s: %w", zone, err)
+		}
+		return ValidationInsecure, nil
+	}
+
+	if missing != nil {
+		return ValidationIndeterminate, missing
+	}
+	return ValidationBogus, fmt.Errorf("no insecure delegation found above %s", name)
+}
+
+// deniesDS returns the NSEC or NSEC3 record, and its RRSIGs, proving that zone is a delegation without DS records:
+// one matching zone, with the NS bit set and the DS and SOA bits clear, signed by a parent zone. Opt-out NSEC3
+// records, which cover rather than match the name, aren't accepted. Nil is returned if there's no such proof.
+func deniesDS(msg *dns.Msg, zone string) []dns.RR {
+	if msg.Rcode != dns.RcodeSuccess {
+		return nil
+	}
+
+	delegation := func(bitmap []uint16) bool {
+		return slices.Contains(bitmap, dns.TypeNS) && !slices.Contains(bitmap, dns.TypeDS) && !slices.Contains(bitmap, dns.TypeSOA)
+	}
+
+	for _, rr := range msg.Ns {
+		var matched bool
+		switch record := rr.(type) {
+		case *dns.NSEC:
+			matched = strings.EqualFold(record.Hdr.Name, zone) && delegation(record.TypeBitMap)
+		case *dns.NSEC3:
+			matched = record.Match(zone) && delegation(record.TypeBitMap)
+		}
+		if !matched {
+			continue
+		}
+
+		proof := []dns.RR{rr}
+		for _, sig := range extractRecordsOfType[*dns.RRSIG](msg.Ns) {
+			if sig.TypeCovered == rr.Header().Rrtype && strings.EqualFold(sig.Hdr.Name, rr.Header().Name) &&
+				!strings.EqualFold(sig.SignerName, zone) && dns.IsSubDomain(sig.SignerName, zone) {
+				proof = append(proof, sig)
+			}
+		}
+		if len(proof) > 1 {
+			return proof
+		}
+	}
+	return nil
+}
+
+// isSigned reports whether a response contains any RRSIG records.
+func isSigned(msg *dns.Msg) bool {
+	return len(extractRecordsOfType[*dns.RRSIG](msg.Answer)) > 0 || len(extractRecordsOfType[*dns.RRSIG](msg.Ns)) > 0
+}
@@ -0,0 +1,110 @@
+package lookup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unsignedZoneNameServer serves an unsigned answer for www.unsigned.com., and the com. zone's NSEC denial of DS
+// records for unsigned.com., passing every query outside unsigned.com. on to a NameServer.
+type unsignedZoneNameServer struct {
+	NameServer
+	nsec []dns.RR
+}
+
+func newUnsignedZoneNameServer(t *testing.T, com *dnssectest.Zone, next NameServer, bitmap ...uint16) *unsignedZoneNameServer {
+	nsec := &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: "unsigned.com.", Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+		NextDomain: "zzz.com.",
+		TypeBitMap: bitmap,
+	}
+	rrsig, err := dnssectest.Sign([]dns.RR{nsec}, com.ZSK, com.ZSKSigner, com.Inception, com.Expiration)
+	require.NoError(t, err)
+
+	return &unsignedZoneNameServer{NameServer: next, nsec: []dns.RR{nsec, rrsig}}
+}
+
+func (n *unsignedZoneNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), rrtype)
+	msg.Response = true
+	msg.SetEdns0(4096, true)
+
+	switch {
+	case name == "www.unsigned.com." && rrtype == dns.TypeA:
+		rr, _ := dns.NewRR("www.unsigned.com. 300 IN A 192.0.2.1")
+		msg.Answer = []dns.RR{rr}
+	case name == "unsigned.com." && rrtype == dns.TypeDS:
+		msg.Ns = n.nsec
+	case dns.IsSubDomain("unsigned.com.", name):
+		// Anything else within the unsigned zone has no data.
+	default:
+		return n.NameServer.Query(name, rrtype)
+	}
+	return msg, 0, nil
+}
+
+func TestValidate_Insecure(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	ns := newUnsignedZoneNameServer(t, zones[1], NewUdpNameserver(server.Address, server.Port), dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	_, err := d.QueryA("www.unsigned.com.")
+	assert.ErrorContains(t, err, "no RRSIG records found")
+
+	msg, _, err := ns.Query("www.unsigned.com.", dns.TypeA)
+	require.NoError(t, err)
+	status, err := d.Validate(msg, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ValidationInsecure, status)
+
+	d.AllowInsecure = true
+	records, err := d.QueryA("www.unsigned.com.")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	// Signed answers are still secure.
+	msg, _, err = ns.Query("test.example.com.", dns.TypeA)
+	require.NoError(t, err)
+	status, err = d.Validate(msg, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ValidationSecure, status)
+}
+
+func TestValidate_Bogus(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	// The NSEC record shows unsigned.com. has DS records, so its answers should be signed.
+	ns := newUnsignedZoneNameServer(t, zones[1], NewUdpNameserver(server.Address, server.Port), dns.TypeNS, dns.TypeDS, dns.TypeRRSIG, dns.TypeNSEC)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+	d.AllowInsecure = true
+
+	msg, _, err := ns.Query("www.unsigned.com.", dns.TypeA)
+	require.NoError(t, err)
+	status, err := d.Validate(msg, context.Background())
+	assert.ErrorContains(t, err, "com. is a signed delegation")
+	assert.Equal(t, ValidationBogus, status)
+
+	_, err = d.QueryA("www.unsigned.com.")
+	assert.Error(t, err)
+
+	// A denial that isn't signed by the parent proves nothing.
+	ns.nsec = ns.nsec[:1]
+	status, _ = d.Validate(msg, context.Background())
+	assert.Equal(t, ValidationBogus, status)
+}
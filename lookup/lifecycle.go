@@ -0,0 +1,97 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+)
+
+// ErrClosed is returned by queries made after Close or Shutdown has been called.
+var ErrClosed = errors.New("dns lookup is closed")
+
+// lifecycle tracks in-flight queries, and the functions to call on shutdown, so a DnsLookup can be shut down cleanly.
+type lifecycle struct {
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+	hooks    []*func() error
+}
+
+// begin registers the start of a query. It returns false once the DnsLookup has been closed.
+func (l *lifecycle) begin() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.inflight.Add(1)
+	return true
+}
+
+// end registers the end of a query started with begin.
+func (l *lifecycle) end() {
+	l.inflight.Done()
+}
+
+// onClose registers a function to call on shutdown, e.g. to stop a background task. If the DnsLookup has already
+// been closed, it's called straight away. The function returned unregisters it, for when the task is stopped first.
+func (l *lifecycle) onClose(hook func() error) (func(), error) {
+	l.mu.Lock()
+	if !l.closed {
+		registered := &hook
+		l.hooks = append(l.hooks, registered)
+		l.mu.Unlock()
+		return func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.hooks = slices.DeleteFunc(l.hooks, func(h *func() error) bool { return h == registered })
+		}, nil
+	}
+	l.mu.Unlock()
+	return func() {}, hook()
+}
+
+//-----
+
+// Shutdown stops new queries from being made, waits for in-flight queries to complete, then stops any background
+// tasks and closes the nameservers that implement io.Closer. If ctx is done before the in-flight queries complete, they're left to finish
+// on their own, and ctx's error is returned along with any error from stopping the background tasks.
+//
+// Queries made after Shutdown has been called return ErrClosed. Calling Shutdown more than once is safe.
+func (d *DnsLookup) Shutdown(ctx context.Context) error {
+	d.lifecycle.mu.Lock()
+	d.lifecycle.closed = true
+	hooks := d.lifecycle.hooks
+	d.lifecycle.hooks = nil
+	d.lifecycle.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.lifecycle.inflight.Wait()
+		close(done)
+	}()
+
+	errs := make([]error, 0)
+	select {
+	case <-ctx.Done():
+		errs = append(errs, ctx.Err())
+	case <-done:
+	}
+
+	for _, hook := range hooks {
+		errs = append(errs, (*hook)())
+	}
+	for _, nameserver := range d.nameservers {
+		if closer, ok := nameserver.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close shuts the DnsLookup down, waiting for in-flight queries to complete. See Shutdown.
+func (d *DnsLookup) Close() error {
+	return d.Shutdown(context.Background())
+}
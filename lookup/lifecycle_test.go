@@ -0,0 +1,88 @@
+package lookup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingNameServer is a NameServer whose queries don't complete until release is closed.
+type blockingNameServer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	close(b.started)
+	<-b.release
+	return newLookupResponseMsgWithAD(dns.RcodeSuccess, false), time.Millisecond, nil
+}
+
+func (b *blockingNameServer) String() string {
+	return "blocking"
+}
+
+func TestShutdownWaitsForInflightQueries(t *testing.T) {
+	ns := &blockingNameServer{started: make(chan struct{}), release: make(chan struct{})}
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	hookCalled := false
+	_, err := d.lifecycle.onClose(func() error {
+		hookCalled = true
+		return nil
+	})
+	assert.NoError(t, err)
+
+	queryErr := make(chan error)
+	go func() {
+		_, _, err := d.Query("example.com.", dns.TypeA)
+		queryErr <- err
+	}()
+	<-ns.started
+
+	// The in-flight query doesn't complete in time.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Shutdown(ctx), context.DeadlineExceeded)
+	assert.True(t, hookCalled)
+
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	close(ns.release)
+	assert.NoError(t, <-queryErr)
+
+	assert.NoError(t, d.Close())
+}
+
+func TestOnCloseAfterClose(t *testing.T) {
+	d := &DnsLookup{}
+	assert.NoError(t, d.Close())
+
+	hookCalled := false
+	_, err := d.lifecycle.onClose(func() error {
+		hookCalled = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, hookCalled)
+}
+
+func TestOnCloseUnregister(t *testing.T) {
+	d := &DnsLookup{}
+
+	hookCalled := false
+	unregister, err := d.lifecycle.onClose(func() error {
+		hookCalled = true
+		return nil
+	})
+	require.NoError(t, err)
+	unregister()
+
+	assert.NoError(t, d.Close())
+	assert.False(t, hookCalled)
+}
@@ -0,0 +1,53 @@
+package lookup
+
+import (
+	"context"
+	"math"
+
+	"github.com/miekg/dns"
+)
+
+// Location is a LOC record's position decoded, as defined in RFC 1876.
+type Location struct {
+	Latitude            float64 // Degrees; negative south of the equator
+	Longitude           float64 // Degrees; negative west of the prime meridian
+	Altitude            float64 // Metres above the WGS 84 reference spheroid
+	Size                float64 // The diameter of a sphere enclosing the entity, in metres
+	HorizontalPrecision float64 // The horizontal precision of the position, in metres
+	VerticalPrecision   float64 // The vertical precision of the position, in metres
+}
+
+// DecodeLOC decodes the position of a LOC record.
+func DecodeLOC(rr *dns.LOC) Location {
+	return Location{
+		Latitude:            float64(int64(rr.Latitude)-dns.LOC_EQUATOR) / dns.LOC_DEGREES,
+		Longitude:           float64(int64(rr.Longitude)-dns.LOC_PRIMEMERIDIAN) / dns.LOC_DEGREES,
+		Altitude:            float64(rr.Altitude)/100 - dns.LOC_ALTITUDEBASE,
+		Size:                locPrecisionToMetres(rr.Size),
+		HorizontalPrecision: locPrecisionToMetres(rr.HorizPre),
+		VerticalPrecision:   locPrecisionToMetres(rr.VertPre),
+	}
+}
+
+// locPrecisionToMetres decodes a size or precision, which is held in centimetres as a mantissa in the high nibble and
+// a power of ten exponent in the low nibble.
+func locPrecisionToMetres(x uint8) float64 {
+	mantissa, exponent := float64(x>>4), float64(x&0x0f)
+	return mantissa * math.Pow(10, exponent) / 100
+}
+
+//-----
+
+// QueryLOC performs a DNS query for LOC records
+func (d *DnsLookup) QueryLOC(name string) ([]*dns.LOC, error) {
+	return d.QueryLOCContext(context.Background(), name)
+}
+
+// QueryLOCContext performs a DNS query for LOC records, stopping when ctx is done
+func (d *DnsLookup) QueryLOCContext(ctx context.Context, name string) ([]*dns.LOC, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeLOC)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.LOC](msg.Answer), nil
+}
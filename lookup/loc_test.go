@@ -0,0 +1,45 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLOC(t *testing.T) {
+	// The example from RFC 1876, appendix A.
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "cambridge-net.kei.com.", dns.TypeLOC).Return(newAnswerMsg(t,
+		"cambridge-net.kei.com. 300 IN LOC 42 21 54 N 71 06 18 W -24m 30m",
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QueryLOC("cambridge-net.kei.com.")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	location := DecodeLOC(records[0])
+	assert.InDelta(t, 42.365, location.Latitude, 0.0001)
+	assert.InDelta(t, -71.105, location.Longitude, 0.0001)
+	assert.InDelta(t, -24, location.Altitude, 0.001)
+	assert.InDelta(t, 30, location.Size, 0.001)
+	assert.InDelta(t, 10000, location.HorizontalPrecision, 0.001)
+	assert.InDelta(t, 10, location.VerticalPrecision, 0.001)
+}
+
+func TestDecodeLOC_Southern(t *testing.T) {
+	rr, err := dns.NewRR("sydney.example. 300 IN LOC 33 52 4.000 S 151 12 26.000 E 58m 1m 2m 3m")
+	require.NoError(t, err)
+
+	location := DecodeLOC(rr.(*dns.LOC))
+	assert.InDelta(t, -33.8678, location.Latitude, 0.0001)
+	assert.InDelta(t, 151.2072, location.Longitude, 0.0001)
+	assert.InDelta(t, 58, location.Altitude, 0.001)
+	assert.InDelta(t, 1, location.Size, 0.001)
+	assert.InDelta(t, 2, location.HorizontalPrecision, 0.001)
+	assert.InDelta(t, 3, location.VerticalPrecision, 0.001)
+}
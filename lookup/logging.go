@@ -1,32 +1,48 @@
 package lookup
 
 import (
+	"fmt"
 	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
 	"strings"
 )
 
-// dnsRecordTypes maps a rrtype id to a string representation
-var dnsRecordTypes = map[uint16]string{
-	1:   "A",
-	2:   "NS",
-	5:   "CNAME",
-	6:   "SOA",
-	12:  "PTR",
-	15:  "MX",
-	16:  "TXT",
-	28:  "AAAA",
-	33:  "SRV",
-	35:  "NAPTR",
-	36:  "KX",
-	37:  "CERT",
-	39:  "DNAME",
-	43:  "DS",
-	46:  "RRSIG",
-	47:  "NSEC",
-	48:  "DNSKEY",
-	50:  "NSEC3",
-	51:  "NSEC3PARAM",
-	257: "CAA",
+// LogComponent identifies a part of DnsLookup whose logging can be configured separately.
+type LogComponent string
+
+const (
+	LogComponentQuery      LogComponent = "query"      // Queries sent to the nameservers
+	LogComponentValidation LogComponent = "validation" // Local DNSSEC validation
+)
+
+// SetLogLevel sets the minimum level of events logged by a component. It takes the place of the logger's own
+// level for that component, so can be used to enable debug logging for just one part of DnsLookup.
+func (d *DnsLookup) SetLogLevel(component LogComponent, level zerolog.Level) {
+	if d.logLevels == nil {
+		d.logLevels = make(map[LogComponent]zerolog.Level)
+	}
+	d.logLevels[component] = level
+}
+
+// SetLogSampler sets a sampler for the events logged by a component, e.g. &zerolog.BasicSampler{N: 10} to log
+// every 10th event, or a zerolog.LevelSampler to only sample the high volume debug events.
+func (d *DnsLookup) SetLogSampler(component LogComponent, sampler zerolog.Sampler) {
+	if d.logSamplers == nil {
+		d.logSamplers = make(map[LogComponent]zerolog.Sampler)
+	}
+	d.logSamplers[component] = sampler
+}
+
+// componentLogger returns the logger for a component, with any level and sampler set for it applied.
+func (d *DnsLookup) componentLogger(component LogComponent) zerolog.Logger {
+	logger := d.logger.With().Str("component", string(component)).Logger()
+	if level, ok := d.logLevels[component]; ok {
+		logger = logger.Level(level)
+	}
+	if sampler, ok := d.logSamplers[component]; ok {
+		logger = logger.Sample(sampler)
+	}
+	return logger
 }
 
 var dnsAlgorithms = map[uint8]string{
@@ -56,13 +72,13 @@ func algorithmToString(algorithm uint8) string {
 	}
 }
 
-// rrtypeToString returns a string representation of a given rrtype integer.
+// rrtypeToString returns the mnemonic of a rrtype, e.g. "A", or its generic form, e.g. "TYPE65534", for one that
+// miekg/dns doesn't know (RFC 3597).
 func rrtypeToString(rrtype uint16) string {
-	if name, ok := dnsRecordTypes[rrtype]; ok {
+	if name, ok := dns.TypeToString[rrtype]; ok {
 		return name
-	} else {
-		return "unknown"
 	}
+	return fmt.Sprintf("TYPE%d", rrtype)
 }
 
 //---
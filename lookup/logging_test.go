@@ -1,11 +1,16 @@
 package lookup
 
 import (
+	"bytes"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRrtypeToString(t *testing.T) {
@@ -33,7 +38,10 @@ func TestRrtypeToString(t *testing.T) {
 		{rrtype: 50, expected: "NSEC3"},
 		{rrtype: 51, expected: "NSEC3PARAM"},
 		{rrtype: 257, expected: "CAA"},
-		{rrtype: 9999, expected: "unknown"},
+		{rrtype: 65, expected: "HTTPS"},
+		{rrtype: 52, expected: "TLSA"},
+		{rrtype: 256, expected: "URI"},
+		{rrtype: 9999, expected: "TYPE9999"},
 	}
 
 	for _, test := range tests {
@@ -128,3 +136,85 @@ func TestTabsToSpaces(t *testing.T) {
 		t.Errorf("Expected '%s', got '%s'", expected, result)
 	}
 }
+
+// logEvent logs a message at the given level, using a component's logger.
+func logEvent(d *DnsLookup, component LogComponent, level string) *zerolog.Event {
+	logger := d.componentLogger(component)
+	switch level {
+	case "Debug":
+		return logger.Debug()
+	case "Info":
+		return logger.Info()
+	default:
+		return logger.Warn()
+	}
+}
+
+func TestComponentLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	d := NewDnsLookup(nil)
+	d.SetLogger(zerolog.New(&buf).Level(zerolog.InfoLevel))
+
+	// The logger's own level applies by default.
+	logEvent(d, LogComponentQuery, "Debug").Msg("query debug")
+	logEvent(d, LogComponentValidation, "Info").Msg("validation info")
+	assert.NotContains(t, buf.String(), "query debug")
+	assert.Contains(t, buf.String(), `"component":"validation"`)
+
+	// Levels can be raised or lowered per component.
+	buf.Reset()
+	d.SetLogLevel(LogComponentQuery, zerolog.DebugLevel)
+	d.SetLogLevel(LogComponentValidation, zerolog.WarnLevel)
+	logEvent(d, LogComponentQuery, "Debug").Msg("query debug")
+	logEvent(d, LogComponentValidation, "Info").Msg("validation info")
+	assert.Contains(t, buf.String(), "query debug")
+	assert.NotContains(t, buf.String(), "validation info")
+
+	// Sampling only applies to the component it's set on.
+	buf.Reset()
+	d.SetLogSampler(LogComponentQuery, &zerolog.BasicSampler{N: 2})
+	for i := 0; i < 4; i++ {
+		logEvent(d, LogComponentQuery, "Info").Msg("query info")
+		logEvent(d, LogComponentValidation, "Warn").Msg("validation warn")
+	}
+	assert.Equal(t, 2, strings.Count(buf.String(), "query info"))
+	assert.Equal(t, 4, strings.Count(buf.String(), "validation warn"))
+}
+
+// countingSampler passes every event, counting those at each level.
+type countingSampler struct {
+	counts map[zerolog.Level]int
+}
+
+func (s *countingSampler) Sample(level zerolog.Level) bool {
+	s.counts[level]++
+	return true
+}
+
+func TestQueryAnswerLogging(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeA).Return(newAnswerMsg(t, "example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+
+	var buf bytes.Buffer
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.SetLogger(zerolog.New(&buf).Level(zerolog.InfoLevel))
+
+	// The answers are only logged at debug level.
+	_, _, err := d.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"level":"info","component":"query","domain":"example.com."`)
+	assert.NotContains(t, buf.String(), `"answers"`)
+
+	// Checking the level doesn't count towards a sampler, so it sees exactly the events logged.
+	buf.Reset()
+	sampler := &countingSampler{counts: make(map[zerolog.Level]int)}
+	d.SetLogLevel(LogComponentQuery, zerolog.DebugLevel)
+	d.SetLogSampler(LogComponentQuery, sampler)
+
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"answers":["example.com. 300 IN A 192.0.2.1"]`)
+	assert.Equal(t, strings.Count(buf.String(), `"level":"debug"`), sampler.counts[zerolog.DebugLevel])
+}
@@ -0,0 +1,64 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// LookupIP returns the IPv4 and IPv6 addresses of a host, querying its A and AAAA records in parallel. IPv4 addresses
+// are returned first. As with net.Resolver, a family with no records, i.e. NODATA or NXDOMAIN, is left out, so an
+// IPv4 only host returns its IPv4 addresses. An error is returned if both families have no records, or if either
+// query fails for any other reason, e.g. an answer that fails validation.
+func (d *DnsLookup) LookupIP(name string) ([]net.IP, error) {
+	return d.LookupIPContext(context.Background(), name)
+}
+
+// LookupIPContext returns the IPv4 and IPv6 addresses of a host, stopping when ctx is done.
+func (d *DnsLookup) LookupIPContext(ctx context.Context, name string) ([]net.IP, error) {
+	var wg sync.WaitGroup
+	var v4, v6 []net.IP
+	var v4Err, v6Err error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		var records []*dns.A
+		if records, v4Err = d.QueryAContext(ctx, name); v4Err == nil {
+			for _, rr := range records {
+				v4 = append(v4, rr.A.To4())
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var records []*dns.AAAA
+		if records, v6Err = d.QueryAAAAContext(ctx, name); v6Err == nil {
+			for _, rr := range records {
+				v6 = append(v6, rr.AAAA)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if v4Err != nil && v6Err != nil {
+		return nil, errors.Join(v4Err, v6Err)
+	}
+	for _, err := range []error{v4Err, v6Err} {
+		if err != nil && !errors.Is(err, ErrNoData) && !errors.Is(err, ErrNXDomain) {
+			return nil, err
+		}
+	}
+	if len(v4)+len(v6) == 0 {
+		// Without local authentication, an empty answer isn't an error, so neither family may have reported one.
+		if err := errors.Join(v4Err, v6Err); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no A or AAAA records found for %s: %w", dns.Fqdn(name), ErrNoData)
+	}
+	return append(append(make([]net.IP, 0, len(v4)+len(v6)), v4...), v6...), nil
+}
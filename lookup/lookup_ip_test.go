@@ -0,0 +1,114 @@
+package lookup
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupIP(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeA).Return(newAnswerMsg(t,
+		"example.com. 300 IN A 192.0.2.1",
+		"example.com. 300 IN A 192.0.2.2",
+	), time.Millisecond, nil)
+	ns.On("Query", "example.com.", dns.TypeAAAA).Return(
+		newAnswerMsg(t, "example.com. 300 IN AAAA 2001:db8::1"), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	ips, err := d.LookupIP("example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, []net.IP{
+		net.ParseIP("192.0.2.1").To4(),
+		net.ParseIP("192.0.2.2").To4(),
+		net.ParseIP("2001:db8::1"),
+	}, ips)
+	ns.AssertNumberOfCalls(t, "Query", 2)
+}
+
+func TestLookupIP_Error(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+	unauthenticated := newAnswerMsg(t, "example.com. 300 IN AAAA 2001:db8::1")
+	unauthenticated.AuthenticatedData = false
+	ns.On("Query", "example.com.", dns.TypeAAAA).Return(unauthenticated, time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}, RemotelyAuthenticateData: true}
+
+	// One family failing validation fails the lookup, rather than returning only the other.
+	ips, err := d.LookupIP("example.com.")
+	assert.ErrorIs(t, err, errResolverAuthentication)
+	assert.Nil(t, ips)
+}
+
+// noDataNameServer answers queries for rrtype with an empty, NODATA, response, passing the rest on to next.
+type noDataNameServer struct {
+	rrtype uint16
+	next   NameServer
+}
+
+func (n *noDataNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	return n.QueryContext(context.Background(), name, rrtype)
+}
+
+func (n *noDataNameServer) QueryContext(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	if rrtype != n.rrtype {
+		return n.next.Query(name, rrtype)
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), rrtype)
+	msg.Response = true
+	return msg, 0, nil
+}
+
+func (n *noDataNameServer) String() string {
+	return n.next.String()
+}
+
+func TestLookupIP_NoData(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	ns := &noDataNameServer{rrtype: dns.TypeAAAA, next: NewUdpNameserver(server.Address, server.Port)}
+	d := NewDnsLookup([]NameServer{ns})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	// The AAAA response can't be authenticated locally, but as it's NODATA, the IPv4 address is still returned.
+	_, err := d.QueryAAAA("test.example.com.")
+	assert.ErrorIs(t, err, ErrNoData)
+	assert.ErrorIs(t, err, ErrDNSSECBogus)
+
+	ips, err := d.LookupIP("test.example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, []net.IP{net.ParseIP("192.0.2.1").To4()}, ips)
+
+	// When neither family has records, the lookup fails.
+	ns.rrtype = dns.TypeA
+	_, err = d.LookupIP("test.example.com.")
+	assert.Error(t, err)
+}
+
+func TestLookupIP_NoDataUnauthenticated(t *testing.T) {
+	empty := newAnswerMsg(t)
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeA).Return(empty, time.Millisecond, nil)
+	ns.On("Query", "example.com.", dns.TypeAAAA).Return(empty, time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	// Neither empty answer is an error by itself, but having no addresses at all is.
+	ips, err := d.LookupIP("example.com.")
+	assert.Nil(t, ips)
+	assert.ErrorIs(t, err, ErrNoData)
+	assert.EqualError(t, err, "no A or AAAA records found for example.com.: no records of the type queried")
+}
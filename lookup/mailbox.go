@@ -0,0 +1,75 @@
+package lookup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// OpenPGPKeyName returns the owner name of the OPENPGPKEY records for an email address, as defined in RFC 7929:
+// the SHA-256 hash of the address's local part, truncated to 28 octets and hex encoded, followed by _openpgpkey
+// and the address's domain.
+func OpenPGPKeyName(address string) (string, error) {
+	return mailboxOwnerName(address, "_openpgpkey")
+}
+
+// SMIMEAName returns the owner name of the SMIMEA records for an email address, as defined in RFC 8162. The local
+// part is hashed as for OPENPGPKEY, and followed by _smimecert and the address's domain.
+func SMIMEAName(address string) (string, error) {
+	return mailboxOwnerName(address, "_smimecert")
+}
+
+// mailboxOwnerName encodes an email address into an owner name beneath the given label. The local part is hashed
+// exactly as given, without case folding, as RFC 7929 requires.
+func mailboxOwnerName(address, label string) (string, error) {
+	at := strings.LastIndex(address, "@")
+	if at < 1 || at == len(address)-1 {
+		return "", fmt.Errorf("invalid email address %q", address)
+	}
+	local, domain := address[:at], address[at+1:]
+
+	hash := sha256.Sum256([]byte(local))
+	return fmt.Sprintf("%s.%s.%s", hex.EncodeToString(hash[:28]), label, dns.Fqdn(domain)), nil
+}
+
+//-----
+
+// QueryOPENPGPKEY performs a DNS query for the OPENPGPKEY records of an email address
+func (d *DnsLookup) QueryOPENPGPKEY(address string) ([]*dns.OPENPGPKEY, error) {
+	return d.QueryOPENPGPKEYContext(context.Background(), address)
+}
+
+// QueryOPENPGPKEYContext performs a DNS query for the OPENPGPKEY records of an email address, stopping when ctx is done
+func (d *DnsLookup) QueryOPENPGPKEYContext(ctx context.Context, address string) ([]*dns.OPENPGPKEY, error) {
+	name, err := OpenPGPKeyName(address)
+	if err != nil {
+		return nil, err
+	}
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeOPENPGPKEY)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.OPENPGPKEY](msg.Answer), nil
+}
+
+// QuerySMIMEA performs a DNS query for the SMIMEA records of an email address
+func (d *DnsLookup) QuerySMIMEA(address string) ([]*dns.SMIMEA, error) {
+	return d.QuerySMIMEAContext(context.Background(), address)
+}
+
+// QuerySMIMEAContext performs a DNS query for the SMIMEA records of an email address, stopping when ctx is done
+func (d *DnsLookup) QuerySMIMEAContext(ctx context.Context, address string) ([]*dns.SMIMEA, error) {
+	name, err := SMIMEAName(address)
+	if err != nil {
+		return nil, err
+	}
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeSMIMEA)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.SMIMEA](msg.Answer), nil
+}
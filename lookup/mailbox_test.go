@@ -0,0 +1,46 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenPGPKeyName(t *testing.T) {
+	// The example from RFC 7929, section 3.
+	name, err := OpenPGPKeyName("hugh@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "c93f1e400f26708f98cb19d936620da35eec8f72e57f9eec01c1afd6._openpgpkey.example.com.", name)
+
+	name, err = SMIMEAName("hugh@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "c93f1e400f26708f98cb19d936620da35eec8f72e57f9eec01c1afd6._smimecert.example.com.", name)
+
+	// Only the last @ separates the local part from the domain.
+	name, err = OpenPGPKeyName(`"a@b"@example.com`)
+	require.NoError(t, err)
+	assert.Contains(t, name, "._openpgpkey.example.com.")
+
+	for _, address := range []string{"", "hugh", "@example.com", "hugh@"} {
+		_, err = OpenPGPKeyName(address)
+		assert.ErrorContains(t, err, "invalid email address", address)
+	}
+}
+
+func TestQuerySMIMEA(t *testing.T) {
+	name, err := SMIMEAName("hugh@example.com")
+	require.NoError(t, err)
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", name, dns.TypeSMIMEA).Return(newAnswerMsg(t, name+" 300 IN SMIMEA 3 0 1 AABBCCDD"), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QuerySMIMEA("hugh@example.com")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, uint8(3), records[0].Usage)
+}
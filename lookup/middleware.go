@@ -0,0 +1,41 @@
+package lookup
+
+import (
+	"context"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// QueryFunc performs a query, as DnsLookup.QueryContext does.
+type QueryFunc func(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error)
+
+// Middleware wraps a QueryFunc, in the style of an http.RoundTripper, so queries can be answered, changed, blocked or
+// measured without changing how they're made. It's given the next QueryFunc in the chain, and returns the one to call
+// in its place; it can call next, with the same or different arguments, or answer the query itself.
+//
+//	block := func(next lookup.QueryFunc) lookup.QueryFunc {
+//		return func(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+//			if dns.IsSubDomain("ads.example.", dns.Fqdn(name)) {
+//				return nil, 0, lookup.ErrNXDomain
+//			}
+//			return next(ctx, name, rrtype)
+//		}
+//	}
+type Middleware func(next QueryFunc) QueryFunc
+
+// Use adds middleware to the queries made by QueryContext, and so by Query and the typed helpers. The first middleware
+// added is the outermost, seeing each query first. Middleware sees the name as it was given, before it's converted to
+// its A-label form or expanded with the SearchDomains; the queries made whilst authenticating an answer don't pass
+// through it. Use isn't safe to call concurrently with queries, so add middleware before making any.
+func (d *DnsLookup) Use(middleware ...Middleware) {
+	d.middleware = append(d.middleware, middleware...)
+}
+
+// chain returns the QueryFunc that runs the middleware in turn, ending with query.
+func (d *DnsLookup) chain(query QueryFunc) QueryFunc {
+	for i := len(d.middleware) - 1; i >= 0; i-- {
+		query = d.middleware[i](query)
+	}
+	return query
+}
@@ -0,0 +1,81 @@
+package lookup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDnsLookup_Use(t *testing.T) {
+	ns := &namedMockNameServer{name: "udp://192.0.2.1:53"}
+	ns.On("Query", "rewritten.example.com.", dns.TypeA).Return(newAnswerMsg(t, "rewritten.example.com. 300 IN A 192.0.2.10"), time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	var order []string
+	d.Use(
+		func(next QueryFunc) QueryFunc {
+			return func(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+				order = append(order, "outer:"+name)
+				return next(ctx, name, rrtype)
+			}
+		},
+		func(next QueryFunc) QueryFunc {
+			return func(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+				order = append(order, "inner:"+name)
+				if name == "blocked.example.com." {
+					return nil, 0, ErrNXDomain
+				}
+				if name == "original.example.com." {
+					name = "rewritten.example.com."
+				}
+				return next(ctx, name, rrtype)
+			}
+		},
+	)
+
+	msg, _, err := d.Query("original.example.com.", dns.TypeA)
+	require.NoError(t, err)
+	require.Len(t, msg.Answer, 1)
+	assert.Equal(t, "192.0.2.10", msg.Answer[0].(*dns.A).A.String())
+	assert.Equal(t, []string{"outer:original.example.com.", "inner:original.example.com."}, order)
+
+	// A blocked query never reaches the nameservers.
+	_, _, err = d.Query("blocked.example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, ErrNXDomain)
+	ns.AssertNumberOfCalls(t, "Query", 1)
+
+	// The typed helpers pass through the middleware too.
+	order = nil
+	records, err := d.QueryA("original.example.com.")
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Len(t, order, 2)
+}
+
+func TestDnsLookup_UseWithSearchDomains(t *testing.T) {
+	ns := &namedMockNameServer{name: "udp://192.0.2.1:53"}
+	ns.On("Query", "host.corp.example.", dns.TypeA).Return(newAnswerMsg(t, "host.corp.example. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.SearchDomains = []string{"corp.example"}
+
+	// The middleware sees the name as given, once, rather than each name it's expanded to.
+	var names []string
+	d.Use(func(next QueryFunc) QueryFunc {
+		return func(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+			names = append(names, name)
+			return next(ctx, name, rrtype)
+		}
+	})
+
+	_, _, err := d.Query("host", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host"}, names)
+}
@@ -2,11 +2,9 @@ package lookup
 
 import (
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/ed25519"
-	"crypto/rsa"
 	"fmt"
 	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
 	"github.com/stretchr/testify/mock"
 	"strings"
 	"time"
@@ -54,40 +52,25 @@ type mockNameServerZone struct {
 
 func (z *mockNameServerZone) rrsigA(inception, expiration int64) *dns.RRSIG {
 	// Signed using the ZSK
-	rrsig := &dns.RRSIG{
-		Inception:  uint32(inception),
-		Expiration: uint32(expiration),
-		KeyTag:     z.zsk.KeyTag(),
-		SignerName: z.zsk.Header().Name,
-		Algorithm:  z.zsk.Algorithm,
-	}
-	rrsig.Sign(z.zskSigner, []dns.RR{*z.a})
+	rrsig, _ := dnssectest.Sign([]dns.RR{*z.a}, z.zsk, z.zskSigner, time.Unix(inception, 0), time.Unix(expiration, 0))
+	return rrsig
+}
+
+func (z *mockNameServerZone) rrsigZSK(records []dns.RR, inception, expiration int64) *dns.RRSIG {
+	// Signed using the ZSK
+	rrsig, _ := dnssectest.Sign(records, z.zsk, z.zskSigner, time.Unix(inception, 0), time.Unix(expiration, 0))
 	return rrsig
 }
 
 func (z *mockNameServerZone) rrsigDS(inception, expiration int64) *dns.RRSIG {
 	// Signed using the ZSK
-	rrsig := &dns.RRSIG{
-		Inception:  uint32(inception),
-		Expiration: uint32(expiration),
-		KeyTag:     z.parent.zsk.KeyTag(),
-		SignerName: z.parent.zsk.Header().Name,
-		Algorithm:  z.parent.zsk.Algorithm,
-	}
-	rrsig.Sign(z.parent.zskSigner, []dns.RR{z.ds})
+	rrsig, _ := dnssectest.Sign([]dns.RR{z.ds}, z.parent.zsk, z.parent.zskSigner, time.Unix(inception, 0), time.Unix(expiration, 0))
 	return rrsig
 }
 
 func (z *mockNameServerZone) rrsigDNSKEY(inception, expiration int64) *dns.RRSIG {
 	// Signed using the KSK
-	rrsig := &dns.RRSIG{
-		Inception:  uint32(inception),
-		Expiration: uint32(expiration),
-		KeyTag:     z.ksk.KeyTag(),
-		SignerName: z.ksk.Header().Name,
-		Algorithm:  z.ksk.Algorithm,
-	}
-	rrsig.Sign(z.kskSigner, []dns.RR{z.ksk, z.zsk})
+	rrsig, _ := dnssectest.Sign([]dns.RR{z.ksk, z.zsk}, z.ksk, z.kskSigner, time.Unix(inception, 0), time.Unix(expiration, 0))
 	return rrsig
 }
 
@@ -238,28 +221,9 @@ func (m *mockNameServer) prepFullChain() *mockNameServer {
 // Functions for generating a mock (self-signed) chain.
 
 func mockGenerateDNSKEY(name string, flag uint16, algorithm uint8, bits int) (*dns.DNSKEY, crypto.Signer) {
-	key := &dns.DNSKEY{
-		Hdr: dns.RR_Header{
-			Name:     name,
-			Rrtype:   dns.TypeDNSKEY,
-			Class:    dns.ClassINET,
-			Ttl:      0,
-			Rdlength: 0,
-		},
-		Flags:     flag,
-		Algorithm: algorithm,
-		Protocol:  3,
+	key, signer, err := dnssectest.GenerateKey(name, flag, algorithm, bits)
+	if err != nil {
+		panic(err)
 	}
-
-	secret, _ := key.Generate(bits)
-
-	if signer, ok := secret.(*rsa.PrivateKey); ok {
-		return key, signer
-	} else if signer, ok := secret.(*ecdsa.PrivateKey); ok {
-		return key, signer
-	} else if signer, ok := secret.(ed25519.PrivateKey); ok {
-		return key, signer
-	}
-
-	panic("unknown key type generation")
+	return key, signer
 }
@@ -0,0 +1,217 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// KeyChange is the kind of change a KeyMonitor has seen.
+type KeyChange uint8
+
+const (
+	KeyAdded         KeyChange = iota // A DNSKEY was added to the zone
+	KeyRemoved                        // A DNSKEY was removed from the zone
+	DSAdded                           // A DS record for the zone was added to the parent
+	DSRemoved                         // A DS record for the zone was removed from the parent
+	AlgorithmChanged                  // The set of algorithms used by the zone's DNSKEYs changed
+)
+
+func (c KeyChange) String() string {
+	switch c {
+	case KeyAdded:
+		return "key-added"
+	case KeyRemoved:
+		return "key-removed"
+	case DSAdded:
+		return "ds-added"
+	case DSRemoved:
+		return "ds-removed"
+	case AlgorithmChanged:
+		return "algorithm-changed"
+	}
+	return fmt.Sprintf("KeyChange(%d)", uint8(c))
+}
+
+// KeyEvent describes a change seen by a KeyMonitor. Key is set for key changes, DS for DS changes, and Algorithms,
+// the algorithms now in use, for algorithm changes.
+type KeyEvent struct {
+	Zone       string
+	Change     KeyChange
+	Key        *dns.DNSKEY
+	DS         *dns.DS
+	Algorithms []uint8
+}
+
+// DefaultKeyMonitorInterval is how often a KeyMonitor checks a zone's keys when it isn't given a positive interval.
+const DefaultKeyMonitorInterval = time.Hour
+
+// KeyMonitor periodically fetches a zone's DNSKEY set, and its DS set from the parent, calling back with each change.
+// Unexpected changes can indicate a compromised key; expected ones confirm a rollover is progressing.
+type KeyMonitor struct {
+	Zone string
+
+	lookup   *DnsLookup
+	interval time.Duration
+	onEvent  func(KeyEvent)
+	onError  func(error)
+
+	mu         sync.Mutex
+	primed     bool // Set once the first sets have been fetched; changes are reported relative to them
+	keys       map[string]*dns.DNSKEY
+	ds         map[string]*dns.DS
+	algorithms []uint8
+
+	stop       chan struct{}
+	done       chan struct{}
+	once       sync.Once
+	unregister func() // Removes the monitor's shutdown hook from the DnsLookup
+}
+
+// MonitorKeys starts monitoring a zone's keys, checking every interval until Stop is called or the DnsLookup is
+// shut down. An interval that isn't positive is replaced by DefaultKeyMonitorInterval. The first check establishes
+// the keys to compare against, so doesn't report any changes. onError, if not nil, is called when a check fails; the
+// next check is still made.
+func (d *DnsLookup) MonitorKeys(zone string, interval time.Duration, onEvent func(KeyEvent), onError func(error)) *KeyMonitor {
+	if interval <= 0 {
+		interval = DefaultKeyMonitorInterval
+	}
+
+	m := &KeyMonitor{
+		Zone:     dns.CanonicalName(zone),
+		lookup:   d,
+		interval: interval,
+		onEvent:  onEvent,
+		onError:  onError,
+		keys:     make(map[string]*dns.DNSKEY),
+		ds:       make(map[string]*dns.DS),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go m.run()
+
+	unregister, _ := d.lifecycle.onClose(func() error {
+		m.Stop()
+		return nil
+	})
+	m.mu.Lock()
+	m.unregister = unregister
+	m.mu.Unlock()
+
+	return m
+}
+
+// Stop stops the monitor, waiting for any check in progress to finish.
+func (m *KeyMonitor) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+
+	m.mu.Lock()
+	unregister := m.unregister
+	m.unregister = nil
+	m.mu.Unlock()
+	if unregister != nil {
+		unregister()
+	}
+}
+
+func (m *KeyMonitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Check(); err != nil && m.onError != nil {
+			m.onError(err)
+		}
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check fetches the zone's DNSKEY and DS sets, and calls back with any changes since the previous check. The
+// response cache is bypassed, so changes are seen as soon as they're published.
+func (m *KeyMonitor) Check() error {
+	ctx := context.WithValue(context.Background(), contextNoCache, true)
+
+	keys, err := m.lookup.QueryDNSKEYContext(ctx, m.Zone)
+	if err != nil {
+		return fmt.Errorf("unable to fetch the DNSKEY records for %s: %w", m.Zone, err)
+	}
+
+	// The root has no parent to publish DS records for it.
+	ds := make([]*dns.DS, 0)
+	if m.Zone != "." {
+		if ds, err = m.lookup.QueryDSContext(ctx, m.Zone); err != nil {
+			return fmt.Errorf("unable to fetch the DS records for %s: %w", m.Zone, err)
+		}
+	}
+
+	m.mu.Lock()
+	events := m.update(keys, ds)
+	m.mu.Unlock()
+
+	for _, event := range events {
+		if m.onEvent != nil {
+			m.onEvent(event)
+		}
+	}
+	return nil
+}
+
+// update replaces the monitored sets, returning the changes from the previous ones.
+func (m *KeyMonitor) update(keys []*dns.DNSKEY, ds []*dns.DS) []KeyEvent {
+	events := make([]KeyEvent, 0)
+
+	currentKeys := make(map[string]*dns.DNSKEY)
+	algorithms := make([]uint8, 0)
+	for _, key := range keys {
+		id := fmt.Sprintf("%d %d %s", key.Flags, key.Algorithm, key.PublicKey)
+		currentKeys[id] = key
+		if !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+		if _, ok := m.keys[id]; !ok && m.primed {
+			events = append(events, KeyEvent{Zone: m.Zone, Change: KeyAdded, Key: key})
+		}
+	}
+	for id, key := range m.keys {
+		if _, ok := currentKeys[id]; !ok {
+			events = append(events, KeyEvent{Zone: m.Zone, Change: KeyRemoved, Key: key})
+		}
+	}
+
+	currentDS := make(map[string]*dns.DS)
+	for _, record := range ds {
+		id := fmt.Sprintf("%d %d %d %s", record.KeyTag, record.Algorithm, record.DigestType, strings.ToUpper(record.Digest))
+		currentDS[id] = record
+		if _, ok := m.ds[id]; !ok && m.primed {
+			events = append(events, KeyEvent{Zone: m.Zone, Change: DSAdded, DS: record})
+		}
+	}
+	for id, record := range m.ds {
+		if _, ok := currentDS[id]; !ok {
+			events = append(events, KeyEvent{Zone: m.Zone, Change: DSRemoved, DS: record})
+		}
+	}
+
+	slices.Sort(algorithms)
+	if m.primed && !slices.Equal(algorithms, m.algorithms) {
+		events = append(events, KeyEvent{Zone: m.Zone, Change: AlgorithmChanged, Algorithms: algorithms})
+	}
+
+	m.keys, m.ds, m.algorithms, m.primed = currentKeys, currentDS, algorithms, true
+	return events
+}
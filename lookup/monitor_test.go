@@ -0,0 +1,91 @@
+package lookup
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyMonitor(t *testing.T) {
+	zones := newTestChain(t)
+	com, example := zones[1], zones[2]
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	var mu sync.Mutex
+	events := make([]KeyEvent, 0)
+	m := d.MonitorKeys("example.com", time.Hour, func(event KeyEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}, func(err error) {
+		t.Error(err)
+	})
+
+	// Wait for the first check, which establishes the keys to compare against.
+	assert.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.primed
+	}, time.Second, time.Millisecond)
+
+	// Start a rollover to a new KSK, using a different algorithm.
+	next, _, err := dnssectest.GenerateKey("example.com.", dnssectest.FlagKSK, dns.RSASHA256, 0)
+	require.NoError(t, err)
+	keys := []dns.RR{example.KSK, example.ZSK, next}
+	rrsig, err := dnssectest.Sign(keys, example.KSK, example.KSKSigner, example.Inception, example.Expiration)
+	require.NoError(t, err)
+	example.Set("example.com.", dns.TypeDNSKEY, append(keys, rrsig)...)
+	require.NoError(t, com.Add(next.ToDS(dns.SHA256)))
+
+	require.NoError(t, m.Check())
+
+	mu.Lock()
+	changes := make([]KeyChange, len(events))
+	for i, event := range events {
+		changes[i] = event.Change
+		assert.Equal(t, "example.com.", event.Zone)
+	}
+	mu.Unlock()
+
+	assert.ElementsMatch(t, []KeyChange{KeyAdded, DSAdded, AlgorithmChanged}, changes)
+	assert.Equal(t, next.KeyTag(), events[0].Key.KeyTag())
+
+	// Stopping the DnsLookup stops the monitor.
+	assert.NoError(t, d.Close())
+	select {
+	case <-m.done:
+	default:
+		t.Error("monitor still running after Close")
+	}
+}
+
+func TestKeyMonitor_Stop(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeDNSKEY).Return(newAnswerMsg(t), time.Millisecond, nil)
+	ns.On("Query", "example.com.", dns.TypeDS).Return(newAnswerMsg(t), time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	// An interval that isn't positive falls back to the default, rather than panicking.
+	m := d.MonitorKeys("example.com", 0, nil, nil)
+	assert.Equal(t, DefaultKeyMonitorInterval, m.interval)
+
+	// Once stopped, the monitor no longer needs stopping on shutdown.
+	m.Stop()
+	d.lifecycle.mu.Lock()
+	assert.Empty(t, d.lifecycle.hooks)
+	d.lifecycle.mu.Unlock()
+
+	m.Stop()
+	assert.NoError(t, d.Close())
+}
@@ -0,0 +1,102 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Errors returned by QueryMTASTS.
+var (
+	ErrNoMTASTSRecord        = errors.New("no mta-sts record found")
+	ErrMultipleMTASTSRecords = errors.New("more than one mta-sts record found")
+)
+
+// MTASTSRecord is a domain's MTA-STS TXT record, as defined in RFC 8461, section 3.1. It says the domain has a policy,
+// and its ID changes whenever the policy does, so a cached policy is stale once the ID differs.
+type MTASTSRecord struct {
+	Version    string            // Always "STSv1"
+	ID         string            // Identifies the current policy; up to 32 alphanumeric characters
+	Extensions map[string]string // Any other fields in the record, by name
+}
+
+// QueryMTASTS fetches the MTA-STS record of a domain, from the TXT records of _mta-sts.<domain>. Only records that
+// start "v=STSv1" are considered; if there isn't exactly one of those, the domain has no usable policy.
+func (d *DnsLookup) QueryMTASTS(domain string) (*MTASTSRecord, error) {
+	return d.QueryMTASTSContext(context.Background(), domain)
+}
+
+// QueryMTASTSContext fetches the MTA-STS record of a domain, stopping when ctx is done.
+func (d *DnsLookup) QueryMTASTSContext(ctx context.Context, domain string) (*MTASTSRecord, error) {
+	name := "_mta-sts." + dns.Fqdn(domain)
+	records, err := d.QueryTXTContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var sts []string
+	for _, record := range records {
+		if txt := strings.Join(record.Txt, ""); strings.HasPrefix(txt, "v=STSv1") {
+			sts = append(sts, txt)
+		}
+	}
+
+	switch len(sts) {
+	case 0:
+		return nil, fmt.Errorf("%w for %s", ErrNoMTASTSRecord, name)
+	case 1:
+		return parseMTASTS(sts[0])
+	default:
+		return nil, fmt.Errorf("%w for %s", ErrMultipleMTASTSRecords, name)
+	}
+}
+
+// parseMTASTS parses an MTA-STS record's semicolon separated fields. The record must start with the version, and
+// have an id of 1 to 32 alphanumeric characters.
+func parseMTASTS(txt string) (*MTASTSRecord, error) {
+	record := &MTASTSRecord{Extensions: make(map[string]string)}
+
+	for i, field := range strings.Split(txt, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mta-sts field %q", field)
+		}
+
+		switch {
+		case i == 0:
+			if name != "v" || value != "STSv1" {
+				return nil, fmt.Errorf("invalid mta-sts version %q", field)
+			}
+			record.Version = value
+		case name == "id":
+			record.ID = value
+		default:
+			record.Extensions[name] = value
+		}
+	}
+
+	if !validMTASTSID(record.ID) {
+		return nil, fmt.Errorf("invalid mta-sts id %q", record.ID)
+	}
+	return record, nil
+}
+
+// validMTASTSID checks an id is 1 to 32 alphanumeric characters.
+func validMTASTSID(id string) bool {
+	if len(id) < 1 || len(id) > 32 {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryMTASTS(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "_mta-sts.example.com.", dns.TypeTXT).Return(newAnswerMsg(t,
+		`_mta-sts.example.com. 300 IN TXT "v=spf1 -all"`,
+		`_mta-sts.example.com. 300 IN TXT "v=STSv1; id=20160831085700Z;" " ext=1"`,
+	), time.Millisecond, nil)
+	ns.On("Query", "_mta-sts.example.net.", dns.TypeTXT).Return(newAnswerMsg(t,
+		`_mta-sts.example.net. 300 IN TXT "v=STSv1; id=1"`,
+		`_mta-sts.example.net. 300 IN TXT "v=STSv1; id=2"`,
+	), time.Millisecond, nil)
+	ns.On("Query", "_mta-sts.example.org.", dns.TypeTXT).Return(newAnswerMsg(t,
+		`_mta-sts.example.org. 300 IN TXT "some other record"`,
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	record, err := d.QueryMTASTS("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "STSv1", record.Version)
+	assert.Equal(t, "20160831085700Z", record.ID)
+	assert.Equal(t, map[string]string{"ext": "1"}, record.Extensions)
+
+	_, err = d.QueryMTASTS("example.net")
+	assert.ErrorIs(t, err, ErrMultipleMTASTSRecords)
+
+	_, err = d.QueryMTASTS("example.org")
+	assert.ErrorIs(t, err, ErrNoMTASTSRecord)
+}
+
+func TestParseMTASTS(t *testing.T) {
+	_, err := parseMTASTS("v=STSv1;")
+	assert.ErrorContains(t, err, "invalid mta-sts id")
+
+	_, err = parseMTASTS("v=STSv1; id=not-alphanumeric")
+	assert.ErrorContains(t, err, "invalid mta-sts id")
+
+	_, err = parseMTASTS("v=STSv1; id=123456789012345678901234567890123")
+	assert.ErrorContains(t, err, "invalid mta-sts id")
+
+	_, err = parseMTASTS("v=STSv12; id=1")
+	assert.ErrorContains(t, err, "invalid mta-sts version")
+
+	_, err = parseMTASTS("v=STSv1; id")
+	assert.ErrorContains(t, err, "invalid mta-sts field")
+}
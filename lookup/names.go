@@ -0,0 +1,170 @@
+package lookup
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// NameValidation sets how strictly query names are checked before they're sent.
+type NameValidation uint8
+
+const (
+	NameValidationBasic    NameValidation = iota // Whitespace and control characters are rejected
+	NameValidationStrict                         // Labels must also be letters, digits and hyphens (LDH), not starting or ending with a hyphen
+	NameValidationDisabled                       // Names are sent as given
+)
+
+// InvalidNameError is returned when a query name fails validation.
+type InvalidNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("invalid name %q: %s", e.Name, e.Reason)
+}
+
+// checkNameLength checks that a name, once in wire format, is within the limits of RFC 1035 (section 2.3.4): labels
+// of at most 63 octets, and names of at most 255 octets including the length octets. Escape sequences such as \.
+// and \046 count as the single octet they represent. Empty labels are also rejected, as they can't be encoded.
+//
+// This is done regardless of NameValidation, as a name that fails it can't be packed into a query.
+func checkNameLength(name string) error {
+	invalid := func(format string, a ...any) error {
+		return &InvalidNameError{Name: name, Reason: fmt.Sprintf(format, a...)}
+	}
+
+	if name == "" {
+		return invalid("name is empty")
+	}
+
+	fqdn := dns.Fqdn(name)
+	if fqdn == "." {
+		return nil
+	}
+
+	total := 1 // The root label's length octet.
+	label, start := 0, 0
+	for i := 0; i < len(fqdn); i++ {
+		switch {
+		case fqdn[i] == '\\' && i+3 < len(fqdn) && isDigit(fqdn[i+1]) && isDigit(fqdn[i+2]) && isDigit(fqdn[i+3]):
+			i += 3
+			label++
+		case fqdn[i] == '\\':
+			i++
+			label++
+		case fqdn[i] == '.':
+			if label == 0 {
+				return invalid("name contains an empty label")
+			}
+			if label > 63 {
+				return invalid("label %q is %d octets long, which is over the limit of 63", fqdn[start:i], label)
+			}
+			total += label + 1
+			label, start = 0, i+1
+		default:
+			label++
+		}
+	}
+
+	// A name ending in an escaped dot has a final label that's yet to be counted.
+	if label > 63 {
+		return invalid("label %q is %d octets long, which is over the limit of 63", fqdn[start:], label)
+	}
+	if label > 0 {
+		total += label + 1
+	}
+
+	if total > 255 {
+		return invalid("name is %d octets long in wire format, which is over the limit of 255", total)
+	}
+	return nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// validateName checks the characters of a query name against the DnsLookup's NameValidation level; its length is
+// checked by checkNameLength. Underscores are only allowed in strict mode if AllowUnderscores is set, as they're
+// needed for names such as _sip._tcp.example.com (SRV) and selector._domainkey.example.com (DKIM).
+func (d *DnsLookup) validateName(name string) error {
+	if d.NameValidation == NameValidationDisabled {
+		return nil
+	}
+
+	invalid := func(format string, a ...any) error {
+		return &InvalidNameError{Name: name, Reason: fmt.Sprintf(format, a...)}
+	}
+
+	fqdn := dns.Fqdn(name)
+	if fqdn == "." {
+		return nil
+	}
+
+	for _, label := range dns.SplitDomainName(fqdn) {
+		for _, c := range label {
+			if c < 0x21 || c == 0x7f {
+				return invalid("label %q contains a whitespace or control character", label)
+			}
+		}
+
+		if d.NameValidation == NameValidationStrict {
+			if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+				return invalid("label %q starts or ends with a hyphen", label)
+			}
+			for _, c := range label {
+				switch {
+				case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+				case c == '_' && d.AllowUnderscores:
+				case c == '_':
+					return invalid("label %q contains an underscore, which is only allowed with AllowUnderscores set", label)
+				default:
+					return invalid("label %q contains the character %q, which isn't a letter, digit or hyphen", label, c)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// toASCII converts the labels of a name that contain Unicode characters to their A-label (punycode) form, using the
+// UTS #46 mapping, e.g. Bücher.example to xn--bcher-kva.example. Other labels, such as _dmarc, are left as they are.
+// Names are sent as given when NameValidation is NameValidationDisabled.
+func (d *DnsLookup) toASCII(name string) (string, error) {
+	if d.NameValidation == NameValidationDisabled || !hasNonASCII(name) {
+		return name, nil
+	}
+
+	labels := dns.SplitDomainName(name)
+	for i, label := range labels {
+		if !hasNonASCII(label) {
+			continue
+		}
+		ascii, err := idna.Lookup.ToASCII(label)
+		if err != nil {
+			return name, &InvalidNameError{Name: name, Reason: fmt.Sprintf("label %q isn't a valid internationalised label: %s", label, err)}
+		}
+		labels[i] = ascii
+	}
+
+	ascii := strings.Join(labels, ".")
+	if strings.HasSuffix(name, ".") {
+		ascii += "."
+	}
+	return ascii, nil
+}
+
+func hasNonASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package lookup
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation NameValidation
+		underscore bool
+		reason     string
+	}{
+		{"example.com.", NameValidationBasic, false, ""},
+		{"example.com", NameValidationStrict, false, ""},
+		{".", NameValidationStrict, false, ""},
+		{"_sip._tcp.example.com.", NameValidationBasic, false, ""},
+		{"_sip._tcp.example.com.", NameValidationStrict, true, ""},
+		{"_sip._tcp.example.com.", NameValidationStrict, false, "only allowed with AllowUnderscores set"},
+		{"1.0/25.0.0.192.in-addr.arpa.", NameValidationBasic, false, ""},
+		{"1.0/25.0.0.192.in-addr.arpa.", NameValidationStrict, false, "isn't a letter, digit or hyphen"},
+		{"-example.com.", NameValidationStrict, false, "starts or ends with a hyphen"},
+		{`a\.b.example.com.`, NameValidationBasic, false, ""},
+		{"exa mple.com.", NameValidationBasic, false, "whitespace or control character"},
+		{"exa mple.com.", NameValidationDisabled, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DnsLookup{NameValidation: tt.validation, AllowUnderscores: tt.underscore}
+			err := d.validateName(tt.name)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var invalid *InvalidNameError
+			assert.True(t, errors.As(err, &invalid))
+			assert.ErrorContains(t, err, tt.reason)
+		})
+	}
+}
+
+func TestCheckNameLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+	}{
+		{"example.com.", ""},
+		{"example.com", ""},
+		{".", ""},
+		{"", "name is empty"},
+		{"example..com.", "empty label"},
+		{".example.com.", "empty label"},
+		{strings.Repeat("a", 63) + ".com.", ""},
+		{strings.Repeat("a", 64) + ".com.", "is 64 octets long, which is over the limit of 63"},
+		// Escape sequences count as a single octet.
+		{strings.Repeat(`\046`, 63) + ".com.", ""},
+		{strings.Repeat(`\.`, 64) + ".com.", "over the limit of 63"},
+		{`example\.`, ""},
+		// 4 labels of 63 octets, plus their length octets, plus the root is 257 octets.
+		{strings.Repeat(strings.Repeat("a", 63)+".", 4), "is 257 octets long in wire format, which is over the limit of 255"},
+		{strings.Repeat(strings.Repeat("a", 63)+".", 3) + strings.Repeat("a", 61) + ".", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkNameLength(tt.name)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var invalid *InvalidNameError
+			assert.True(t, errors.As(err, &invalid))
+			assert.ErrorContains(t, err, tt.reason)
+		})
+	}
+}
+
+func TestQueryRejectsInvalidName(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	_, _, err := d.Query("example..com.", dns.TypeA)
+
+	var invalid *InvalidNameError
+	assert.ErrorAs(t, err, &invalid)
+	ns.AssertNotCalled(t, "Query", "example..com.", dns.TypeA)
+
+	// Lengths are checked even with validation disabled.
+	d.NameValidation = NameValidationDisabled
+	_, _, err = d.Query(strings.Repeat("a", 64)+".com.", dns.TypeA)
+	assert.ErrorAs(t, err, &invalid)
+}
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "example.com.", expected: "example.com."},
+		{name: "bücher.example.", expected: "xn--bcher-kva.example."},
+		{name: "BÜCHER.example", expected: "xn--bcher-kva.example"},
+		{name: "_dmarc.bücher.example.", expected: "_dmarc.xn--bcher-kva.example."},
+		{name: "例え.テスト.", expected: "xn--r8jz45g.xn--zckzah."},
+	}
+
+	d := &DnsLookup{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ascii, err := d.toASCII(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ascii)
+		})
+	}
+
+	_, err := d.toASCII("b\u200dcher.example.")
+	var invalid *InvalidNameError
+	assert.ErrorAs(t, err, &invalid)
+
+	// With validation disabled, names are sent as given.
+	d.NameValidation = NameValidationDisabled
+	ascii, err := d.toASCII("bücher.example.")
+	require.NoError(t, err)
+	assert.Equal(t, "bücher.example.", ascii)
+}
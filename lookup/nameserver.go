@@ -1,10 +1,18 @@
 package lookup
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,7 +31,23 @@ type DNSClient interface {
 	Exchange(m *dns.Msg, address string) (r *dns.Msg, rtt time.Duration, err error)
 }
 
+// ContextDNSClient is a DNSClient that can be cancelled, or given a deadline, via a context. *dns.Client satisfies it.
+type ContextDNSClient interface {
+	ExchangeContext(ctx context.Context, m *dns.Msg, address string) (r *dns.Msg, rtt time.Duration, err error)
+}
+
+// BootstrapResolver resolves a nameserver's hostname to IP addresses. *net.Resolver satisfies this interface.
+type BootstrapResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DefaultBootstrapResolver is used to resolve nameserver hostnames when no other BootstrapResolver is given.
+var DefaultBootstrapResolver BootstrapResolver = net.DefaultResolver
+
 // NameServer interface defines the methods for a DNS name server.
+//
+// A NameServer that holds connections open between queries may also implement io.Closer, to release them.
+// DnsLookup closes its nameservers when it's shut down.
 type NameServer interface {
 	// Query perform the DNS query/lookup.
 	Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error)
@@ -32,54 +56,220 @@ type NameServer interface {
 	String() string
 }
 
+// ContextNameServer is a NameServer whose queries can be cancelled, or given a deadline, via a context.
+// NameServers that don't implement it are abandoned, rather than stopped, when the context is done.
+type ContextNameServer interface {
+	NameServer
+
+	// QueryContext performs the DNS query/lookup, stopping when ctx is done.
+	QueryContext(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error)
+}
+
+// CheckedNameServer is a NameServer that can report whether the details it was created with are valid, so a mistake
+// in an address, port or option is found when the nameserver is created rather than by its first query.
+// NameServerConcrete and HttpsNameServer implement it.
+type CheckedNameServer interface {
+	NameServer
+
+	// Err returns why the nameserver's details are invalid, or nil if they're valid. Every query to an invalid
+	// nameserver fails with this error.
+	Err() error
+}
+
+// CheckNameServers returns an error naming each of the nameservers whose details are invalid, or nil if they're all
+// valid. Nameservers that don't implement CheckedNameServer are assumed to be valid.
+//
+//	nameservers := []lookup.NameServer{lookup.NewUdpNameserver(address, port)}
+//	if err := lookup.CheckNameServers(nameservers); err != nil {
+//		return err
+//	}
+//	client := lookup.NewDnsLookup(nameservers)
+func CheckNameServers(nameservers []NameServer) error {
+	var errs []error
+	for _, ns := range nameservers {
+		if checked, ok := ns.(CheckedNameServer); ok {
+			if err := checked.Err(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid nameserver %s: %w", ns.String(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // NameServerConcrete represents the details of a DNS name server, including protocol, address, port, and client.
 type NameServerConcrete struct {
-	protocol protocol  // Connection protocol: udp, tcp, or tcp-tls
-	domain   string    // Domain name for TLS certificate verification
-	address  string    // IP address of the name server
-	port     string    // Port number of the name server
-	client   DNSClient // DNS client for sending queries
+	protocol     protocol          // Connection protocol: udp, tcp, or tcp-tls
+	domain       string            // Domain name for TLS certificate verification
+	address      string            // IP address or hostname of the name server
+	port         string            // Port number of the name server
+	client       DNSClient         // DNS client for sending queries
+	fallback     DNSClient         // DNS client for retrying a query over TCP when the UDP response was truncated
+	bootstrap    *bootstrap        // Resolves the address when it's a hostname; nil when it's an IP address
+	edns         *edns             // Remembers whether the name server supports EDNS(0)
+	retry        *RetryPolicy      // Overrides the DnsLookup's RetryPolicy when set
+	clientSubnet *dns.EDNS0_SUBNET // Added to each query when set
+	padding      PaddingPolicy     // How queries are padded; by default, only on encrypted connections
+	tsig         *TsigKey          // Signs each query when set
+	limiter      *rate.Limiter     // Limits the rate queries are sent at when set
+	pcap         *PcapWriter       // Captures queries and responses when set
+	err          error             // Set when the address, port or options given were invalid
+}
+
+// NameServerOption configures optional behaviour on a NameServerConcrete.
+type NameServerOption func(*NameServerConcrete)
+
+// WithBootstrapResolver sets the resolver used to look up the nameserver's address when it's a hostname.
+func WithBootstrapResolver(resolver BootstrapResolver) NameServerOption {
+	return func(n *NameServerConcrete) {
+		if n.bootstrap != nil {
+			n.bootstrap.resolver = resolver
+		}
+	}
+}
+
+// WithTimeouts sets how long a UDP, TCP, TLS or QUIC nameserver may take to connect, and to send and receive each
+// message, so an unresponsive nameserver fails quickly rather than after the default of two seconds each (five for
+// QUIC). A zero timeout leaves that default in place.
+func WithTimeouts(dial, read, write time.Duration) NameServerOption {
+	return func(n *NameServerConcrete) {
+		for _, client := range []DNSClient{n.client, n.fallback} {
+			if pool, ok := client.(*pooledClient); ok {
+				client = pool.client
+			}
+			switch c := client.(type) {
+			case *dns.Client:
+				c.DialTimeout, c.ReadTimeout, c.WriteTimeout = dial, read, write
+			case *quicClient:
+				c.dialTimeout, c.readTimeout, c.writeTimeout = dial, read, write
+			}
+		}
+	}
+}
+
+// newNameServerConcrete normalises and validates the address and port of a NameServerConcrete, then applies any options given.
+// If the address or port are invalid, the error is returned by Err, and by every call to Query.
+func newNameServerConcrete(n *NameServerConcrete, opts []NameServerOption) *NameServerConcrete {
+	n.address, n.port, n.err = parseAddressAndPort(n.protocol, n.address, n.port)
+	n.edns = new(edns)
+	if n.err == nil && !isIPAddress(n.address) {
+		n.bootstrap = &bootstrap{resolver: DefaultBootstrapResolver}
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// parseAddressAndPort normalises a nameserver's address and port. The address can be an IP address, a bracketed
+// IPv6 address, or a hostname, optionally including a port. The port can be a number or a service name.
+// IPv6 addresses may include a zone index, e.g. fe80::1%eth0, which is needed to reach link-local addresses.
+func parseAddressAndPort(p protocol, address, port string) (string, string, error) {
+	address = strings.TrimSpace(address)
+	port = strings.TrimSpace(port)
+
+	if address == "" {
+		return address, port, fmt.Errorf("no address given")
+	}
+
+	bracketed := strings.HasPrefix(address, "[")
+
+	if host, addressPort, err := net.SplitHostPort(address); err == nil {
+		// The address includes a port, e.g. 1.1.1.1:53 or [::1]:53
+		if port != "" && port != addressPort {
+			return address, port, fmt.Errorf("address %s includes port %s, which conflicts with port %s", address, addressPort, port)
+		}
+		address, port = host, addressPort
+	} else if bracketed && strings.HasSuffix(address, "]") {
+		address = address[1 : len(address)-1]
+	}
+
+	// Bracketed addresses may have come from a URL, where the zone index is escaped, e.g. [fe80::1%25eth0]
+	if i := strings.Index(address, "%25"); bracketed && i >= 0 && i+3 < len(address) {
+		address = address[:i] + "%" + address[i+3:]
+	}
+
+	if !isIPAddress(address) {
+		if strings.Contains(address, "%") {
+			return address, port, fmt.Errorf("address %s is not a valid IPv6 address with zone index", address)
+		}
+		if _, ok := dns.IsDomainName(address); !ok || strings.ContainsAny(address, "[]/ ") {
+			return address, port, fmt.Errorf("address %s is not a valid IP address or hostname", address)
+		}
+	}
+
+	if port == "" {
+		return address, port, fmt.Errorf("no port given")
+	}
+
+	number, err := strconv.ParseUint(port, 10, 16)
+	if errors.Is(err, strconv.ErrRange) {
+		return address, port, fmt.Errorf("port %s is out of range", port)
+	} else if err != nil {
+		// Not a number, so try it as a service name, e.g. domain.
+		network := string(tcp)
+		if p == udp || p == quicProtocol {
+			network = string(udp)
+		}
+		lookedUp, err := net.LookupPort(network, port)
+		if err != nil {
+			return address, port, fmt.Errorf("port %s is not a valid port number or service name", port)
+		}
+		number = uint64(lookedUp)
+	}
+
+	if number == 0 {
+		return address, port, fmt.Errorf("port %s is out of range", port)
+	}
+
+	return address, strconv.FormatUint(number, 10), nil
 }
 
 // NewUdpNameserver creates a NameServerConcrete instance using UDP protocol.
-func NewUdpNameserver(address, port string) NameServer {
-	return &NameServerConcrete{
+// The address can be an IP address or a hostname. Queries with a truncated response are retried over TCP.
+func NewUdpNameserver(address, port string, opts ...NameServerOption) NameServer {
+	return newNameServerConcrete(&NameServerConcrete{
 		protocol: udp,
 		address:  address,
 		port:     port,
 		client: &dns.Client{
 			Net: string(udp),
 		},
-	}
+		fallback: &dns.Client{
+			Net: string(tcp),
+		},
+	}, opts)
 }
 
 // NewTcpNameserver creates a NameServerConcrete instance using TCP protocol.
-func NewTcpNameserver(address, port string) NameServer {
-	return &NameServerConcrete{
+// The address can be an IP address or a hostname. Connections are kept open and reused; see WithIdleConnections.
+func NewTcpNameserver(address, port string, opts ...NameServerOption) NameServer {
+	return newNameServerConcrete(&NameServerConcrete{
 		protocol: tcp,
 		address:  address,
 		port:     port,
-		client: &dns.Client{
+		client: newPooledClient(&dns.Client{
 			Net: string(tcp),
-		},
-	}
+		}),
+	}, opts)
 }
 
 // NewTlsNameserver creates a NameServerConcrete instance using TCP over TLS protocol.
-// The domain parameter is required for TLS certificate verification.
-func NewTlsNameserver(address, port, domain string) NameServer {
-	return &NameServerConcrete{
+// The address can be an IP address or a hostname. The domain parameter is required for TLS certificate verification.
+// Connections are kept open and reused; see WithIdleConnections.
+func NewTlsNameserver(address, port, domain string, opts ...NameServerOption) NameServer {
+	return newNameServerConcrete(&NameServerConcrete{
 		protocol: tcpTls,
 		address:  address,
 		port:     port,
 		domain:   domain,
-		client: &dns.Client{
+		padding:  DefaultPaddingPolicy,
+		client: newPooledClient(&dns.Client{
 			Net: string(tcpTls),
 			TLSConfig: &tls.Config{
 				ServerName: domain,
 			},
-		},
-	}
+		}),
+	}, opts)
 }
 
 // String returns a human-readable string representation of the NameServerConcrete details.
@@ -91,6 +281,11 @@ func (n NameServerConcrete) String() string {
 	return details
 }
 
+// Err returns why the address, port or options given to the NameServerConcrete are invalid, or nil if they're valid.
+func (n NameServerConcrete) Err() error {
+	return n.err
+}
+
 // getAddress returns the IP address of the NameServerConcrete, formatted for IPv4 or IPv6.
 func (n NameServerConcrete) getAddress() string {
 	if n.isIPv6() {
@@ -104,6 +299,26 @@ func (n NameServerConcrete) getConnectionString() string {
 	return fmt.Sprintf("%s:%s", n.getAddress(), n.port)
 }
 
+// getDialString returns the connection string used to reach the NameServerConcrete, resolving the hostname if needed.
+func (n NameServerConcrete) getDialString(ctx context.Context) (string, error) {
+	if n.bootstrap == nil {
+		return n.getConnectionString(), nil
+	}
+
+	address, err := n.bootstrap.resolve(ctx, n.address)
+	if err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(address, n.port), nil
+}
+
+// isIPAddress checks if an address is an IPv4 or IPv6 address, including IPv6 addresses with a zone index.
+func isIPAddress(address string) bool {
+	_, err := netip.ParseAddr(address)
+	return err == nil
+}
+
 // isIPv6 checks if the NameServerConcrete address is IPv6.
 func (n NameServerConcrete) isIPv6() bool {
 	return strings.Count(n.address, ":") >= 2
@@ -111,19 +326,202 @@ func (n NameServerConcrete) isIPv6() bool {
 
 // Query sends a DNS query to the NameServerConcrete.
 func (n NameServerConcrete) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(name), rrtype)
-	msg.SetEdns0(4096, true)
-	msg.RecursionDesired = true
+	return n.QueryContext(context.Background(), name, rrtype)
+}
+
+// QueryContext sends a DNS query to the NameServerConcrete, stopping when ctx is done.
+func (n NameServerConcrete) QueryContext(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	msg := newQueryMsg(name, rrtype)
+	addClientSubnet(msg, n.clientSubnet)
+	addPadding(msg, n.padding, n.tsig)
+	addTsig(msg, n.tsig)
+
+	if n.err != nil {
+		return nil, 0, fmt.Errorf("invalid nameserver %s: %w", n.String(), n.err)
+	}
 
-	response, rtt, err := n.client.Exchange(msg, n.getConnectionString())
+	if err := waitForRateLimit(ctx, n.limiter); err != nil {
+		return nil, 0, err
+	}
+
+	address, err := n.getDialString(ctx)
 	if err != nil {
+		return nil, 0, err
+	}
+
+	if n.edns != nil && !n.edns.supported() {
+		removeEdns0(msg)
+	}
+
+	response, rtt, err := n.exchange(ctx, msg, address)
+
+	if msg.IsEdns0() != nil && isEdnsFailure(response, err) && ctx.Err() == nil {
+		// Some servers, or middleboxes in front of them, don't cope with EDNS(0). If the query succeeds
+		// without it, remember that, so later queries don't wait on a failure first.
+		plain := msg.Copy()
+		removeEdns0(plain)
+
+		plainResponse, plainRtt, plainErr := n.exchange(ctx, plain, address)
+		rtt = rtt + plainRtt
+		if plainErr == nil && !isEdnsFailure(plainResponse, plainErr) {
+			if n.edns != nil {
+				n.edns.markUnsupported()
+			}
+			msg, response, err = plain, plainResponse, nil
+		}
+	}
+
+	if err == nil && response.Truncated && n.fallback != nil {
+		// The full response didn't fit in a UDP message, so ask again over TCP (RFC 7766, section 5).
+		var tcpRtt time.Duration
+		response, tcpRtt, err = n.exchangeUsing(ctx, n.fallback, msg, address)
+		rtt = rtt + tcpRtt
+		if err != nil {
+			err = fmt.Errorf("response was truncated, and retrying over tcp failed: %w", err)
+		}
+	}
+
+	if err != nil {
+		if n.bootstrap != nil && ctx.Err() == nil {
+			// The address may have changed, so resolve it again on the next query.
+			n.bootstrap.reset()
+		}
 		return response, rtt, err
 	}
 
 	if response.Rcode != dns.RcodeSuccess {
-		return response, rtt, fmt.Errorf("query error returned (rcode %d)", response.Rcode)
+		return response, rtt, newRcodeError(name, rrtype, n.String(), response.Rcode)
+	}
+
+	// The client verifies signed responses, but accepts unsigned ones.
+	if n.tsig != nil && response.IsTsig() == nil {
+		return response, rtt, fmt.Errorf("response was not signed with tsig key %s", n.tsig.Name)
 	}
 
 	return response, rtt, nil
 }
+
+// exchange sends a message using the NameServerConcrete's client.
+func (n NameServerConcrete) exchange(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	return n.exchangeUsing(ctx, n.client, msg, address)
+}
+
+// exchangeUsing sends a message using a client, capturing the message and its response if a PcapWriter is set.
+func (n NameServerConcrete) exchangeUsing(ctx context.Context, client DNSClient, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	n.pcap.writeMsg(msg, msg.Id, address, true)
+	response, rtt, err := exchangeWith(ctx, client, msg, address)
+	n.pcap.writeMsg(response, msg.Id, address, false)
+	return response, rtt, err
+}
+
+// exchangeWith sends a message using a client, via ExchangeContext if it supports contexts.
+func exchangeWith(ctx context.Context, client DNSClient, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	if c, ok := client.(ContextDNSClient); ok {
+		return c.ExchangeContext(ctx, msg, address)
+	}
+	return client.Exchange(msg, address)
+}
+
+// Close releases any connections held open by the client between queries.
+func (n NameServerConcrete) Close() error {
+	if closer, ok := n.client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// newQueryMsg creates the DNS query message sent to a nameserver, requesting recursion and DNSSEC records.
+func newQueryMsg(name string, rrtype uint16) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), rrtype)
+	msg.SetEdns0(4096, true)
+	msg.RecursionDesired = true
+	return msg
+}
+
+// removeEdns0 removes the OPT record, and so EDNS(0), from a message.
+func removeEdns0(msg *dns.Msg) {
+	extra := make([]dns.RR, 0, len(msg.Extra))
+	for _, rr := range msg.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	msg.Extra = extra
+}
+
+// isEdnsFailure checks if the outcome of a query is an explicit rejection of its OPT record, as a server not supporting
+// EDNS(0) gives: a FORMERR, NOTIMP or BADVERS response. A timeout isn't taken as one; it's more often transient, and
+// querying without EDNS(0) also drops the DO bit, so answers couldn't be authenticated until it was tried again.
+func isEdnsFailure(response *dns.Msg, err error) bool {
+	if err != nil || response == nil {
+		return false
+	}
+	switch response.Rcode {
+	case dns.RcodeFormatError, dns.RcodeNotImplemented, dns.RcodeBadVers:
+		return true
+	}
+	return false
+}
+
+//---
+
+// ednsReprobeInterval is how long a name server found not to support EDNS(0) is queried without it, before trying it again.
+const ednsReprobeInterval = 10 * time.Minute
+
+// edns remembers whether a name server supports EDNS(0).
+type edns struct {
+	mu          sync.Mutex
+	unsupported time.Time // When the name server was last found not to support EDNS(0); zero if it does
+}
+
+// supported checks if EDNS(0) should be used with the name server.
+func (e *edns) supported() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.unsupported.IsZero() || time.Since(e.unsupported) > ednsReprobeInterval
+}
+
+// markUnsupported records that the name server has been found not to support EDNS(0).
+func (e *edns) markUnsupported() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.unsupported = time.Now()
+}
+
+//---
+
+// bootstrap resolves, and remembers, the IP address of a nameserver configured with a hostname.
+type bootstrap struct {
+	mu       sync.Mutex
+	resolver BootstrapResolver
+	address  string // The most recently resolved IP address; empty when the hostname needs resolving
+}
+
+// resolve returns the IP address for the hostname, looking it up if one is not already known.
+func (b *bootstrap) resolve(ctx context.Context, hostname string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.address != "" {
+		return b.address, nil
+	}
+
+	addresses, err := b.resolver.LookupIPAddr(ctx, hostname)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve nameserver hostname %s: %w", hostname, err)
+	}
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("unable to resolve nameserver hostname %s: no addresses found", hostname)
+	}
+
+	b.address = addresses[0].String()
+	return b.address, nil
+}
+
+// reset forgets the resolved address, forcing the hostname to be resolved again.
+func (b *bootstrap) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.address = ""
+}
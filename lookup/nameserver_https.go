@@ -0,0 +1,271 @@
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// https represents DNS over HTTPS (DoH) connections.
+const https protocol = "https"
+
+// dohContentType is the media type of DNS messages sent and received over HTTPS.
+const dohContentType = "application/dns-message"
+
+// dohMaxResponseSize is the largest response body that will be read from a DoH nameserver.
+const dohMaxResponseSize = 65535
+
+// templateExpression matches an RFC 6570 expression within a URL template, e.g. {?dns}.
+var templateExpression = regexp.MustCompile(`\{([?&]?)([^}]*)}`)
+
+// HTTPClient interface abstracts the http.Client to allow custom clients, and mocking in tests.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HttpsNameServer represents a DNS over HTTPS (DoH) name server, as defined in RFC 8484.
+type HttpsNameServer struct {
+	template     string            // RFC 6570 URL template of the name server, e.g. https://dns.google/dns-query{?dns}
+	method       string            // HTTP method used for queries: GET or POST
+	client       HTTPClient        // HTTP client for sending queries
+	proxy        string            // URL of the proxy to send requests via; when empty, the proxy environment variables are used
+	retry        *RetryPolicy      // Overrides the DnsLookup's RetryPolicy when set
+	clientSubnet *dns.EDNS0_SUBNET // Added to each query when set
+	padding      PaddingPolicy     // How queries are padded
+	limiter      *rate.Limiter     // Limits the rate queries are sent at when set
+	pcap         *PcapWriter       // Captures queries and responses when set
+	err          error             // Set when the template, method, proxy or options given were invalid
+}
+
+// HttpsNameServerOption configures optional behaviour on an HttpsNameServer.
+type HttpsNameServerOption func(*HttpsNameServer)
+
+// WithHttpMethod sets the HTTP method used for queries, either http.MethodGet or http.MethodPost.
+// GET requests can be cached by HTTP caches; POST requests are smaller. Defaults to GET.
+func WithHttpMethod(method string) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		n.method = strings.ToUpper(method)
+	}
+}
+
+// WithHttpProxy sets the URL of a proxy to send requests via, e.g. http://proxy.example.net:3128.
+// Without it, the HTTPS_PROXY and NO_PROXY environment variables are honoured.
+func WithHttpProxy(proxy string) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		n.proxy = proxy
+	}
+}
+
+// WithHttpClient sets the HTTP client used to send queries, e.g. an *http.Client with a custom transport.
+// It can't be combined with WithHttpProxy; configure the proxy on the client instead.
+func WithHttpClient(client HTTPClient) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		n.client = client
+	}
+}
+
+// NewHttpsNameserver creates an HttpsNameServer instance using DNS over HTTPS.
+// The template is either a plain https URL, or an RFC 6570 URL template containing a dns variable.
+func NewHttpsNameserver(template string, opts ...HttpsNameServerOption) NameServer {
+	n := &HttpsNameServer{
+		template: template,
+		method:   http.MethodGet,
+		padding:  DefaultPaddingPolicy,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	if n.client != nil {
+		if n.proxy != "" {
+			n.err = fmt.Errorf("a proxy can't be set when a custom http client is used")
+		}
+	} else {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyFromEnvironment
+		n.client = &http.Client{Transport: transport}
+
+		if n.proxy != "" {
+			if proxy, err := url.Parse(n.proxy); err != nil || proxy.Host == "" {
+				n.err = fmt.Errorf("invalid proxy url %s", n.proxy)
+			} else {
+				transport.Proxy = http.ProxyURL(proxy)
+			}
+		}
+	}
+
+	if n.err != nil {
+		return n
+	} else if n.method != http.MethodGet && n.method != http.MethodPost {
+		n.err = fmt.Errorf("unsupported http method %s", n.method)
+	} else if u, err := url.Parse(expandTemplate(n.template, "")); err != nil {
+		n.err = fmt.Errorf("invalid url template: %w", err)
+	} else if u.Scheme != "https" || u.Host == "" {
+		n.err = fmt.Errorf("url template must be an absolute https url")
+	}
+
+	return n
+}
+
+// String returns a human-readable string representation of the HttpsNameServer details.
+func (n HttpsNameServer) String() string {
+	return fmt.Sprintf("%s (%s)", n.template, n.method)
+}
+
+// Err returns why the template, method, proxy or options given to the HttpsNameServer are invalid, or nil if they're
+// valid.
+func (n HttpsNameServer) Err() error {
+	return n.err
+}
+
+// Close closes any idle connections held by the HTTP client. Connections in use by in-flight queries are unaffected.
+func (n HttpsNameServer) Close() error {
+	if client, ok := n.client.(interface{ CloseIdleConnections() }); ok {
+		client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Query sends a DNS query to the HttpsNameServer.
+func (n HttpsNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	return n.QueryContext(context.Background(), name, rrtype)
+}
+
+// QueryContext sends a DNS query to the HttpsNameServer, stopping when ctx is done.
+func (n HttpsNameServer) QueryContext(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	if n.err != nil {
+		return nil, 0, fmt.Errorf("invalid nameserver %s: %w", n.String(), n.err)
+	}
+
+	if err := waitForRateLimit(ctx, n.limiter); err != nil {
+		return nil, 0, err
+	}
+
+	msg := newQueryMsg(name, rrtype)
+	addClientSubnet(msg, n.clientSubnet)
+	addPadding(msg, n.padding, nil)
+
+	// RFC 8484 recommends an ID of 0, making GET requests more cache friendly.
+	msg.Id = 0
+
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	request, err := n.newRequest(ctx, packed)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	port := request.URL.Port()
+	if port == "" {
+		port = "443"
+	}
+	server := net.JoinHostPort(request.URL.Hostname(), port)
+	n.pcap.write(packed, msg.Id, server, true)
+
+	start := time.Now()
+	response, err := n.client.Do(request)
+	if err != nil {
+		return nil, time.Since(start), err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, dohMaxResponseSize))
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, rtt, fmt.Errorf("unexpected http status returned (%s)", response.Status)
+	}
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, dohContentType) {
+		return nil, rtt, fmt.Errorf("unexpected content type returned (%s)", contentType)
+	}
+
+	n.pcap.write(body, msg.Id, server, false)
+
+	result := new(dns.Msg)
+	if err = result.Unpack(body); err != nil {
+		return nil, rtt, err
+	}
+
+	if result.Rcode != dns.RcodeSuccess {
+		return result, rtt, newRcodeError(name, rrtype, n.String(), result.Rcode)
+	}
+
+	return result, rtt, nil
+}
+
+// newRequest builds the HTTP request for a packed DNS query, using the HttpsNameServer's method.
+func (n HttpsNameServer) newRequest(ctx context.Context, packed []byte) (*http.Request, error) {
+	var request *http.Request
+	var err error
+
+	if n.method == http.MethodGet {
+		request, err = http.NewRequestWithContext(ctx, http.MethodGet, expandTemplate(n.template, base64.RawURLEncoding.EncodeToString(packed)), nil)
+	} else {
+		request, err = http.NewRequestWithContext(ctx, http.MethodPost, expandTemplate(n.template, ""), bytes.NewReader(packed))
+		if err == nil {
+			request.Header.Set("Content-Type", dohContentType)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", dohContentType)
+	return request, nil
+}
+
+// expandTemplate expands an RFC 6570 URL template, setting the dns variable to the given value.
+// An empty value leaves the variable undefined, which removes it from the expansion. If the template
+// doesn't contain a dns variable, but a value is given, it's appended as a query parameter.
+func expandTemplate(template, dnsValue string) string {
+	used := false
+
+	expanded := templateExpression.ReplaceAllStringFunc(template, func(expression string) string {
+		parts := templateExpression.FindStringSubmatch(expression)
+		operator, variables := parts[1], strings.Split(parts[2], ",")
+
+		for _, variable := range variables {
+			if variable != "dns" {
+				continue
+			}
+			used = true
+			if dnsValue == "" {
+				return ""
+			}
+			if operator == "" {
+				return dnsValue
+			}
+			return operator + "dns=" + dnsValue
+		}
+
+		// No variables we know of, so all are undefined.
+		return ""
+	})
+
+	if !used && dnsValue != "" {
+		separator := "?"
+		if strings.Contains(expanded, "?") {
+			separator = "&"
+		}
+		expanded = expanded + separator + "dns=" + dnsValue
+	}
+
+	return expanded
+}
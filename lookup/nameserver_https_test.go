@@ -0,0 +1,179 @@
+package lookup
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDohTestServer returns a TLS test server that answers DoH queries, recording the last request it received.
+func newDohTestServer(t *testing.T, lastRequest **http.Request) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lastRequest = r
+
+		var packed []byte
+		var err error
+		if r.Method == http.MethodGet {
+			packed, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		} else {
+			packed, err = io.ReadAll(r.Body)
+		}
+		require.NoError(t, err)
+
+		query := new(dns.Msg)
+		require.NoError(t, query.Unpack(packed))
+
+		response := newLookupResponseMsgWithAD(dns.RcodeSuccess, true)
+		response.SetReply(query)
+
+		packed, err = response.Pack()
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", dohContentType)
+		w.Write(packed)
+	}))
+}
+
+func TestHttpsNameServer_Query(t *testing.T) {
+	var lastRequest *http.Request
+	server := newDohTestServer(t, &lastRequest)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		template string
+		method   string
+		path     string
+	}{
+		{name: "GET with template", template: server.URL + "/dns-query{?dns}", method: http.MethodGet, path: "/dns-query"},
+		{name: "GET without template", template: server.URL + "/resolve", method: http.MethodGet, path: "/resolve"},
+		{name: "POST with template", template: server.URL + "/dns-query{?dns}", method: http.MethodPost, path: "/dns-query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := NewHttpsNameserver(tt.template, WithHttpMethod(tt.method), WithHttpClient(server.Client()))
+
+			resp, _, err := ns.Query("example.com", dns.TypeA)
+			require.NoError(t, err)
+			assert.Equal(t, "example.com.", resp.Question[0].Name)
+			assert.Len(t, resp.Answer, 1)
+
+			assert.Equal(t, tt.method, lastRequest.Method)
+			assert.Equal(t, tt.path, lastRequest.URL.Path)
+			assert.Equal(t, dohContentType, lastRequest.Header.Get("Accept"))
+			if tt.method == http.MethodPost {
+				assert.Equal(t, dohContentType, lastRequest.Header.Get("Content-Type"))
+				assert.Empty(t, lastRequest.URL.RawQuery)
+			} else {
+				assert.NotEmpty(t, lastRequest.URL.Query().Get("dns"))
+			}
+		})
+	}
+}
+
+func TestHttpsNameServer_QueryInvalid(t *testing.T) {
+	ns := NewHttpsNameserver("http://dns.example.net/dns-query")
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.ErrorContains(t, err, "url template must be an absolute https url")
+
+	ns = NewHttpsNameserver("https://dns.example.net/dns-query", WithHttpMethod(http.MethodPut))
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	assert.ErrorContains(t, err, "unsupported http method PUT")
+}
+
+func TestExpandTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		value    string
+		expected string
+	}{
+		{template: "https://dns.example.net/dns-query{?dns}", value: "AAAB", expected: "https://dns.example.net/dns-query?dns=AAAB"},
+		{template: "https://dns.example.net/dns-query{?dns}", value: "", expected: "https://dns.example.net/dns-query"},
+		{template: "https://dns.example.net/dns-query?ct{&dns}", value: "AAAB", expected: "https://dns.example.net/dns-query?ct&dns=AAAB"},
+		{template: "https://dns.example.net/{dns}", value: "AAAB", expected: "https://dns.example.net/AAAB"},
+		{template: "https://dns.example.net/dns-query{?other,dns}", value: "AAAB", expected: "https://dns.example.net/dns-query?dns=AAAB"},
+		{template: "https://dns.example.net/dns-query{?other}", value: "AAAB", expected: "https://dns.example.net/dns-query?dns=AAAB"},
+		{template: "https://dns.example.net/dns-query", value: "AAAB", expected: "https://dns.example.net/dns-query?dns=AAAB"},
+		{template: "https://dns.example.net/dns-query?a=b", value: "AAAB", expected: "https://dns.example.net/dns-query?a=b&dns=AAAB"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, expandTemplate(tt.template, tt.value), tt.template)
+	}
+}
+
+func TestHttpsNameServer_Proxy(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodGet, "https://dns.example.net/dns-query", nil)
+
+	// Without a proxy option, the environment is used.
+	ns := NewHttpsNameserver("https://dns.example.net/dns-query").(*HttpsNameServer)
+	assert.NotNil(t, ns.client.(*http.Client).Transport.(*http.Transport).Proxy)
+
+	ns = NewHttpsNameserver("https://dns.example.net/dns-query", WithHttpProxy("http://proxy.example.net:3128")).(*HttpsNameServer)
+	proxy, err := ns.client.(*http.Client).Transport.(*http.Transport).Proxy(request)
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.net:3128", proxy.String())
+
+	ns = NewHttpsNameserver("https://dns.example.net/dns-query", WithHttpProxy("not a url")).(*HttpsNameServer)
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	assert.ErrorContains(t, err, "invalid proxy url not a url")
+}
+
+// mockHTTPClient is a mock implementation of the HTTPClient interface for testing purposes.
+type mockHTTPClient struct {
+	err     error
+	lastReq *http.Request
+}
+
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	m.lastReq = req
+	return nil, m.err
+}
+
+func TestHttpsNameServer_Client(t *testing.T) {
+	client := &mockHTTPClient{err: fmt.Errorf("network error")}
+
+	ns := NewHttpsNameserver("https://dns.example.net/dns-query{?dns}", WithHttpClient(client))
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.EqualError(t, err, "network error")
+	require.NotNil(t, client.lastReq)
+	assert.Equal(t, "dns.example.net", client.lastReq.URL.Host)
+
+	ns = NewHttpsNameserver("https://dns.example.net/dns-query", WithHttpClient(client), WithHttpProxy("http://proxy.example.net:3128"))
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	assert.ErrorContains(t, err, "a proxy can't be set when a custom http client is used")
+}
+
+// closingHTTPClient is a mock HTTPClient that records calls to CloseIdleConnections.
+type closingHTTPClient struct {
+	mockHTTPClient
+	closed int
+}
+
+func (c *closingHTTPClient) CloseIdleConnections() {
+	c.closed++
+}
+
+func TestHttpsNameServer_Close(t *testing.T) {
+	client := &closingHTTPClient{}
+
+	ns := NewHttpsNameserver("https://dns.example.net/dns-query", WithHttpClient(client))
+	closer, ok := ns.(io.Closer)
+	require.True(t, ok)
+
+	assert.NoError(t, closer.Close())
+	assert.Equal(t, 1, client.closed)
+
+	// DnsLookup closes its nameservers when shut down.
+	d := NewDnsLookup([]NameServer{ns})
+	assert.NoError(t, d.Close())
+	assert.Equal(t, 2, client.closed)
+}
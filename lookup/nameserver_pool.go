@@ -0,0 +1,137 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Defaults for the idle connections kept open by TCP and TLS nameservers.
+const (
+	defaultMaxIdleConnections = 4
+	defaultIdleTimeout        = 30 * time.Second
+)
+
+// WithIdleConnections sets how many connections a TCP or TLS nameserver keeps open between queries, and how long
+// they're kept for once idle. A maxIdle of zero opens a new connection for every query. It has no effect on other
+// nameservers.
+func WithIdleConnections(maxIdle int, idleTimeout time.Duration) NameServerOption {
+	return func(n *NameServerConcrete) {
+		if pool, ok := n.client.(*pooledClient); ok {
+			pool.maxIdle = maxIdle
+			pool.idleTimeout = idleTimeout
+		}
+	}
+}
+
+// pooledClient is a DNSClient that reuses connections between queries, rather than opening one per query. This
+// avoids a TCP, and for TLS a TLS, handshake on every query. A connection that's been closed by the server whilst
+// idle is replaced transparently.
+type pooledClient struct {
+	client      *dns.Client
+	maxIdle     int
+	idleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle map[string][]*pooledConn // Idle connections per address, most recently used last
+}
+
+type pooledConn struct {
+	*dns.Conn
+	lastUsed time.Time
+}
+
+func newPooledClient(client *dns.Client) *pooledClient {
+	return &pooledClient{
+		client:      client,
+		maxIdle:     defaultMaxIdleConnections,
+		idleTimeout: defaultIdleTimeout,
+		idle:        make(map[string][]*pooledConn),
+	}
+}
+
+// Exchange sends a query on an idle connection to the address, or a new one if there are none.
+func (p *pooledClient) Exchange(m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	return p.ExchangeContext(context.Background(), m, address)
+}
+
+// ExchangeContext sends a query on an idle connection to the address, or a new one if there are none, stopping when
+// ctx is done.
+func (p *pooledClient) ExchangeContext(ctx context.Context, m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	for {
+		conn, reused, err := p.get(ctx, address)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		response, rtt, err := p.client.ExchangeWithConnContext(ctx, m, conn.Conn)
+		if err == nil {
+			p.put(address, conn)
+			return response, rtt, nil
+		}
+		conn.Close()
+
+		// The server may have closed an idle connection; if so, try again on another.
+		var netErr net.Error
+		if reused && ctx.Err() == nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+			continue
+		}
+		return response, rtt, err
+	}
+}
+
+// get returns the most recently used idle connection to the address, or dials a new one. Idle connections past the
+// idle timeout are closed.
+func (p *pooledClient) get(ctx context.Context, address string) (*pooledConn, bool, error) {
+	p.mu.Lock()
+	conns := p.idle[address]
+	for len(conns) > 0 {
+		conn := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if time.Since(conn.lastUsed) < p.idleTimeout {
+			p.idle[address] = conns
+			p.mu.Unlock()
+			return conn, true, nil
+		}
+		conn.Close()
+	}
+	delete(p.idle, address)
+	p.mu.Unlock()
+
+	conn, err := p.client.DialContext(ctx, address)
+	if err != nil {
+		return nil, false, err
+	}
+	return &pooledConn{Conn: conn}, false, nil
+}
+
+// put returns a connection to the idle pool, closing it if the pool is full.
+func (p *pooledClient) put(address string, conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle[address]) >= p.maxIdle {
+		conn.Close()
+		return
+	}
+	conn.lastUsed = time.Now()
+	p.idle[address] = append(p.idle[address], conn)
+}
+
+// Close closes the idle connections. Later queries open new ones.
+func (p *pooledClient) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for address, conns := range p.idle {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(p.idle, address)
+	}
+	return nil
+}
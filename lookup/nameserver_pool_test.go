@@ -0,0 +1,111 @@
+package lookup
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingListener counts the connections accepted.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+// newTcpTestServer starts a TCP server answering every query with an A record, which closes connections that are
+// idle for longer than idleTimeout.
+func newTcpTestServer(t *testing.T, idleTimeout time.Duration) (*countingListener, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	counting := &countingListener{Listener: listener}
+
+	server := &dns.Server{
+		Listener:    counting,
+		IdleTimeout: func() time.Duration { return idleTimeout },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			msg := new(dns.Msg)
+			msg.SetReply(r)
+			rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A 192.0.2.1")
+			msg.Answer = []dns.RR{rr}
+			w.WriteMsg(msg)
+		}),
+	}
+	go server.ActivateAndServe()
+	t.Cleanup(func() {
+		server.Shutdown()
+	})
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return counting, port
+}
+
+func TestPooledClient_ReusesConnections(t *testing.T) {
+	listener, port := newTcpTestServer(t, time.Minute)
+
+	ns := NewTcpNameserver("127.0.0.1", port).(*NameServerConcrete)
+	defer ns.Close()
+
+	for i := 0; i < 5; i++ {
+		msg, _, err := ns.Query("example.com.", dns.TypeA)
+		require.NoError(t, err)
+		assert.Len(t, msg.Answer, 1)
+	}
+	assert.Equal(t, int32(1), listener.accepted.Load())
+
+	// Once closed, a new connection is opened.
+	require.NoError(t, ns.Close())
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), listener.accepted.Load())
+}
+
+func TestPooledClient_ReconnectsAfterServerCloses(t *testing.T) {
+	listener, port := newTcpTestServer(t, 50*time.Millisecond)
+
+	ns := NewTcpNameserver("127.0.0.1", port)
+
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+
+	// The server closes the idle connection; the next query reconnects transparently.
+	time.Sleep(200 * time.Millisecond)
+
+	msg, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Len(t, msg.Answer, 1)
+	assert.Equal(t, int32(2), listener.accepted.Load())
+}
+
+func TestPooledClient_IdleTimeout(t *testing.T) {
+	listener, port := newTcpTestServer(t, time.Minute)
+
+	ns := NewTcpNameserver("127.0.0.1", port, WithIdleConnections(1, 50*time.Millisecond))
+
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), listener.accepted.Load())
+
+	// Without idle connections, every query opens a new one.
+	ns = NewTcpNameserver("127.0.0.1", port, WithIdleConnections(0, 0))
+	for i := 0; i < 2; i++ {
+		_, _, err = ns.Query("example.com.", dns.TypeA)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(4), listener.accepted.Load())
+}
@@ -0,0 +1,199 @@
+package lookup
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// quicProtocol represents DNS over QUIC (DoQ) connections.
+const quicProtocol protocol = "quic"
+
+// doqTimeout is how long a DoQ query may take to establish a connection, send the query, and read the response, each,
+// unless other timeouts are set with WithTimeouts.
+const doqTimeout = 5 * time.Second
+
+// doqIdleTimeout is how long an idle DoQ connection is kept open for reuse.
+const doqIdleTimeout = 30 * time.Second
+
+// NewQuicNameserver creates a NameServerConcrete instance using DNS over QUIC (DoQ), as defined in RFC 9250.
+// The address can be an IP address or a hostname. The domain parameter is required for TLS certificate verification.
+// A connection is kept open and reused by later queries, each of which is sent on its own stream.
+func NewQuicNameserver(address, port, domain string, opts ...NameServerOption) NameServer {
+	return newNameServerConcrete(&NameServerConcrete{
+		protocol: quicProtocol,
+		address:  address,
+		port:     port,
+		domain:   domain,
+		padding:  DefaultPaddingPolicy,
+		client: newQuicClient(&tls.Config{
+			ServerName: domain,
+			NextProtos: []string{"doq"},
+		}),
+	}, opts)
+}
+
+// quicClient is a DNSClient that sends queries over QUIC, reusing a connection per address.
+type quicClient struct {
+	tlsConfig *tls.Config
+
+	// Zero timeouts default to doqTimeout.
+	dialTimeout, readTimeout, writeTimeout time.Duration
+
+	mu    sync.Mutex
+	conns map[string]quic.Connection
+}
+
+func newQuicClient(tlsConfig *tls.Config) *quicClient {
+	return &quicClient{
+		tlsConfig: tlsConfig,
+		conns:     make(map[string]quic.Connection),
+	}
+}
+
+// Exchange sends a query on a new stream, then reads the response from it.
+func (c *quicClient) Exchange(m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	return c.ExchangeContext(context.Background(), m, address)
+}
+
+// ExchangeContext sends a query on a new stream, then reads the response from it, stopping when ctx is done.
+func (c *quicClient) ExchangeContext(ctx context.Context, m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	start := time.Now()
+
+	// RFC 9250 requires the message ID to be 0; the stream identifies the query.
+	query := m.Copy()
+	query.Id = 0
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, quicTimeout(c.dialTimeout))
+	stream, err := c.openStream(dialCtx, address)
+	cancel()
+	if err != nil {
+		return nil, time.Since(start), err
+	}
+
+	if err = stream.SetWriteDeadline(streamDeadline(ctx, quicTimeout(c.writeTimeout))); err != nil {
+		return nil, time.Since(start), err
+	}
+
+	// Messages are prefixed with their length, as over TCP. Closing the stream signals the query is complete.
+	if _, err = stream.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed)))); err == nil {
+		_, err = stream.Write(packed)
+	}
+	if err != nil {
+		stream.CancelRead(0)
+		return nil, time.Since(start), err
+	}
+	if err = stream.Close(); err != nil {
+		return nil, time.Since(start), err
+	}
+
+	if err = stream.SetReadDeadline(streamDeadline(ctx, quicTimeout(c.readTimeout))); err != nil {
+		stream.CancelRead(0)
+		return nil, time.Since(start), err
+	}
+
+	length := make([]byte, 2)
+	if _, err = io.ReadFull(stream, length); err != nil {
+		return nil, time.Since(start), err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err = io.ReadFull(stream, body); err != nil {
+		return nil, time.Since(start), err
+	}
+
+	response := new(dns.Msg)
+	if err = response.Unpack(body); err != nil {
+		return nil, time.Since(start), fmt.Errorf("unable to parse response: %w", err)
+	}
+
+	return response, time.Since(start), nil
+}
+
+// quicTimeout returns the timeout, or doqTimeout if it's zero.
+func quicTimeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return doqTimeout
+	}
+	return timeout
+}
+
+// streamDeadline returns when a stream operation with the given timeout must complete by, which is no later than ctx's
+// deadline.
+func streamDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// openStream opens a stream on the connection to the address, establishing a new connection if there's no open one.
+// The lock is only held to look up and store connections, so a slow handshake doesn't hold up other queries.
+func (c *quicClient) openStream(ctx context.Context, address string) (quic.Stream, error) {
+	c.mu.Lock()
+	conn, ok := c.conns[address]
+	c.mu.Unlock()
+
+	if ok {
+		stream, err := conn.OpenStreamSync(ctx)
+		if err == nil || ctx.Err() != nil {
+			return stream, err
+		}
+		// The connection has been closed, e.g. after being idle, so it's replaced.
+		c.remove(address, conn)
+	}
+
+	conn, err := quic.DialAddr(ctx, address, c.tlsConfig, &quic.Config{MaxIdleTimeout: doqIdleTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if current, found := c.conns[address]; found {
+		// Another query established a connection while this one was dialing, so that's used instead.
+		c.mu.Unlock()
+		_ = conn.CloseWithError(0, "")
+		conn = current
+	} else {
+		c.conns[address] = conn
+		c.mu.Unlock()
+	}
+
+	return conn.OpenStreamSync(ctx)
+}
+
+// remove closes a connection, and stops it being used for the address if it's still the current one.
+func (c *quicClient) remove(address string, conn quic.Connection) {
+	c.mu.Lock()
+	if c.conns[address] == conn {
+		delete(c.conns, address)
+	}
+	c.mu.Unlock()
+
+	_ = conn.CloseWithError(0, "")
+}
+
+// Close closes the open connections. Later queries establish new ones.
+func (c *quicClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for address, conn := range c.conns {
+		// The DOQ_NO_ERROR code signals the connection is no longer needed.
+		_ = conn.CloseWithError(0, "")
+		delete(c.conns, address)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package lookup
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns a self-signed certificate for localhost.
+func newTestCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// newDoqTestServer starts a DoQ server that answers every A query with 192.0.2.1, counting the connections made to it.
+func newDoqTestServer(t *testing.T, connections *atomic.Int32) *quic.Listener {
+	listener, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{newTestCertificate(t)},
+		NextProtos:   []string{"doq"},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			connections.Add(1)
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go serveDoqStream(stream)
+				}
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func serveDoqStream(stream quic.Stream) {
+	defer stream.Close()
+
+	body, err := io.ReadAll(stream)
+	if err != nil || len(body) < 2 {
+		return
+	}
+	query := new(dns.Msg)
+	if query.Unpack(body[2:]) != nil || query.Id != 0 {
+		return
+	}
+
+	response := new(dns.Msg)
+	response.SetReply(query)
+	rr, _ := dns.NewRR(query.Question[0].Name + " 300 IN A 192.0.2.1")
+	response.Answer = append(response.Answer, rr)
+
+	packed, _ := response.Pack()
+	_, _ = stream.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed))))
+	_, _ = stream.Write(packed)
+}
+
+func TestQuicNameServer_Query(t *testing.T) {
+	var connections atomic.Int32
+	listener := newDoqTestServer(t, &connections)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	ns := NewQuicNameserver("127.0.0.1", port, "localhost")
+	assert.Equal(t, "quic://127.0.0.1:"+port+"#localhost", ns.String())
+
+	// The test server's certificate is self-signed.
+	ns.(*NameServerConcrete).client = newQuicClient(&tls.Config{InsecureSkipVerify: true, NextProtos: []string{"doq"}})
+
+	for i := 0; i < 3; i++ {
+		msg, _, err := ns.Query("example.com", dns.TypeA)
+		require.NoError(t, err)
+		require.Len(t, msg.Answer, 1)
+		assert.Equal(t, "192.0.2.1", msg.Answer[0].(*dns.A).A.String())
+	}
+
+	// The connection is reused by later queries.
+	assert.Equal(t, int32(1), connections.Load())
+
+	// Once closed, a new connection is made.
+	assert.NoError(t, ns.(io.Closer).Close())
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), connections.Load())
+}
+
+func TestQuicNameServer_Timeouts(t *testing.T) {
+	// A server that accepts queries, but never answers them.
+	listener, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{newTestCertificate(t)},
+		NextProtos:   []string{"doq"},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					if _, err := conn.AcceptStream(context.Background()); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	ns := NewQuicNameserver("127.0.0.1", port, "localhost", WithTimeouts(0, 100*time.Millisecond, 0)).(*NameServerConcrete)
+	client := ns.client.(*quicClient)
+	assert.Equal(t, 100*time.Millisecond, client.readTimeout)
+	assert.Zero(t, client.dialTimeout)
+	client.tlsConfig = &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"doq"}}
+
+	start := time.Now()
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestQuicNameServer_Dial(t *testing.T) {
+	var connections atomic.Int32
+	listener := newDoqTestServer(t, &connections)
+	address := listener.Addr().String()
+
+	// Nothing answers on this address, so dialing it only ends when the timeout's reached.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		silent.Close()
+	})
+
+	client := newQuicClient(&tls.Config{InsecureSkipVerify: true, NextProtos: []string{"doq"}})
+	client.dialTimeout = 2 * time.Second
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	// A slow dial doesn't hold up queries to other servers.
+	dialing := make(chan error)
+	go func() {
+		_, _, err := client.Exchange(newQueryMsg("example.com.", dns.TypeA), silent.LocalAddr().String())
+		dialing <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	_, _, err = client.Exchange(newQueryMsg("example.com.", dns.TypeA), address)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Error(t, <-dialing)
+
+	// A connection that's been closed is replaced, and the new one is kept for later queries.
+	client.mu.Lock()
+	closed := client.conns[address]
+	client.mu.Unlock()
+	require.NoError(t, closed.CloseWithError(0, ""))
+
+	_, _, err = client.Exchange(newQueryMsg("example.com.", dns.TypeA), address)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), connections.Load())
+
+	client.mu.Lock()
+	assert.NotEqual(t, closed, client.conns[address])
+	client.mu.Unlock()
+}
+
+func TestQuicNameServer_Invalid(t *testing.T) {
+	ns := NewQuicNameserver("1.1.1.1", "99999", "one.one.one.one")
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.ErrorContains(t, err, "port 99999 is out of range")
+}
@@ -1,7 +1,11 @@
 package lookup
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -75,8 +79,8 @@ func TestNewTlsNameserver(t *testing.T) {
 	if ns.domain != domain {
 		t.Errorf("expected domain %v, got %v", domain, ns.domain)
 	}
-	if ns.client.(*dns.Client).TLSConfig.ServerName != domain {
-		t.Errorf("expected TLS ServerName %v, got %v", domain, ns.client.(*dns.Client).TLSConfig.ServerName)
+	if ns.client.(*pooledClient).client.TLSConfig.ServerName != domain {
+		t.Errorf("expected TLS ServerName %v, got %v", domain, ns.client.(*pooledClient).client.TLSConfig.ServerName)
 	}
 }
 
@@ -164,7 +168,7 @@ func TestNameServer_Query(t *testing.T) {
 			nameserver:            &NameServerConcrete{protocol: udp, address: "8.8.8.8", port: "53", client: &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeNameError, true), rtt: mockRtt, err: nil}},
 			mockResponse:          newNameserverResponseMsgWithAD(dns.RcodeNameError, true),
 			mockRtt:               mockRtt,
-			expectedErr:           "query error returned (rcode 3)",
+			expectedErr:           "example.com. A query to udp://8.8.8.8:53 returned NXDOMAIN",
 			expectedRcode:         dns.RcodeNameError,
 			expectedQuery:         newNameserverQueryMsg("example.com", dns.TypeA),
 			expectedAuthenticated: true,
@@ -241,3 +245,314 @@ func newNameserverQueryMsg(name string, rrtype uint16) *dns.Msg {
 	msg.RecursionDesired = true
 	return msg
 }
+
+// mockBootstrapResolver is a mock implementation of the BootstrapResolver interface for testing purposes.
+type mockBootstrapResolver struct {
+	addresses []net.IPAddr
+	err       error
+	calls     int
+}
+
+func (m *mockBootstrapResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	m.calls++
+	return m.addresses, m.err
+}
+
+func TestNameServer_QueryHostname(t *testing.T) {
+	resolver := &mockBootstrapResolver{addresses: []net.IPAddr{{IP: net.ParseIP("2001:db8::53")}}}
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	ns := NewTlsNameserver("dns.example.net", "853", "dns.example.net", WithBootstrapResolver(resolver)).(*NameServerConcrete)
+	ns.client = client
+
+	assert.Equal(t, "tcp-tls://dns.example.net:853#dns.example.net", ns.String())
+
+	// The hostname is resolved once, then the result is reused.
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.NoError(t, err)
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, "[2001:db8::53]:853", client.lastAddr)
+	assert.Equal(t, 1, resolver.calls)
+
+	// A failed exchange causes the hostname to be resolved again on the next query.
+	client.err = fmt.Errorf("network error")
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	assert.Error(t, err)
+	client.err = nil
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resolver.calls)
+}
+
+func TestNameServer_QueryHostnameResolutionFails(t *testing.T) {
+	resolver := &mockBootstrapResolver{err: fmt.Errorf("no such host")}
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	ns := NewUdpNameserver("dns.example.net", "53", WithBootstrapResolver(resolver)).(*NameServerConcrete)
+	ns.client = client
+
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.ErrorContains(t, err, "unable to resolve nameserver hostname dns.example.net")
+	assert.Nil(t, client.lastMsg)
+}
+
+func TestParseAddressAndPort(t *testing.T) {
+	tests := []struct {
+		name            string
+		protocol        protocol
+		address         string
+		port            string
+		expectedAddress string
+		expectedPort    string
+		expectedErr     string
+	}{
+		{name: "IPv4 address", protocol: udp, address: "1.1.1.1", port: "53", expectedAddress: "1.1.1.1", expectedPort: "53"},
+		{name: "IPv6 address", protocol: udp, address: "::1", port: "53", expectedAddress: "::1", expectedPort: "53"},
+		{name: "Bracketed IPv6 address", protocol: udp, address: "[::1]", port: "53", expectedAddress: "::1", expectedPort: "53"},
+		{name: "IPv6 address with zone index", protocol: udp, address: "fe80::1%eth0", port: "53", expectedAddress: "fe80::1%eth0", expectedPort: "53"},
+		{name: "Bracketed IPv6 address with zone index", protocol: udp, address: "[fe80::1%eth0]:53", port: "", expectedAddress: "fe80::1%eth0", expectedPort: "53"},
+		{name: "IPv6 address with escaped zone index", protocol: udp, address: "[fe80::1%25eth0]", port: "53", expectedAddress: "fe80::1%eth0", expectedPort: "53"},
+		{name: "Hostname", protocol: tcpTls, address: "dns.google", port: "853", expectedAddress: "dns.google", expectedPort: "853"},
+		{name: "Surrounding whitespace", protocol: udp, address: " 1.1.1.1 ", port: " 53 ", expectedAddress: "1.1.1.1", expectedPort: "53"},
+		{name: "IPv4 address including port", protocol: udp, address: "1.1.1.1:53", port: "", expectedAddress: "1.1.1.1", expectedPort: "53"},
+		{name: "IPv6 address including port", protocol: udp, address: "[::1]:53", port: "53", expectedAddress: "::1", expectedPort: "53"},
+		{name: "Service name", protocol: tcp, address: "1.1.1.1", port: "domain", expectedAddress: "1.1.1.1", expectedPort: "53"},
+		{name: "Leading zeros", protocol: tcp, address: "1.1.1.1", port: "053", expectedAddress: "1.1.1.1", expectedPort: "53"},
+		{name: "Conflicting ports", protocol: udp, address: "1.1.1.1:53", port: "5353", expectedErr: "address 1.1.1.1:53 includes port 53, which conflicts with port 5353"},
+		{name: "No address", protocol: udp, address: "", port: "53", expectedErr: "no address given"},
+		{name: "No port", protocol: udp, address: "1.1.1.1", port: "", expectedErr: "no port given"},
+		{name: "Port too large", protocol: udp, address: "1.1.1.1", port: "65536", expectedErr: "port 65536 is out of range"},
+		{name: "Port zero", protocol: udp, address: "1.1.1.1", port: "0", expectedErr: "port 0 is out of range"},
+		{name: "Unknown service name", protocol: udp, address: "1.1.1.1", port: "not-a-service", expectedErr: "port not-a-service is not a valid port number or service name"},
+		{name: "Empty zone index", protocol: udp, address: "fe80::1%", port: "53", expectedErr: "address fe80::1% is not a valid IPv6 address with zone index"},
+		{name: "Zone index on a hostname", protocol: udp, address: "dns.google%eth0", port: "53", expectedErr: "is not a valid IPv6 address with zone index"},
+		{name: "Invalid address", protocol: udp, address: "1.1.1.1/24", port: "53", expectedErr: "is not a valid IP address or hostname"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, port, err := parseAddressAndPort(tt.protocol, tt.address, tt.port)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedAddress, address)
+			assert.Equal(t, tt.expectedPort, port)
+		})
+	}
+}
+
+func TestNameServer_QueryInvalid(t *testing.T) {
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	ns := NewUdpNameserver("1.1.1.1", "99999").(*NameServerConcrete)
+	ns.client = client
+
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.EqualError(t, err, "invalid nameserver udp://1.1.1.1:99999: port 99999 is out of range")
+	assert.Nil(t, client.lastMsg)
+}
+
+func TestCheckNameServers(t *testing.T) {
+	valid := NewUdpNameserver("1.1.1.1", "53")
+	invalidPort := NewUdpNameserver("1.1.1.1", "99999")
+	invalidAddress := NewTcpNameserver("1.1.1.1/24", "53")
+	invalidTemplate := NewHttpsNameserver("http://dns.example.net/dns-query")
+
+	assert.NoError(t, valid.(CheckedNameServer).Err())
+	assert.EqualError(t, invalidPort.(CheckedNameServer).Err(), "port 99999 is out of range")
+	assert.EqualError(t, invalidTemplate.(CheckedNameServer).Err(), "url template must be an absolute https url")
+
+	assert.NoError(t, CheckNameServers([]NameServer{valid, &mockNameServer{}}))
+	assert.NoError(t, CheckNameServers(nil))
+
+	err := CheckNameServers([]NameServer{valid, invalidPort, invalidAddress, invalidTemplate})
+	assert.ErrorContains(t, err, "invalid nameserver udp://1.1.1.1:99999: port 99999 is out of range")
+	assert.ErrorContains(t, err, "invalid nameserver tcp://1.1.1.1/24:53: address 1.1.1.1/24 is not a valid IP address or hostname")
+	assert.ErrorContains(t, err, "invalid nameserver http://dns.example.net/dns-query (GET): url template must be an absolute https url")
+	assert.NotContains(t, err.Error(), "1.1.1.1:53")
+}
+
+func TestNameServer_LinkLocal(t *testing.T) {
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	ns := NewUdpNameserver("fe80::1%eth0", "53").(*NameServerConcrete)
+	ns.client = client
+
+	assert.Nil(t, ns.bootstrap)
+	assert.Equal(t, "udp://[fe80::1%eth0]:53", ns.String())
+
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, "[fe80::1%eth0]:53", client.lastAddr)
+
+	// The address given to the dialer must keep the zone index.
+	host, _, err := net.SplitHostPort(client.lastAddr)
+	require.NoError(t, err)
+	assert.Equal(t, "eth0", netip.MustParseAddr(host).Zone())
+}
+
+// noEdnsDNSClient is a mock DNSClient for a server that returns FORMERR to any query using EDNS(0).
+type noEdnsDNSClient struct {
+	sent []*dns.Msg
+}
+
+func (m *noEdnsDNSClient) Exchange(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	m.sent = append(m.sent, msg)
+	if msg.IsEdns0() != nil {
+		return newNameserverResponseMsgWithAD(dns.RcodeFormatError, false), time.Millisecond, nil
+	}
+	return newNameserverResponseMsgWithAD(dns.RcodeSuccess, false), time.Millisecond, nil
+}
+
+func TestNameServer_QueryEdnsDowngrade(t *testing.T) {
+	client := &noEdnsDNSClient{}
+
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = client
+
+	// The first query is tried with EDNS(0), then without.
+	resp, rtt, err := ns.Query("example.com", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Equal(t, 2*time.Millisecond, rtt)
+	require.Len(t, client.sent, 2)
+	assert.NotNil(t, client.sent[0].IsEdns0())
+	assert.Nil(t, client.sent[1].IsEdns0())
+
+	// Later queries go straight to not using EDNS(0).
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	require.NoError(t, err)
+	require.Len(t, client.sent, 3)
+	assert.Nil(t, client.sent[2].IsEdns0())
+
+	// Until it's time to try EDNS(0) again.
+	ns.edns.unsupported = time.Now().Add(-ednsReprobeInterval - time.Second)
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	require.NoError(t, err)
+	require.Len(t, client.sent, 5)
+	assert.NotNil(t, client.sent[3].IsEdns0())
+}
+
+func TestNameServer_QueryEdnsNotDowngradedOnOtherErrors(t *testing.T) {
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeServerFailure, false)}
+
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = client
+
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.EqualError(t, err, "example.com. A query to udp://192.0.2.1:53 returned SERVFAIL")
+	assert.NotNil(t, client.lastMsg.IsEdns0())
+	assert.True(t, ns.edns.supported())
+}
+
+// timeoutError is a network error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// timeoutOnceDNSClient is a mock DNSClient that times out on its first query, then answers.
+type timeoutOnceDNSClient struct {
+	sent []*dns.Msg
+}
+
+func (m *timeoutOnceDNSClient) Exchange(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	m.sent = append(m.sent, msg)
+	if len(m.sent) == 1 {
+		return nil, time.Millisecond, timeoutError{}
+	}
+	return newNameserverResponseMsgWithAD(dns.RcodeSuccess, true), time.Millisecond, nil
+}
+
+func TestNameServer_QueryEdnsNotDowngradedOnTimeout(t *testing.T) {
+	client := &timeoutOnceDNSClient{}
+
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = client
+
+	// A timeout isn't retried without EDNS(0).
+	_, _, err := ns.Query("example.com", dns.TypeA)
+	assert.True(t, isTimeout(err))
+	require.Len(t, client.sent, 1)
+
+	// The next query succeeds, still using EDNS(0), with the DO bit set.
+	_, _, err = ns.Query("example.com", dns.TypeA)
+	require.NoError(t, err)
+	require.Len(t, client.sent, 2)
+	require.NotNil(t, client.sent[1].IsEdns0())
+	assert.True(t, client.sent[1].IsEdns0().Do())
+	assert.True(t, ns.edns.supported())
+}
+
+func TestIsEdnsFailure(t *testing.T) {
+	for rcode, expected := range map[int]bool{
+		dns.RcodeSuccess:        false,
+		dns.RcodeServerFailure:  false,
+		dns.RcodeRefused:        false,
+		dns.RcodeFormatError:    true,
+		dns.RcodeNotImplemented: true,
+		dns.RcodeBadVers:        true,
+	} {
+		assert.Equal(t, expected, isEdnsFailure(&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: rcode}}, nil), dns.RcodeToString[rcode])
+	}
+	assert.False(t, isEdnsFailure(nil, timeoutError{}))
+}
+
+func TestNameServer_QueryRetriesTruncatedOverTcp(t *testing.T) {
+	truncated := newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)
+	truncated.Truncated = true
+	full := newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)
+	rr, _ := dns.NewRR("example.com. 300 IN TXT \"long\"")
+	full.Answer = []dns.RR{rr}
+
+	client := &MockDNSClient{response: truncated, rtt: time.Millisecond}
+	fallback := &MockDNSClient{response: full, rtt: 2 * time.Millisecond}
+
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client, ns.fallback = client, fallback
+
+	response, rtt, err := ns.Query("example.com", dns.TypeTXT)
+	require.NoError(t, err)
+	assert.False(t, response.Truncated)
+	assert.Len(t, response.Answer, 1)
+	assert.Equal(t, 3*time.Millisecond, rtt)
+	assert.Equal(t, client.lastMsg, fallback.lastMsg)
+	assert.Equal(t, "192.0.2.1:53", fallback.lastAddr)
+
+	fallback.err = errors.New("connection refused")
+	_, _, err = ns.Query("example.com", dns.TypeTXT)
+	assert.EqualError(t, err, "response was truncated, and retrying over tcp failed: connection refused")
+
+	// Responses that aren't truncated aren't retried.
+	client.response, fallback.lastMsg = full, nil
+	_, _, err = ns.Query("example.com", dns.TypeTXT)
+	require.NoError(t, err)
+	assert.Nil(t, fallback.lastMsg)
+}
+
+func TestWithTimeouts(t *testing.T) {
+	ns := NewUdpNameserver("192.0.2.1", "53", WithTimeouts(time.Second, 2*time.Second, 3*time.Second)).(*NameServerConcrete)
+	for _, client := range []*dns.Client{ns.client.(*dns.Client), ns.fallback.(*dns.Client)} {
+		assert.Equal(t, time.Second, client.DialTimeout)
+		assert.Equal(t, 2*time.Second, client.ReadTimeout)
+		assert.Equal(t, 3*time.Second, client.WriteTimeout)
+	}
+
+	tlsNameserver := NewTlsNameserver("192.0.2.1", "853", "dns.example", WithTimeouts(time.Second, 2*time.Second, 3*time.Second)).(*NameServerConcrete)
+	assert.Equal(t, 2*time.Second, tlsNameserver.client.(*pooledClient).client.ReadTimeout)
+
+	// A nameserver that never responds fails once the read timeout has passed.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+	start := time.Now()
+	_, _, err = NewUdpNameserver("127.0.0.1", port, WithTimeouts(0, 50*time.Millisecond, 0)).Query("example.com", dns.TypeA)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
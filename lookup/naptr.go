@@ -0,0 +1,33 @@
+package lookup
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/miekg/dns"
+)
+
+// QueryNAPTR performs a DNS query for NAPTR records, returning them in the order they're to be processed
+func (d *DnsLookup) QueryNAPTR(name string) ([]*dns.NAPTR, error) {
+	return d.QueryNAPTRContext(context.Background(), name)
+}
+
+// QueryNAPTRContext performs a DNS query for NAPTR records, stopping when ctx is done
+func (d *DnsLookup) QueryNAPTRContext(ctx context.Context, name string) ([]*dns.NAPTR, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeNAPTR)
+	if err != nil {
+		return nil, err
+	}
+	records := extractRecordsOfType[*dns.NAPTR](msg.Answer)
+	sortNAPTR(records)
+	return records, nil
+}
+
+// sortNAPTR sorts records by their Order, then their Preference, lowest first, as RFC 3403, section 4.1 requires them
+// to be processed. Records that are equal keep the order the nameserver gave them in.
+func sortNAPTR(records []*dns.NAPTR) {
+	slices.SortStableFunc(records, func(a, b *dns.NAPTR) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), cmp.Compare(a.Preference, b.Preference))
+	})
+}
@@ -0,0 +1,32 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryNAPTR(t *testing.T) {
+	name := "4.3.2.1.5.5.5.0.0.8.1.e164.arpa."
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", name, dns.TypeNAPTR).Return(newAnswerMsg(t,
+		name+` 300 IN NAPTR 200 10 "u" "E2U+email" "!^.*$!mailto:info@example.com!" .`,
+		name+` 300 IN NAPTR 100 20 "u" "E2U+sip" "!^.*$!sip:backup@example.com!" .`,
+		name+` 300 IN NAPTR 100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`,
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QueryNAPTR(name)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "!^.*$!sip:info@example.com!", records[0].Regexp)
+	assert.Equal(t, "!^.*$!sip:backup@example.com!", records[1].Regexp)
+	assert.Equal(t, "!^.*$!mailto:info@example.com!", records[2].Regexp)
+	assert.Equal(t, "E2U+sip", records[0].Service)
+}
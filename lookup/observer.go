@@ -0,0 +1,107 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+// Observer receives the events of a query as they happen, so they can be streamed elsewhere, e.g. to a tracing
+// system, rather than read from a Trace afterwards. The methods may be called concurrently, as the signatures of an
+// answer are validated in parallel.
+type Observer interface {
+	OnLookup(TraceLookup)                           // A nameserver was queried, successfully or not
+	OnSignatureValidation(TraceSignatureValidation) // A signature was verified with a zone's key
+	OnDelegationCheck(TraceDelegationSignerCheck)   // A zone's key was matched with a DS record from its parent
+}
+
+// ValidationObserver is an Observer that's also told the outcome of validating each query's answer: once per query,
+// rather than once per signature, as OnSignatureValidation is. An Observer that implements it has OnValidation called.
+type ValidationObserver interface {
+	Observer
+	OnValidation(QueryValidation) // A query's answer was validated
+}
+
+// QueryValidation is the outcome of validating a query's answer. Answers from the hosts file or the cache, and
+// queries that failed for other reasons, e.g. NXDOMAIN, aren't reported.
+type QueryValidation struct {
+	Domain string           // The name queried
+	Rrtype string           // The type queried, e.g. "A"
+	Status ValidationStatus // ValidationSecure, ValidationInsecure or ValidationBogus
+	Err    error            // Why the answer was bogus; nil otherwise
+}
+
+// multiObserver passes each event on to every one of its observers, in turn.
+type multiObserver []Observer
+
+func (m multiObserver) OnLookup(r TraceLookup) {
+	for _, o := range m {
+		o.OnLookup(r)
+	}
+}
+
+func (m multiObserver) OnSignatureValidation(r TraceSignatureValidation) {
+	for _, o := range m {
+		o.OnSignatureValidation(r)
+	}
+}
+
+func (m multiObserver) OnDelegationCheck(r TraceDelegationSignerCheck) {
+	for _, o := range m {
+		o.OnDelegationCheck(r)
+	}
+}
+
+func (m multiObserver) OnValidation(r QueryValidation) {
+	for _, o := range m {
+		if v, ok := o.(ValidationObserver); ok {
+			v.OnValidation(r)
+		}
+	}
+}
+
+// observeValidation reports the outcome of validating a query's answer to the observer, if it's a ValidationObserver.
+// Nothing is reported if the query failed for a reason other than the answer being bogus, or if the answer wasn't
+// validated.
+func (d *DnsLookup) observeValidation(observer Observer, name string, rrtype uint16, msg *dns.Msg, err error) {
+	v, ok := observer.(ValidationObserver)
+	if !ok {
+		return
+	}
+
+	record := QueryValidation{Domain: name, Rrtype: rrtypeToString(rrtype)}
+	switch {
+	case err == nil:
+		record.Status = d.validationStatus(msg)
+	case errors.Is(err, ErrDNSSECBogus):
+		record.Status, record.Err = ValidationBogus, err
+	}
+	if record.Status != ValidationIndeterminate {
+		v.OnValidation(record)
+	}
+}
+
+// newObserver combines the observers, skipping any that are nil. It returns nil if there are none.
+func newObserver(observers ...Observer) Observer {
+	var m multiObserver
+	for _, o := range observers {
+		if o != nil {
+			m = append(m, o)
+		}
+	}
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	default:
+		return m
+	}
+}
+
+// observerFrom returns the Observer of the query being made with ctx, or nil if there isn't one.
+func observerFrom(ctx context.Context) Observer {
+	observer, _ := ctx.Value(contextTrace).(Observer)
+	return observer
+}
@@ -0,0 +1,91 @@
+package lookup
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingObserver counts the events it receives.
+type recordingObserver struct {
+	mu          sync.Mutex
+	lookups     []TraceLookup
+	signatures  int
+	delegations int
+	validations []QueryValidation
+}
+
+func (o *recordingObserver) OnLookup(r TraceLookup) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lookups = append(o.lookups, r)
+}
+
+func (o *recordingObserver) OnSignatureValidation(r TraceSignatureValidation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.signatures++
+}
+
+func (o *recordingObserver) OnDelegationCheck(r TraceDelegationSignerCheck) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.delegations++
+}
+
+func (o *recordingObserver) OnValidation(r QueryValidation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.validations = append(o.validations, r)
+}
+
+func TestDnsLookup_Observer(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	observer := &recordingObserver{}
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+	d.Observer = observer
+	d.EnableTrace = true
+
+	_, err := d.QueryA("test.example.com.")
+	require.NoError(t, err)
+
+	require.NotEmpty(t, observer.lookups)
+	assert.Equal(t, "test.example.com.", observer.lookups[0].Domain)
+	assert.Equal(t, "A", observer.lookups[0].Rrtype)
+	assert.Equal(t, 3, observer.delegations)
+	assert.Positive(t, observer.signatures)
+
+	// The trace sees the same events.
+	assert.Len(t, d.Trace.Records, len(observer.lookups)+observer.signatures+observer.delegations)
+
+	// The outcome of validation is reported once for the query, however many signatures were verified.
+	require.Len(t, observer.validations, 1)
+	assert.Equal(t, QueryValidation{Domain: "test.example.com.", Rrtype: "A", Status: ValidationSecure}, observer.validations[0])
+
+	d.RootDNSSECRecords = nil
+	_, err = d.QueryA("test.example.com.")
+	require.Error(t, err)
+	require.Len(t, observer.validations, 2)
+	assert.Equal(t, ValidationBogus, observer.validations[1].Status)
+	assert.ErrorIs(t, observer.validations[1].Err, ErrDNSSECBogus)
+}
+
+func TestNewObserver(t *testing.T) {
+	assert.Nil(t, newObserver(nil, nil))
+
+	observer := &recordingObserver{}
+	assert.Same(t, observer, newObserver(nil, observer))
+
+	trace := new(Trace)
+	combined := newObserver(observer, trace)
+	combined.OnLookup(newtTraceLookup("example.com.", dns.TypeA, "mock", 0, new(dns.Msg)))
+	assert.Len(t, observer.lookups, 1)
+	assert.Len(t, trace.Records, 1)
+}
@@ -0,0 +1,51 @@
+package lookup
+
+import (
+	"github.com/miekg/dns"
+)
+
+// PaddingPolicy sets how queries are padded with the EDNS(0) Padding option (RFC 7830), so that on an encrypted
+// connection their size doesn't reveal which name is being queried.
+type PaddingPolicy struct {
+	BlockSize int // Queries are padded to a multiple of this many bytes; 0 disables padding
+}
+
+// DefaultPaddingPolicy pads queries to a multiple of 128 bytes, as recommended by RFC 8467. It's used by TLS, HTTPS and
+// QUIC nameservers unless they're given another.
+var DefaultPaddingPolicy = PaddingPolicy{BlockSize: 128}
+
+// NoPadding sends queries without padding.
+var NoPadding = PaddingPolicy{}
+
+// WithPadding sets how queries to the nameserver are padded. Padding only hides anything on an encrypted connection,
+// so UDP and TCP nameservers don't pad queries unless given a policy.
+func WithPadding(policy PaddingPolicy) NameServerOption {
+	return func(n *NameServerConcrete) {
+		n.padding = policy
+	}
+}
+
+// WithHttpPadding sets how queries to the DoH nameserver are padded.
+func WithHttpPadding(policy PaddingPolicy) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		n.padding = policy
+	}
+}
+
+// addPadding adds a Padding option to a message's OPT record, sized so the packed message is a multiple of the
+// policy's block size. It should be the last option added. It has no effect on messages without EDNS(0).
+// If the message is to be signed with key, the TSIG record added when it's sent is included in its size.
+func addPadding(msg *dns.Msg, policy PaddingPolicy, key *TsigKey) {
+	opt := msg.IsEdns0()
+	if opt == nil || policy.BlockSize <= 0 {
+		return
+	}
+
+	// Each option takes four bytes for its code and length, as well as its data.
+	length := msg.Len() + 4
+	if key != nil {
+		length += key.signedLen(msg.Id)
+	}
+	padding := (policy.BlockSize - length%policy.BlockSize) % policy.BlockSize
+	opt.Option = append(opt.Option, &dns.EDNS0_PADDING{Padding: make([]byte, padding)})
+}
@@ -0,0 +1,75 @@
+package lookup
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddPadding(t *testing.T) {
+	for _, name := range []string{"a.", "example.com.", "a-much-longer-name.sub.example.com."} {
+		msg := newQueryMsg(name, dns.TypeA)
+		addPadding(msg, DefaultPaddingPolicy, nil)
+
+		packed, err := msg.Pack()
+		require.NoError(t, err)
+		assert.Zero(t, len(packed)%128, name)
+	}
+
+	// The TSIG record, added when the message is signed, is included in the padded length.
+	for _, algorithm := range []string{dns.HmacSHA1, dns.HmacSHA224, dns.HmacSHA256, dns.HmacSHA384, dns.HmacSHA512} {
+		key := &TsigKey{Name: "a-longer-key-name.example.", Algorithm: algorithm, Secret: testTsigSecret}
+		msg := newQueryMsg("example.com.", dns.TypeA)
+		addPadding(msg, DefaultPaddingPolicy, key)
+		addTsig(msg, key)
+
+		packed, _, err := dns.TsigGenerate(msg, key.Secret, "", false)
+		require.NoError(t, err)
+		assert.Zero(t, len(packed)%128, algorithm)
+	}
+
+	// Without EDNS(0), or a block size, nothing is added.
+	msg := newQueryMsg("example.com.", dns.TypeA)
+	addPadding(msg, NoPadding, nil)
+	assert.Empty(t, msg.IsEdns0().Option)
+
+	removeEdns0(msg)
+	addPadding(msg, DefaultPaddingPolicy, nil)
+	assert.Nil(t, msg.IsEdns0())
+}
+
+func TestWithPadding(t *testing.T) {
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	// Encrypted transports pad by default; the padding comes after any other options.
+	ns := NewTlsNameserver("192.0.2.1", "853", "dns.example", WithClientSubnet(netip.MustParsePrefix("192.0.2.0/24"))).(*NameServerConcrete)
+	ns.client = client
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	options := client.lastMsg.IsEdns0().Option
+	require.Len(t, options, 2)
+	assert.IsType(t, &dns.EDNS0_PADDING{}, options[1])
+	assert.Zero(t, client.lastMsg.Len()%128)
+
+	ns = NewTlsNameserver("192.0.2.1", "853", "dns.example", WithPadding(NoPadding)).(*NameServerConcrete)
+	ns.client = client
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Empty(t, client.lastMsg.IsEdns0().Option)
+
+	// Unencrypted transports don't, unless asked to.
+	ns = NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = client
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Empty(t, client.lastMsg.IsEdns0().Option)
+
+	ns = NewUdpNameserver("192.0.2.1", "53", WithPadding(PaddingPolicy{BlockSize: 64})).(*NameServerConcrete)
+	ns.client = client
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Zero(t, client.lastMsg.Len()%64)
+}
@@ -0,0 +1,223 @@
+package lookup
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	pcapLinkTypeRaw = 101   // LINKTYPE_RAW; each packet starts with its IPv4 or IPv6 header
+	pcapSnapLen     = 65535 // The largest packet written
+)
+
+// Synthetic addresses used for the local end of captured queries, and for nameservers given as a hostname.
+// They're from the documentation ranges, so won't be mistaken for real hosts.
+var (
+	pcapClientIPv4 = netip.MustParseAddr("192.0.2.1")
+	pcapClientIPv6 = netip.MustParseAddr("2001:db8::1")
+	pcapServerIPv4 = netip.MustParseAddr("203.0.113.1")
+)
+
+// PcapWriter writes the DNS messages sent to, and received from, nameservers to a pcap file, so they can be inspected
+// with Wireshark or tcpdump when debugging an upstream. It's safe to share between nameservers.
+//
+// Each message is written as a single UDP packet, with synthetic IP and UDP headers, whichever protocol it was sent
+// over; the nameserver's address and port are kept, but the local address is a documentation address. The local port
+// is derived from the message ID, so a query and its response share one. Messages are repacked to be written, so name
+// compression may differ from the bytes on the wire.
+type PcapWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+	err error
+}
+
+// NewPcapWriter writes the pcap file header to w, and returns a PcapWriter that writes messages after it.
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], 0xa1b2c3d4) // Magic number, for microsecond timestamps
+	binary.LittleEndian.PutUint16(header[4:], 2)          // Major version
+	binary.LittleEndian.PutUint16(header[6:], 4)          // Minor version
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkTypeRaw)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w, now: time.Now}, nil
+}
+
+// Err returns the first error from writing a message, if there was one. Writing stops after an error, but queries are
+// unaffected.
+func (p *PcapWriter) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// WithPcap writes the queries sent to a UDP, TCP, TLS or QUIC nameserver, and the responses received, to a pcap file.
+func WithPcap(p *PcapWriter) NameServerOption {
+	return func(n *NameServerConcrete) {
+		n.pcap = p
+	}
+}
+
+// WithHttpPcap writes the queries sent to the DoH nameserver, and the responses received, to a pcap file.
+// See WithPcap.
+func WithHttpPcap(p *PcapWriter) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		n.pcap = p
+	}
+}
+
+// writeMsg packs a message and writes it, with the local port derived from id, the ID of the query. It has no effect
+// if p or msg is nil.
+func (p *PcapWriter) writeMsg(msg *dns.Msg, id uint16, server string, outgoing bool) {
+	if p == nil || msg == nil {
+		return
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return
+	}
+	p.write(packed, id, server, outgoing)
+}
+
+// write writes a packed message as a UDP packet between the client and server, with the client's port derived from
+// id. The server is a host and port, e.g. 192.0.2.53:53; if the host isn't an IP address, pcapServerIPv4 is used in
+// its place.
+func (p *PcapWriter) write(payload []byte, id uint16, server string, outgoing bool) {
+	if p == nil {
+		return
+	}
+
+	serverAddr := pcapServerAddr(server)
+	clientAddr := netip.AddrPortFrom(pcapClientIPv4, 49152+id%16384)
+	if serverAddr.Addr().Is6() {
+		clientAddr = netip.AddrPortFrom(pcapClientIPv6, clientAddr.Port())
+	}
+
+	src, dst := clientAddr, serverAddr
+	if !outgoing {
+		src, dst = serverAddr, clientAddr
+	}
+	packet := newPcapPacket(src, dst, payload)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+
+	now := p.now()
+	record := make([]byte, 16, 16+len(packet))
+	binary.LittleEndian.PutUint32(record[0:], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(packet)))
+
+	_, p.err = p.w.Write(append(record, packet...))
+}
+
+// pcapServerAddr parses the address of a nameserver, as given to its client.
+func pcapServerAddr(server string) netip.AddrPort {
+	host, portStr, err := net.SplitHostPort(server)
+	if err != nil {
+		host, portStr = server, "53"
+	}
+	port, err := net.LookupPort("udp", portStr)
+	if err != nil {
+		port = 53
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		addr = pcapServerIPv4
+	}
+	return netip.AddrPortFrom(addr.Unmap().WithZone(""), uint16(port))
+}
+
+// newPcapPacket builds an IPv4 or IPv6 packet, holding a UDP datagram with the payload. Payloads too large for a
+// single datagram are cut short.
+func newPcapPacket(src, dst netip.AddrPort, payload []byte) []byte {
+	ipHeaderLen := 20
+	if src.Addr().Is6() {
+		ipHeaderLen = 40
+	}
+	if max := pcapSnapLen - ipHeaderLen - 8; len(payload) > max {
+		payload = payload[:max]
+	}
+	udpLen := 8 + len(payload)
+
+	packet := make([]byte, ipHeaderLen+udpLen)
+	ip, udp := packet[:ipHeaderLen], packet[ipHeaderLen:]
+
+	// The pseudo header the UDP checksum covers (RFC 768 and RFC 8200, section 8.1).
+	var pseudo []byte
+	srcIP, dstIP := src.Addr().AsSlice(), dst.Addr().AsSlice()
+
+	if src.Addr().Is4() {
+		ip[0] = 0x45 // Version 4, with a 20 byte header
+		binary.BigEndian.PutUint16(ip[2:], uint16(len(packet)))
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // Don't fragment
+		ip[8] = 64                                 // TTL
+		ip[9] = 17                                 // UDP
+		copy(ip[12:], srcIP)
+		copy(ip[16:], dstIP)
+		binary.BigEndian.PutUint16(ip[10:], internetChecksum(0, ip))
+
+		pseudo = append(append(pseudo, srcIP...), dstIP...)
+		pseudo = append(pseudo, 0, 17)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(udpLen))
+	} else {
+		ip[0] = 0x60 // Version 6
+		binary.BigEndian.PutUint16(ip[4:], uint16(udpLen))
+		ip[6] = 17 // UDP
+		ip[7] = 64 // Hop limit
+		copy(ip[8:], srcIP)
+		copy(ip[24:], dstIP)
+
+		pseudo = append(append(pseudo, srcIP...), dstIP...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(udpLen))
+		pseudo = append(pseudo, 0, 0, 0, 17)
+	}
+
+	binary.BigEndian.PutUint16(udp[0:], src.Port())
+	binary.BigEndian.PutUint16(udp[2:], dst.Port())
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpLen))
+	copy(udp[8:], payload)
+
+	checksum := internetChecksum(internetChecksumSum(0, pseudo), udp)
+	if checksum == 0 {
+		checksum = 0xffff // Zero means no checksum was computed
+	}
+	binary.BigEndian.PutUint16(udp[6:], checksum)
+
+	return packet
+}
+
+// internetChecksum returns the ones' complement checksum of b (RFC 1071), continuing from a partial sum.
+func internetChecksum(sum uint32, b []byte) uint16 {
+	sum = internetChecksumSum(sum, b)
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// internetChecksumSum adds b, as big endian 16 bit words, to a partial checksum sum.
+func internetChecksumSum(sum uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
@@ -0,0 +1,174 @@
+package lookup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pcapPacket is a packet read back from a pcap file.
+type pcapPacket struct {
+	src, dst netip.AddrPort
+	msg      *dns.Msg
+}
+
+// readPcap parses a pcap file written by a PcapWriter, checking each packet's headers and checksums.
+func readPcap(t *testing.T, b []byte) []pcapPacket {
+	require.GreaterOrEqual(t, len(b), 24)
+	assert.Equal(t, uint32(0xa1b2c3d4), binary.LittleEndian.Uint32(b[0:]))
+	assert.Equal(t, uint32(pcapLinkTypeRaw), binary.LittleEndian.Uint32(b[20:]))
+	b = b[24:]
+
+	var packets []pcapPacket
+	for len(b) > 0 {
+		require.GreaterOrEqual(t, len(b), 16)
+		length := int(binary.LittleEndian.Uint32(b[8:]))
+		require.GreaterOrEqual(t, len(b), 16+length)
+		packet := b[16 : 16+length]
+		b = b[16+length:]
+
+		var p pcapPacket
+		var udp, pseudo []byte
+		switch packet[0] >> 4 {
+		case 4:
+			assert.Equal(t, uint16(0), internetChecksum(0, packet[:20]), "ipv4 header checksum")
+			src, _ := netip.AddrFromSlice(packet[12:16])
+			dst, _ := netip.AddrFromSlice(packet[16:20])
+			udp = packet[20:]
+			p.src, p.dst = netip.AddrPortFrom(src, 0), netip.AddrPortFrom(dst, 0)
+			pseudo = append(append(pseudo, packet[12:20]...), 0, 17)
+			pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(udp)))
+		case 6:
+			src, _ := netip.AddrFromSlice(packet[8:24])
+			dst, _ := netip.AddrFromSlice(packet[24:40])
+			udp = packet[40:]
+			p.src, p.dst = netip.AddrPortFrom(src, 0), netip.AddrPortFrom(dst, 0)
+			pseudo = append(pseudo, packet[8:40]...)
+			pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(udp)))
+			pseudo = append(pseudo, 0, 0, 0, 17)
+		default:
+			t.Fatalf("unexpected ip version %d", packet[0]>>4)
+		}
+
+		assert.Equal(t, uint16(0), internetChecksum(internetChecksumSum(0, pseudo), udp), "udp checksum")
+		assert.Equal(t, len(udp), int(binary.BigEndian.Uint16(udp[4:])))
+		p.src = netip.AddrPortFrom(p.src.Addr(), binary.BigEndian.Uint16(udp[0:]))
+		p.dst = netip.AddrPortFrom(p.dst.Addr(), binary.BigEndian.Uint16(udp[2:]))
+
+		p.msg = new(dns.Msg)
+		require.NoError(t, p.msg.Unpack(udp[8:]))
+		packets = append(packets, p)
+	}
+	return packets
+}
+
+func TestWithPcap(t *testing.T) {
+	var buf bytes.Buffer
+	pcap, err := NewPcapWriter(&buf)
+	require.NoError(t, err)
+
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+	ns := NewUdpNameserver("198.51.100.53", "5353", WithPcap(pcap)).(*NameServerConcrete)
+	ns.client = client
+
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	require.NoError(t, pcap.Err())
+
+	packets := readPcap(t, buf.Bytes())
+	require.Len(t, packets, 2)
+
+	server := netip.MustParseAddrPort("198.51.100.53:5353")
+	query, response := packets[0], packets[1]
+
+	assert.Equal(t, server, query.dst)
+	assert.Equal(t, pcapClientIPv4, query.src.Addr())
+	assert.Equal(t, uint16(49152+client.lastMsg.Id%16384), query.src.Port())
+	assert.Equal(t, client.lastMsg.Question, query.msg.Question)
+	assert.False(t, query.msg.Response)
+
+	assert.Equal(t, server, response.src)
+	assert.Equal(t, query.src, response.dst)
+	assert.True(t, response.msg.Response)
+}
+
+func TestWithPcap_IPv6(t *testing.T) {
+	var buf bytes.Buffer
+	pcap, err := NewPcapWriter(&buf)
+	require.NoError(t, err)
+
+	ns := NewTcpNameserver("2001:db8::53", "53", WithPcap(pcap)).(*NameServerConcrete)
+	ns.client = &MockDNSClient{err: errors.New("connection refused")}
+
+	_, _, err = ns.Query("example.com.", dns.TypeAAAA)
+	require.Error(t, err)
+
+	// Only the query is captured, as there was no response.
+	packets := readPcap(t, buf.Bytes())
+	require.Len(t, packets, 1)
+	assert.Equal(t, netip.MustParseAddrPort("[2001:db8::53]:53"), packets[0].dst)
+	assert.Equal(t, pcapClientIPv6, packets[0].src.Addr())
+	assert.Equal(t, dns.TypeAAAA, packets[0].msg.Question[0].Qtype)
+}
+
+func TestWithHttpPcap(t *testing.T) {
+	var lastRequest *http.Request
+	server := newDohTestServer(t, &lastRequest)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	pcap, err := NewPcapWriter(&buf)
+	require.NoError(t, err)
+
+	ns := NewHttpsNameserver(server.URL+"/dns-query", WithHttpClient(server.Client()), WithHttpPcap(pcap))
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+
+	packets := readPcap(t, buf.Bytes())
+	require.Len(t, packets, 2)
+	assert.Equal(t, netip.MustParseAddrPort(lastRequest.Host), packets[0].dst)
+	assert.Equal(t, packets[0].dst, packets[1].src)
+	assert.Equal(t, "example.com.", packets[1].msg.Question[0].Name)
+}
+
+func TestPcapServerAddr(t *testing.T) {
+	assert.Equal(t, netip.MustParseAddrPort("192.0.2.53:853"), pcapServerAddr("192.0.2.53:853"))
+	assert.Equal(t, netip.MustParseAddrPort("[fe80::1]:53"), pcapServerAddr("[fe80::1%eth0]:53"))
+	assert.Equal(t, netip.AddrPortFrom(pcapServerIPv4, 443), pcapServerAddr("dns.example.net:443"))
+	assert.Equal(t, netip.AddrPortFrom(pcapServerIPv4, 53), pcapServerAddr("dns.example.net"))
+}
+
+// failingWriter fails every write after the first n.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	w.n--
+	return len(b), nil
+}
+
+func TestPcapWriter_Err(t *testing.T) {
+	_, err := NewPcapWriter(&failingWriter{})
+	assert.Error(t, err)
+
+	pcap, err := NewPcapWriter(&failingWriter{n: 1})
+	require.NoError(t, err)
+
+	// A failed write doesn't fail the query.
+	ns := NewUdpNameserver("192.0.2.53", "53", WithPcap(pcap)).(*NameServerConcrete)
+	ns.client = &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.ErrorContains(t, pcap.Err(), "disk full")
+}
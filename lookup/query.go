@@ -2,16 +2,22 @@ package lookup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/miekg/dns"
 	"github.com/nsmithuk/dns-anchors-go/anchors"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+	"golang.org/x/net/idna"
 	"io"
 	"math/rand"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// FanOutAll sets DnsLookup.FanOut to query every nameserver at once.
+const FanOutAll = -1
+
 type DnsLookup struct {
 	logger                   zerolog.Logger
 	nameservers              []NameServer
@@ -20,8 +26,32 @@ type DnsLookup struct {
 	RemotelyAuthenticateData bool
 	RandomNameserver         bool
 	maxAuthenticationDepth   uint8
-	Trace                    *Trace
-	EnableTrace              bool
+	Trace                    *Trace // The trace of the latest query made without its own; see ContextWithTrace
+	EnableTrace              bool   // Trace each query, to DnsLookup.Trace, or Result.Trace for QueryResult
+	logLevels                map[LogComponent]zerolog.Level
+	logSamplers              map[LogComponent]zerolog.Sampler
+	ZoneWalkInterval         time.Duration    // The minimum time between the queries made by WalkZone
+	UnicodeOwnerNames        bool             // Convert A-label (punycode) owner names in answers to their Unicode form
+	ExcludeDNSSECStripping   bool             // When validating locally, skip nameservers known to strip DNSSEC records
+	NameValidation           NameValidation   // How strictly names are checked before being queried
+	AllowUnderscores         bool             // Allow underscores in names when NameValidation is NameValidationStrict
+	TrustAnchorMaxAge        time.Duration    // The age after which the embedded trust anchors are reported as stale
+	Hardening                *HardeningLimits // When set, responses are strictly re-parsed and checked against these limits
+	Cache                    *Cache           // When set, validated responses are cached until their TTLs expire
+	AllowInsecure            bool             // Accept answers from unsigned zones that are proven to be below an insecure delegation
+	FanOut                   int              // Query this many nameservers at once, using the first validated answer; FanOutAll for every one
+	RetryPolicy              *RetryPolicy     // How queries to each nameserver are retried; nil for a single attempt
+	Hosts                    *Hosts           // When set, A, AAAA and PTR queries are answered from the hosts file first
+	SearchDomains            []string         // Domains appended to names that don't end in a dot, tried in order
+	Ndots                    int              // Names with fewer dots than this are tried with the SearchDomains first
+	BatchConcurrency         int              // How many of a QueryBatch's queries are made at once; 0 for DefaultBatchConcurrency
+	FailoverPolicy           *FailoverPolicy  // Which failures move a query on to the next nameserver; nil for all of them
+	Observer                 Observer         // When set, receives each query's lookups and validation steps as they happen
+	middleware               []Middleware
+	health                   nameserverHealth
+	rootKeys                 rootKeyCheck
+	lifecycle                lifecycle
+	anchorManager            atomic.Pointer[AnchorManager]
 }
 
 func NewDnsLookup(nameservers []NameServer) *DnsLookup {
@@ -34,115 +64,425 @@ func NewDnsLookup(nameservers []NameServer) *DnsLookup {
 		maxAuthenticationDepth:   10,
 		RootDNSSECRecords:        anchors.GetAllFromEmbedded(),
 		EnableTrace:              false,
+		ZoneWalkInterval:         100 * time.Millisecond,
+		NameValidation:           NameValidationBasic,
+		AllowUnderscores:         true,
+		TrustAnchorMaxAge:        365 * 24 * time.Hour,
+		Ndots:                    DefaultNdots,
 	}
 }
 
+// SetLogger sets the logger. A warning is logged straight away if the embedded trust anchors are stale.
 func (d *DnsLookup) SetLogger(l zerolog.Logger) {
 	d.logger = l
+	d.warnIfTrustAnchorsStale()
 }
 
+// getNameservers returns the nameservers in the order they should be tried. The shuffle is applied to a copy,
+// so concurrent queries don't race on the configured slice.
 func (d *DnsLookup) getNameservers() []NameServer {
-	if d.RandomNameserver && len(d.nameservers) > 1 {
-		rand.Shuffle(len(d.nameservers), func(i, j int) {
-			d.nameservers[i], d.nameservers[j] = d.nameservers[j], d.nameservers[i]
+	nameservers := make([]NameServer, len(d.nameservers))
+	copy(nameservers, d.nameservers)
+	if d.RandomNameserver && len(nameservers) > 1 {
+		rand.Shuffle(len(nameservers), func(i, j int) {
+			nameservers[i], nameservers[j] = nameservers[j], nameservers[i]
 		})
 	}
-	return d.nameservers
+	return nameservers
 }
 
 func (d *DnsLookup) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
-	ctx := context.Background()
+	return d.QueryContext(context.Background(), name, rrtype)
+}
 
-	if d.EnableTrace {
-		d.Trace = new(Trace)
-		ctx = context.WithValue(ctx, contextTrace, d.Trace)
-	}
+// QueryContext performs a DNS query, stopping when ctx is done. The context is passed on to the nameservers, and
+// used for the queries made whilst authenticating the answer. Unicode names are converted to their A-label form, and
+// names not ending in a dot are expanded with the SearchDomains, if any are set. The query passes through any
+// Middleware added with Use first.
+func (d *DnsLookup) QueryContext(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	return d.chain(d.queryContext)(ctx, name, rrtype)
+}
 
-	msg, latency, err := d.query(name, rrtype, ctx)
+// queryContext performs a DNS query for QueryContext, once it's passed through the middleware.
+func (d *DnsLookup) queryContext(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	name, err := d.toASCII(name)
 	if err != nil {
-		return nil, latency, err
+		return nil, 0, err
+	}
+
+	if candidates := d.searchCandidates(name); len(candidates) > 1 {
+		return d.querySearch(ctx, candidates, rrtype)
+	}
+
+	if !d.lifecycle.begin() {
+		return nil, 0, ErrClosed
+	}
+	defer d.lifecycle.end()
+
+	if err := checkNameLength(name); err != nil {
+		return nil, 0, err
+	}
+	if err := d.validateName(name); err != nil {
+		return nil, 0, err
+	}
+
+	if d.Hosts != nil {
+		if msg, ok := d.Hosts.lookup(name, rrtype); ok {
+			logger := d.componentLogger(LogComponentQuery)
+			logger.Debug().Str("domain", name).Str("type", rrtypeToString(rrtype)).Msg("Answer found in hosts file")
+			setQueryInfo(ctx, "hosts", ValidationIndeterminate)
+			return msg, 0, nil
+		}
+	}
+
+	useCache := d.useCache(ctx)
+	if useCache {
+		if msg, ok := d.Cache.Get(name, rrtype); ok {
+			logger := d.componentLogger(LogComponentQuery)
+			logger.Debug().Str("domain", name).Str("type", rrtypeToString(rrtype)).Msg("Answer found in cache")
+			setQueryInfo(ctx, "cache", d.validationStatus(msg))
+			if msg.Rcode != dns.RcodeSuccess {
+				return nil, 0, fmt.Errorf("cached response: %w", newRcodeError(name, rrtype, "cache", msg.Rcode))
+			}
+			return d.toUnicode(msg), 0, nil
+		}
+	}
+
+	observer := d.Observer
+	trace, ok := ctx.Value(contextQueryTrace).(*Trace)
+	if !ok && d.EnableTrace {
+		// Each query replaces the last one's trace, so this is only reliable when queries aren't made concurrently.
+		trace = new(Trace)
+		d.Trace = trace
+	}
+	if trace != nil {
+		observer = newObserver(d.Observer, trace)
+	}
+	if observer != nil {
+		ctx = context.WithValue(ctx, contextTrace, observer)
+	}
+
+	authenticate := func(msg *dns.Msg) error {
+		// The DS and DNSKEY responses fetched are cached once the answer's authenticated, so later answers from
+		// the same zones don't fetch them again.
+		queries := &authenticationQueries{results: make(map[string]*authenticationQueryResult)}
+		ctx := context.WithValue(ctx, contextQueries, queries)
+
+		var err error
+		status := ValidationSecure
+		if d.AllowInsecure {
+			status, err = d.Validate(msg, ctx)
+		} else {
+			err = d.Authenticate(msg, ctx)
+		}
+		if err == nil && status == ValidationSecure && useCache {
+			queries.cache(d.Cache)
+		}
+		if err != nil && ctx.Err() == nil {
+			err = &bogusError{err: err, noData: isNoData(msg)}
+		}
+		return err
 	}
 
+	// Each answer is authenticated as it arrives, so an answer that fails moves the query on to the next nameserver,
+	// whether they're queried in turn or fanned out.
+	var accept func(*dns.Msg) error
 	if d.LocallyAuthenticateData {
-		err = d.Authenticate(msg, ctx)
-		if err != nil {
-			return nil, latency, err
+		accept = authenticate
+	}
+
+	msg, nameserver, latency, err := d.queryNameservers(name, rrtype, ctx, accept)
+	if observer != nil && ctx.Err() == nil {
+		d.observeValidation(observer, name, rrtype, msg, err)
+	}
+	if err != nil {
+		// NXDOMAIN responses are returned as errors, so are cached here, rather than with answers below.
+		if nxdomain := nxdomainResponse(err); useCache && nxdomain != nil && d.trustNXDomain(nxdomain) {
+			d.Cache.Set(name, rrtype, nxdomain)
 		}
+		return nil, latency, err
 	}
 
-	return msg, latency, err
+	setQueryInfo(ctx, nameserver, d.validationStatus(msg))
+
+	if useCache {
+		d.Cache.setValidated(name, rrtype, msg, d.validationStatus(msg))
+	}
+
+	return d.toUnicode(msg), latency, err
+}
+
+// trustNXDomain reports whether an NXDOMAIN response can be cached. The denial of existence can't be authenticated
+// locally, so it's only cached when LocallyAuthenticateData isn't set and, if RemotelyAuthenticateData is, the
+// nameserver set the AD flag on it.
+func (d *DnsLookup) trustNXDomain(msg *dns.Msg) bool {
+	return !d.LocallyAuthenticateData && (!d.RemotelyAuthenticateData || msg.AuthenticatedData)
+}
+
+// useCache reports whether responses to queries made with ctx should be looked up in, and added to, the cache.
+func (d *DnsLookup) useCache(ctx context.Context) bool {
+	return d.Cache != nil && ctx.Value(contextNoCache) == nil
+}
+
+// toUnicode converts the owner names in an answer to their Unicode form, if UnicodeOwnerNames is set. It's only done
+// once authentication is complete, as signatures cover the A-label form; cached responses also keep that form.
+func (d *DnsLookup) toUnicode(msg *dns.Msg) *dns.Msg {
+	if d.UnicodeOwnerNames {
+		msg.Answer = ownerNamesToUnicode(msg.Answer)
+	}
+	return msg
 }
 
 func (d *DnsLookup) query(name string, rrtype uint16, ctx context.Context) (*dns.Msg, time.Duration, error) {
+	msg, _, duration, err := d.queryNameservers(name, rrtype, ctx, nil)
+	return msg, duration, err
+}
+
+// queryNameservers queries the nameservers in turn, or FanOut at a time, until one answers. If accept isn't nil, it's
+// also called on each answer, and only an answer it accepts is returned, along with the nameserver that gave it.
+func (d *DnsLookup) queryNameservers(name string, rrtype uint16, ctx context.Context, accept func(*dns.Msg) error) (*dns.Msg, string, time.Duration, error) {
 	nameservers := d.getNameservers()
 
 	if len(nameservers) < 1 {
-		return nil, 0, fmt.Errorf("no nameservers set")
+		return nil, "", 0, fmt.Errorf("no nameservers set")
+	}
+
+	if d.LocallyAuthenticateData && d.ExcludeDNSSECStripping {
+		var err error
+		if nameservers, err = d.excludeDNSSECStripping(nameservers); err != nil {
+			return nil, "", 0, err
+		}
 	}
 
-	logger := d.logger.With().Str("domain", name).Str("type", rrtypeToString(rrtype)).Logger()
+	logger := d.componentLogger(LogComponentQuery).With().Str("domain", name).Str("type", rrtypeToString(rrtype)).Logger()
 
 	logger.Info().Msg("Performing DNS query")
 	logger.Debug().Interface("nameservers", nameservers).Msg("Using nameservers")
 
+	if d.FanOut != 0 && len(nameservers) > 1 {
+		return d.queryConcurrently(nameservers, name, rrtype, ctx, logger, accept)
+	}
+
 	var totalDuration time.Duration
+	var errs []error
 	for _, nameserver := range nameservers {
+		if err := ctx.Err(); err != nil {
+			return nil, "", totalDuration, asQueryError(name, rrtype, nameserver.String(), err)
+		}
 
-		logger.Debug().Str("nameserver", nameserver.String()).Msg("Nameserver selected")
-
-		result, duration, err := nameserver.Query(name, rrtype)
+		result, duration, err := d.queryNameserverChecked(nameserver, name, rrtype, ctx, logger)
 		totalDuration = totalDuration + duration
 
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, "", totalDuration, asQueryError(name, rrtype, nameserver.String(), ctxErr)
+		}
+		if err != nil && d.FailoverPolicy.action(err) == FailoverStop {
+			return nil, "", totalDuration, fmt.Errorf("%s: %w", nameserver.String(), err)
+		}
+		if err == nil && accept != nil {
+			err = accept(result)
+		}
 		if err != nil {
-			logger.Warn().Dur("latency", duration).Str("nameserver", nameserver.String()).Err(err).
-				Msg("Issue resolving query. If there are other nameservers they will still be tried.")
+			errs = append(errs, fmt.Errorf("%s: %w", nameserver.String(), err))
 			continue
 		}
 
-		//---
+		return result, nameserver.String(), totalDuration, nil
+	}
+
+	//---
+
+	// Each nameserver's failure is included, so the cause is visible without needing the logs.
+	err := fmt.Errorf("%w on any configured nameserver: %w", ErrNoAnswer, errors.Join(errs...))
+	logger.Warn().Dur("latency", totalDuration).Msg("No answer found on any configured nameserver")
+
+	return nil, "", totalDuration, err
+}
+
+// queryConcurrently sends the query to FanOut nameservers at once, returning the first answer that's accepted. The
+// queries still outstanding are then cancelled. If none of them answer, the next FanOut nameservers are tried.
+func (d *DnsLookup) queryConcurrently(nameservers []NameServer, name string, rrtype uint16, ctx context.Context, logger zerolog.Logger, accept func(*dns.Msg) error) (*dns.Msg, string, time.Duration, error) {
+	size := d.FanOut
+	if size < 0 || size > len(nameservers) {
+		size = len(nameservers)
+	}
+
+	type result struct {
+		nameserver NameServer
+		msg        *dns.Msg
+		err        error
+	}
+
+	start := time.Now()
+	var errs []error
+	for len(nameservers) > 0 {
+		batch := nameservers[:min(size, len(nameservers))]
+		nameservers = nameservers[len(batch):]
 
-		if d.RemotelyAuthenticateData && !result.AuthenticatedData {
-			logger.Error().Dur("latency", duration).Str("nameserver", nameserver.String()).
-				Msg("Resolver dnssec authentication failed")
-			return nil, totalDuration, fmt.Errorf("resolver dnssec authentication failed")
+		batchCtx, cancel := context.WithCancel(ctx)
+		results := make(chan result, len(batch))
+		for _, nameserver := range batch {
+			go func() {
+				msg, _, err := d.queryNameserverChecked(nameserver, name, rrtype, batchCtx, logger)
+				if err == nil && accept != nil {
+					err = accept(msg)
+				}
+				results <- result{nameserver, msg, err}
+			}()
 		}
 
-		//---
+		for range batch {
+			r := <-results
+			if r.err == nil {
+				cancel()
+				return r.msg, r.nameserver.String(), time.Since(start), nil
+			}
+			if d.FailoverPolicy.action(r.err) == FailoverStop {
+				cancel()
+				return nil, "", time.Since(start), fmt.Errorf("%s: %w", r.nameserver.String(), r.err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", r.nameserver.String(), r.err))
+		}
+		cancel()
 
-		if e := log.Debug(); e.Enabled() {
-			logger.Debug().Dur("latency", duration).Str("nameserver", nameserver.String()).
-				Bool("authenticated-data-flag", result.AuthenticatedData).
-				Int("number-of-answers", len(result.Answer)).
-				Strs("answers", rrsetToStrings(result.Answer)).
-				Msg("Answer to query found")
-		} else {
-			logger.Info().Dur("latency", duration).Str("nameserver", nameserver.String()).
-				Bool("authenticated-data-flag", result.AuthenticatedData).
-				Int("number-of-answers", len(result.Answer)).
-				Msg("Answer to query found")
+		if ctx.Err() != nil {
+			// Each of the batch's queries was stopped, so their errors describe why.
+			return nil, "", time.Since(start), errors.Join(errs[len(errs)-len(batch):]...)
 		}
+	}
+
+	err := fmt.Errorf("%w on any configured nameserver: %w", ErrNoAnswer, errors.Join(errs...))
+	logger.Warn().Dur("latency", time.Since(start)).Msg("No answer found on any configured nameserver")
+
+	return nil, "", time.Since(start), err
+}
+
+// errResolverAuthentication is returned when the nameserver didn't set the AD bit on a response that needed it.
+var errResolverAuthentication error = &bogusError{err: errors.New("resolver dnssec authentication failed")}
 
-		//---
+// queryNameserverChecked queries a single nameserver, then checks, logs and traces its response.
+func (d *DnsLookup) queryNameserverChecked(nameserver NameServer, name string, rrtype uint16, ctx context.Context, logger zerolog.Logger) (*dns.Msg, time.Duration, error) {
+	logger.Debug().Str("nameserver", nameserver.String()).Msg("Nameserver selected")
 
-		if trace, ok := ctx.Value(contextTrace).(*Trace); ok {
-			trace.Add(newtTraceLookup(name, rrtype, nameserver.String(), duration, result.Answer))
+	result, duration, err := d.queryWithRetries(ctx, nameserver, name, rrtype)
+
+	if ctx.Err() != nil {
+		return nil, duration, asQueryError(name, rrtype, nameserver.String(), ctx.Err())
+	}
+
+	if err != nil {
+		err = asQueryError(name, rrtype, nameserver.String(), err)
+		var queryErr *QueryError
+		if result != nil && result.Rcode == dns.RcodeNameError && errors.As(err, &queryErr) {
+			// Kept so QueryContext can cache it, if it's trusted, once the query's failed.
+			queryErr.response = result
 		}
+		err = withExtendedErrors(result, err)
+		if observer := observerFrom(ctx); observer != nil {
+			observer.OnLookup(newTraceFailedLookup(name, rrtype, nameserver.String(), duration, result, err))
+		}
+		logger.Warn().Dur("latency", duration).Str("nameserver", nameserver.String()).Err(err).
+			Msg("Issue resolving query. If there are other nameservers they will still be tried.")
+		return nil, duration, err
+	}
 
-		//--
+	if d.Hardening != nil {
+		if result, err = harden(result, d.Hardening); err != nil {
+			logger.Warn().Dur("latency", duration).Str("nameserver", nameserver.String()).Err(err).
+				Msg("Response rejected. If there are other nameservers they will still be tried.")
+			return nil, duration, err
+		}
+	}
 
-		return result, totalDuration, err
+	// Only stripping is detected here; a flagged nameserver is cleared again by ProbeDNSSEC.
+	if reason := dnssecStrippedReason(result); reason != "" {
+		d.recordDNSSECSupport(nameserver.String(), reason)
 	}
 
 	//---
 
-	err := fmt.Errorf("no answer found on any configured nameserver")
-	logger.Warn().Dur("latency", totalDuration).Msg("No answer found on any configured nameserver")
+	// An unsigned answer that's allowed is checked locally instead, as the resolver won't set the AD bit on it.
+	if d.RemotelyAuthenticateData && !result.AuthenticatedData && !(d.AllowInsecure && d.LocallyAuthenticateData) {
+		logger.Error().Dur("latency", duration).Str("nameserver", nameserver.String()).
+			Msg("Resolver dnssec authentication failed")
+		return nil, duration, errResolverAuthentication
+	}
+
+	//---
+
+	// The level's checked directly, as creating an event to ask it would count towards any sampler set on the logger.
+	if logger.GetLevel() <= zerolog.DebugLevel && zerolog.GlobalLevel() <= zerolog.DebugLevel {
+		logger.Debug().Dur("latency", duration).Str("nameserver", nameserver.String()).
+			Bool("authenticated-data-flag", result.AuthenticatedData).
+			Int("number-of-answers", len(result.Answer)).
+			Strs("answers", rrsetToStrings(result.Answer)).
+			Msg("Answer to query found")
+	} else {
+		logger.Info().Dur("latency", duration).Str("nameserver", nameserver.String()).
+			Bool("authenticated-data-flag", result.AuthenticatedData).
+			Int("number-of-answers", len(result.Answer)).
+			Msg("Answer to query found")
+	}
+
+	//---
 
-	return nil, totalDuration, err
+	if observer := observerFrom(ctx); observer != nil {
+		observer.OnLookup(newtTraceLookup(name, rrtype, nameserver.String(), duration, result))
+	}
+
+	return result, duration, nil
+}
+
+// queryNameserver queries a nameserver, passing it ctx if it's a ContextNameServer. Otherwise, the query is abandoned
+// if ctx is done before it completes.
+func queryNameserver(ctx context.Context, nameserver NameServer, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	if ns, ok := nameserver.(ContextNameServer); ok {
+		return ns.QueryContext(ctx, name, rrtype)
+	}
+	if ctx.Done() == nil {
+		return nameserver.Query(name, rrtype)
+	}
+
+	type result struct {
+		msg      *dns.Msg
+		duration time.Duration
+		err      error
+	}
+
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		msg, duration, err := nameserver.Query(name, rrtype)
+		done <- result{msg, duration, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.msg, r.duration, r.err
+	case <-ctx.Done():
+		return nil, time.Since(start), asQueryError(name, rrtype, nameserver.String(), ctx.Err())
+	}
 }
 
 //-----
 
+// ownerNamesToUnicode returns a copy of the rrset with A-label (punycode) owner names converted to their Unicode form.
+// Names that cannot be converted are left unchanged.
+func ownerNamesToUnicode(rrset []dns.RR) []dns.RR {
+	results := make([]dns.RR, len(rrset))
+	for i, rr := range rrset {
+		results[i] = rr
+		if !strings.Contains(strings.ToLower(rr.Header().Name), "xn--") {
+			continue
+		}
+		if name, err := idna.Display.ToUnicode(rr.Header().Name); err == nil {
+			results[i] = dns.Copy(rr)
+			results[i].Header().Name = name
+		}
+	}
+	return results
+}
+
 // extractRecordsOfType Given a slice of RR, returns all instances within it of type T, cast to type T.
 func extractRecordsOfType[T dns.RR](rr []dns.RR) []T {
 	var result []T
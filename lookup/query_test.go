@@ -1,6 +1,7 @@
 package lookup
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/mock"
 	"net"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // OriginalMockNameServer represents a mock implementation of the NameServer interface.
@@ -126,3 +128,239 @@ func newLookupResponseMsgWithAD(rcode int, authenticatedData bool) *dns.Msg {
 	}
 	return msg
 }
+
+func TestDnsLookup_QueryAggregatesErrors(t *testing.T) {
+	timeout := fmt.Errorf("i/o timeout")
+
+	ns1 := &namedMockNameServer{name: "udp://192.0.2.1:53"}
+	ns1.On("Query", "example.com.", dns.TypeA).Return((*dns.Msg)(nil), time.Millisecond, timeout)
+	ns2 := &namedMockNameServer{name: "udp://192.0.2.2:53"}
+	ns2.On("Query", "example.com.", dns.TypeA).Return(newLookupResponseMsgWithAD(dns.RcodeServerFailure, false), time.Millisecond, fmt.Errorf("query error returned (rcode 2)"))
+
+	d := &DnsLookup{nameservers: []NameServer{ns1, ns2}}
+
+	_, _, err := d.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "no answer found on any configured nameserver")
+	assert.ErrorContains(t, err, "example.com. A query to udp://192.0.2.1:53 failed: i/o timeout")
+	assert.ErrorContains(t, err, "example.com. A query to udp://192.0.2.2:53 failed: query error returned (rcode 2)")
+	assert.ErrorIs(t, err, timeout)
+}
+
+// namedMockNameServer is a mock NameServer with a configurable name.
+type namedMockNameServer struct {
+	OriginalMockNameServer
+	name string
+}
+
+func (m *namedMockNameServer) String() string {
+	return m.name
+}
+
+func TestDnsLookup_QueryUnicodeOwnerNames(t *testing.T) {
+	response := newLookupResponseMsgWithAD(dns.RcodeSuccess, false)
+	response.Answer[0].Header().Name = "xn--bcher-kva.example."
+	original := response.Answer[0]
+
+	ns := &OriginalMockNameServer{response: response, rtt: time.Millisecond}
+	ns.On("Query", "xn--bcher-kva.example.", dns.TypeA).Return(response, time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	msg, _, err := d.Query("xn--bcher-kva.example.", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, "xn--bcher-kva.example.", msg.Answer[0].Header().Name)
+
+	d.UnicodeOwnerNames = true
+
+	msg, _, err = d.Query("xn--bcher-kva.example.", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, "bücher.example.", msg.Answer[0].Header().Name)
+
+	// The record received from the nameserver is left unchanged.
+	assert.Equal(t, "xn--bcher-kva.example.", original.Header().Name)
+}
+
+func TestDnsLookup_QueryContext(t *testing.T) {
+	ns := &blockingNameServer{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(ns.release)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	// A NameServer that doesn't support contexts is abandoned when the deadline passes.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := d.QueryContext(ctx, "example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.EqualError(t, err, "example.com. A query to "+ns.String()+" failed: context deadline exceeded")
+
+	// Nothing is sent once the context is done.
+	other := &namedMockNameServer{name: "other"}
+	d.nameservers = []NameServer{other}
+	_, err = d.QueryAContext(ctx, "example.com.")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, ErrTimeout)
+	other.AssertNotCalled(t, "Query", "example.com.", dns.TypeA)
+
+	// Nor when the nameservers are queried at once.
+	slow := &delayedNameServer{name: "slow", delay: 5 * time.Second}
+	d.nameservers = []NameServer{slow, &delayedNameServer{name: "slower", delay: 5 * time.Second}}
+	d.FanOut = FanOutAll
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = d.QueryContext(ctx, "example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorContains(t, err, "example.com. A query to slow failed: context deadline exceeded")
+	assert.ErrorContains(t, err, "example.com. A query to slower failed: context deadline exceeded")
+}
+
+// contextMockDNSClient is a ContextDNSClient that records the context it was given.
+type contextMockDNSClient struct {
+	ctx context.Context
+}
+
+func (c *contextMockDNSClient) Exchange(m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	return c.ExchangeContext(context.Background(), m, address)
+}
+
+func (c *contextMockDNSClient) ExchangeContext(ctx context.Context, m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	c.ctx = ctx
+	response := new(dns.Msg)
+	response.SetReply(m)
+	return response, time.Millisecond, nil
+}
+
+func TestDnsLookup_QueryContextPropagated(t *testing.T) {
+	client := &contextMockDNSClient{}
+	ns := NewUdpNameserver("192.0.2.1", "53").(*NameServerConcrete)
+	ns.client = client
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	_, _, err := d.QueryContext(ctx, "example.com.", dns.TypeA)
+	assert.NoError(t, err)
+	require.NotNil(t, client.ctx)
+	assert.Equal(t, "value", client.ctx.Value(key{}))
+}
+
+// delayedNameServer answers after a delay, unless the query's context is done first. Queries other than for an A
+// record are passed on to next, if it's set.
+type delayedNameServer struct {
+	name      string
+	delay     time.Duration
+	response  *dns.Msg
+	next      NameServer
+	cancelled chan struct{}
+}
+
+func (n *delayedNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	return n.QueryContext(context.Background(), name, rrtype)
+}
+
+func (n *delayedNameServer) QueryContext(ctx context.Context, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	select {
+	case <-time.After(n.delay):
+	case <-ctx.Done():
+		if n.cancelled != nil {
+			close(n.cancelled)
+		}
+		return nil, 0, ctx.Err()
+	}
+	switch {
+	case rrtype == dns.TypeA && n.response != nil:
+		return n.response, n.delay, nil
+	case n.next != nil:
+		return n.next.Query(name, rrtype)
+	}
+	return nil, n.delay, fmt.Errorf("no answer")
+}
+
+func (n *delayedNameServer) String() string {
+	return n.name
+}
+
+func TestDnsLookup_QueryFanOut(t *testing.T) {
+	response := newLookupResponseMsgWithAD(dns.RcodeSuccess, true)
+	slow := &delayedNameServer{name: "slow", delay: 5 * time.Second, response: response, cancelled: make(chan struct{})}
+	fast := &delayedNameServer{name: "fast", delay: 10 * time.Millisecond, response: response}
+
+	d := NewDnsLookup([]NameServer{slow, fast})
+	d.LocallyAuthenticateData = false
+	d.RandomNameserver = false
+	d.FanOut = FanOutAll
+
+	start := time.Now()
+	msg, _, err := d.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, response, msg)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The slower query is cancelled once an answer is found.
+	select {
+	case <-slow.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow query wasn't cancelled")
+	}
+
+	// With a fan out of one, the nameservers are tried in turn.
+	d.FanOut = 1
+	slow.delay, slow.response = 10*time.Millisecond, nil
+	fast.response = nil
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "example.com. A query to slow failed: no answer")
+	assert.ErrorContains(t, err, "example.com. A query to fast failed: no answer")
+}
+
+func TestDnsLookup_QueryFanOutUsesValidatedAnswer(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	// The fast nameserver answers first, but without signatures.
+	bogus := newAnswerMsg(t, "test.example.com. 300 IN A 192.0.2.99")
+	bogus.Question = []dns.Question{{Name: "test.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}
+	fast := &delayedNameServer{name: "fast", delay: time.Millisecond, response: bogus}
+	slow := &delayedNameServer{name: "slow", delay: 100 * time.Millisecond, next: NewUdpNameserver(server.Address, server.Port)}
+
+	d := NewDnsLookup([]NameServer{fast, slow})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+	d.FanOut = FanOutAll
+
+	records, err := d.QueryA("test.example.com.")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "192.0.2.1", records[0].A.String())
+}
+
+func TestDnsLookup_QueryUnicodeName(t *testing.T) {
+	response := newLookupResponseMsgWithAD(dns.RcodeSuccess, false)
+	response.Answer[0].Header().Name = "xn--bcher-kva.example."
+
+	ns := &OriginalMockNameServer{}
+	ns.On("Query", "xn--bcher-kva.example.", dns.TypeA).Return(response, time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}, UnicodeOwnerNames: true}
+
+	msg, _, err := d.Query("Bücher.example.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, "bücher.example.", msg.Answer[0].Header().Name)
+	ns.AssertExpectations(t)
+}
+
+func TestDnsLookup_QueryCERT(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "hugh.example.com.", dns.TypeCERT).Return(newAnswerMsg(t,
+		"hugh.example.com. 300 IN CERT PGP 0 0 AABBCCDD",
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QueryCERT("hugh.example.com.")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, uint16(dns.CertPGP), records[0].Type)
+	assert.Equal(t, "AABBCCDD", records[0].Certificate)
+}
@@ -1,6 +1,8 @@
 package lookup
 
 import (
+	"context"
+
 	"github.com/miekg/dns"
 )
 
@@ -8,7 +10,12 @@ import (
 
 // QueryA performs a DNS query for A records
 func (d *DnsLookup) QueryA(name string) ([]*dns.A, error) {
-	msg, _, err := d.Query(name, dns.TypeA)
+	return d.QueryAContext(context.Background(), name)
+}
+
+// QueryAContext performs a DNS query for A records, stopping when ctx is done
+func (d *DnsLookup) QueryAContext(ctx context.Context, name string) ([]*dns.A, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeA)
 	if err != nil {
 		return nil, err
 	}
@@ -17,7 +24,12 @@ func (d *DnsLookup) QueryA(name string) ([]*dns.A, error) {
 
 // QueryAAAA performs a DNS query for AAAA records
 func (d *DnsLookup) QueryAAAA(name string) ([]*dns.AAAA, error) {
-	msg, _, err := d.Query(name, dns.TypeAAAA)
+	return d.QueryAAAAContext(context.Background(), name)
+}
+
+// QueryAAAAContext performs a DNS query for AAAA records, stopping when ctx is done
+func (d *DnsLookup) QueryAAAAContext(ctx context.Context, name string) ([]*dns.AAAA, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeAAAA)
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +38,12 @@ func (d *DnsLookup) QueryAAAA(name string) ([]*dns.AAAA, error) {
 
 // QueryCNAME performs a DNS query for CNAME records
 func (d *DnsLookup) QueryCNAME(name string) ([]*dns.CNAME, error) {
-	msg, _, err := d.Query(name, dns.TypeCNAME)
+	return d.QueryCNAMEContext(context.Background(), name)
+}
+
+// QueryCNAMEContext performs a DNS query for CNAME records, stopping when ctx is done
+func (d *DnsLookup) QueryCNAMEContext(ctx context.Context, name string) ([]*dns.CNAME, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeCNAME)
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +52,12 @@ func (d *DnsLookup) QueryCNAME(name string) ([]*dns.CNAME, error) {
 
 // QueryMX performs a DNS query for MX records
 func (d *DnsLookup) QueryMX(name string) ([]*dns.MX, error) {
-	msg, _, err := d.Query(name, dns.TypeMX)
+	return d.QueryMXContext(context.Background(), name)
+}
+
+// QueryMXContext performs a DNS query for MX records, stopping when ctx is done
+func (d *DnsLookup) QueryMXContext(ctx context.Context, name string) ([]*dns.MX, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeMX)
 	if err != nil {
 		return nil, err
 	}
@@ -44,25 +66,26 @@ func (d *DnsLookup) QueryMX(name string) ([]*dns.MX, error) {
 
 // QueryNS performs a DNS query for NS records
 func (d *DnsLookup) QueryNS(name string) ([]*dns.NS, error) {
-	msg, _, err := d.Query(name, dns.TypeNS)
-	if err != nil {
-		return nil, err
-	}
-	return extractRecordsOfType[*dns.NS](msg.Answer), nil
+	return d.QueryNSContext(context.Background(), name)
 }
 
-// QueryPTR performs a DNS query for PTR records
-func (d *DnsLookup) QueryPTR(name string) ([]*dns.PTR, error) {
-	msg, _, err := d.Query(name, dns.TypePTR)
+// QueryNSContext performs a DNS query for NS records, stopping when ctx is done
+func (d *DnsLookup) QueryNSContext(ctx context.Context, name string) ([]*dns.NS, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeNS)
 	if err != nil {
 		return nil, err
 	}
-	return extractRecordsOfType[*dns.PTR](msg.Answer), nil
+	return extractRecordsOfType[*dns.NS](msg.Answer), nil
 }
 
 // QuerySOA performs a DNS query for SOA records
 func (d *DnsLookup) QuerySOA(name string) ([]*dns.SOA, error) {
-	msg, _, err := d.Query(name, dns.TypeSOA)
+	return d.QuerySOAContext(context.Background(), name)
+}
+
+// QuerySOAContext performs a DNS query for SOA records, stopping when ctx is done
+func (d *DnsLookup) QuerySOAContext(ctx context.Context, name string) ([]*dns.SOA, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeSOA)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +94,12 @@ func (d *DnsLookup) QuerySOA(name string) ([]*dns.SOA, error) {
 
 // QuerySRV performs a DNS query for SRV records
 func (d *DnsLookup) QuerySRV(name string) ([]*dns.SRV, error) {
-	msg, _, err := d.Query(name, dns.TypeSRV)
+	return d.QuerySRVContext(context.Background(), name)
+}
+
+// QuerySRVContext performs a DNS query for SRV records, stopping when ctx is done
+func (d *DnsLookup) QuerySRVContext(ctx context.Context, name string) ([]*dns.SRV, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeSRV)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +108,12 @@ func (d *DnsLookup) QuerySRV(name string) ([]*dns.SRV, error) {
 
 // QueryTXT performs a DNS query for TXT records
 func (d *DnsLookup) QueryTXT(name string) ([]*dns.TXT, error) {
-	msg, _, err := d.Query(name, dns.TypeTXT)
+	return d.QueryTXTContext(context.Background(), name)
+}
+
+// QueryTXTContext performs a DNS query for TXT records, stopping when ctx is done
+func (d *DnsLookup) QueryTXTContext(ctx context.Context, name string) ([]*dns.TXT, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeTXT)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +122,12 @@ func (d *DnsLookup) QueryTXT(name string) ([]*dns.TXT, error) {
 
 // QueryDS performs a DNS query for DS records
 func (d *DnsLookup) QueryDS(name string) ([]*dns.DS, error) {
-	msg, _, err := d.Query(name, dns.TypeDS)
+	return d.QueryDSContext(context.Background(), name)
+}
+
+// QueryDSContext performs a DNS query for DS records, stopping when ctx is done
+func (d *DnsLookup) QueryDSContext(ctx context.Context, name string) ([]*dns.DS, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeDS)
 	if err != nil {
 		return nil, err
 	}
@@ -98,16 +136,68 @@ func (d *DnsLookup) QueryDS(name string) ([]*dns.DS, error) {
 
 // QueryDNSKEY performs a DNS query for DNSKEY records
 func (d *DnsLookup) QueryDNSKEY(name string) ([]*dns.DNSKEY, error) {
-	msg, _, err := d.Query(name, dns.TypeDNSKEY)
+	return d.QueryDNSKEYContext(context.Background(), name)
+}
+
+// QueryDNSKEYContext performs a DNS query for DNSKEY records, stopping when ctx is done
+func (d *DnsLookup) QueryDNSKEYContext(ctx context.Context, name string) ([]*dns.DNSKEY, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeDNSKEY)
 	if err != nil {
 		return nil, err
 	}
 	return extractRecordsOfType[*dns.DNSKEY](msg.Answer), nil
 }
 
+// QueryNSEC performs a DNS query for NSEC records
+func (d *DnsLookup) QueryNSEC(name string) ([]*dns.NSEC, error) {
+	return d.QueryNSECContext(context.Background(), name)
+}
+
+// QueryNSECContext performs a DNS query for NSEC records, stopping when ctx is done
+func (d *DnsLookup) QueryNSECContext(ctx context.Context, name string) ([]*dns.NSEC, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeNSEC)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.NSEC](msg.Answer), nil
+}
+
+// QueryNSEC3PARAM performs a DNS query for NSEC3PARAM records
+func (d *DnsLookup) QueryNSEC3PARAM(name string) ([]*dns.NSEC3PARAM, error) {
+	return d.QueryNSEC3PARAMContext(context.Background(), name)
+}
+
+// QueryNSEC3PARAMContext performs a DNS query for NSEC3PARAM records, stopping when ctx is done
+func (d *DnsLookup) QueryNSEC3PARAMContext(ctx context.Context, name string) ([]*dns.NSEC3PARAM, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeNSEC3PARAM)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.NSEC3PARAM](msg.Answer), nil
+}
+
+// QueryCERT performs a DNS query for CERT records
+func (d *DnsLookup) QueryCERT(name string) ([]*dns.CERT, error) {
+	return d.QueryCERTContext(context.Background(), name)
+}
+
+// QueryCERTContext performs a DNS query for CERT records, stopping when ctx is done
+func (d *DnsLookup) QueryCERTContext(ctx context.Context, name string) ([]*dns.CERT, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeCERT)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.CERT](msg.Answer), nil
+}
+
 // QueryANY performs a DNS query for ANY records
 func (d *DnsLookup) QueryANY(name string) ([]dns.RR, error) {
-	msg, _, err := d.Query(name, dns.TypeANY)
+	return d.QueryANYContext(context.Background(), name)
+}
+
+// QueryANYContext performs a DNS query for ANY records, stopping when ctx is done
+func (d *DnsLookup) QueryANYContext(ctx context.Context, name string) ([]dns.RR, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeANY)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,55 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit limits the queries sent to the nameserver to qps a second, allowing bursts of up to burst queries,
+// so bulk lookups aren't throttled or blocked by public resolvers. Queries over the limit wait, until their context is
+// done. Each retry counts as a query. Both qps and burst must be positive.
+func WithRateLimit(qps float64, burst int) NameServerOption {
+	return func(n *NameServerConcrete) {
+		limiter, err := newRateLimiter(qps, burst)
+		if err != nil {
+			n.err = err
+			return
+		}
+		n.limiter = limiter
+	}
+}
+
+// WithHttpRateLimit limits the queries sent to the DoH nameserver. See WithRateLimit.
+func WithHttpRateLimit(qps float64, burst int) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		limiter, err := newRateLimiter(qps, burst)
+		if err != nil {
+			n.err = err
+			return
+		}
+		n.limiter = limiter
+	}
+}
+
+// newRateLimiter returns a limiter for qps queries a second, with bursts of up to burst. A limit that isn't positive
+// would hold every query after the burst until its context is done, so it's an error.
+func newRateLimiter(qps float64, burst int) (*rate.Limiter, error) {
+	if !(qps > 0) || math.IsInf(qps, 0) {
+		return nil, fmt.Errorf("invalid rate limit of %v queries a second", qps)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("invalid rate limit burst of %d queries", burst)
+	}
+	return rate.NewLimiter(rate.Limit(qps), burst), nil
+}
+
+// waitForRateLimit waits until the limiter allows a query, or ctx is done. It returns straight away if limiter is nil.
+func waitForRateLimit(ctx context.Context, limiter *rate.Limiter) error {
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
@@ -0,0 +1,68 @@
+package lookup
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+	ns := NewUdpNameserver("192.0.2.1", "53", WithRateLimit(20, 2)).(*NameServerConcrete)
+	ns.client = client
+
+	// The burst is sent straight away; the query after it waits for the limit.
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, _, err := ns.Query("example.com.", dns.TypeA)
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	// A query that can't be sent before its deadline fails without waiting.
+	ns = NewUdpNameserver("192.0.2.1", "53", WithRateLimit(0.1, 1)).(*NameServerConcrete)
+	ns.client = client
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, _, err = ns.QueryContext(ctx, "example.com.", dns.TypeA)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestWithRateLimit_Invalid(t *testing.T) {
+	client := &MockDNSClient{response: newNameserverResponseMsgWithAD(dns.RcodeSuccess, true)}
+	for _, limit := range []struct {
+		qps   float64
+		burst int
+	}{{0, 1}, {-1, 1}, {math.NaN(), 1}, {math.Inf(1), 1}, {5, 0}, {5, -1}} {
+		ns := NewUdpNameserver("192.0.2.1", "53", WithRateLimit(limit.qps, limit.burst)).(*NameServerConcrete)
+		ns.client = client
+		assert.ErrorContains(t, ns.Err(), "invalid rate limit", limit)
+		assert.Nil(t, ns.limiter)
+
+		_, _, err := ns.Query("example.com.", dns.TypeA)
+		assert.ErrorContains(t, err, "invalid nameserver udp://192.0.2.1:53: invalid rate limit")
+	}
+}
+
+func TestWithHttpRateLimit(t *testing.T) {
+	doh := NewHttpsNameserver("https://dns.example/dns-query", WithHttpRateLimit(5, 2)).(*HttpsNameServer)
+	require.NoError(t, doh.Err())
+	require.NotNil(t, doh.limiter)
+	assert.Equal(t, 2, doh.limiter.Burst())
+
+	doh = NewHttpsNameserver("https://dns.example/dns-query", WithHttpRateLimit(5, 0)).(*HttpsNameServer)
+	assert.ErrorContains(t, doh.Err(), "invalid rate limit burst of 0 queries")
+
+	doh = NewHttpsNameserver("https://dns.example/dns-query", WithHttpRateLimit(0, 1)).(*HttpsNameServer)
+	assert.ErrorContains(t, doh.Err(), "invalid rate limit of 0 queries a second")
+}
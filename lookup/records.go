@@ -0,0 +1,57 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// rrtypes maps each record's Go type, e.g. *dns.MX, to its rrtype. Go types shared by more than one rrtype are left
+// out, as the rrtype can't be inferred from them.
+var rrtypes = sync.OnceValue(func() map[reflect.Type]uint16 {
+	types := make(map[reflect.Type]uint16)
+	ambiguous := make(map[reflect.Type]bool)
+	for rrtype, newRR := range dns.TypeToRR {
+		t := reflect.TypeOf(newRR())
+		if _, ok := types[t]; ok {
+			ambiguous[t] = true
+		}
+		types[t] = rrtype
+	}
+	for t := range ambiguous {
+		delete(types, t)
+	}
+	return types
+})
+
+// rrtypeOf returns the rrtype of records of type T.
+func rrtypeOf[T dns.RR]() (uint16, error) {
+	t := reflect.TypeFor[T]()
+	rrtype, ok := rrtypes()[t]
+	if !ok {
+		return 0, fmt.Errorf("unable to infer the record type of %s", t)
+	}
+	return rrtype, nil
+}
+
+// QueryRecords performs a DNS query for records of type T, inferring the rrtype from it, e.g.
+// QueryRecords[*dns.MX](d, "example.com"). Any record type miekg/dns supports can be queried this way.
+func QueryRecords[T dns.RR](d *DnsLookup, name string) ([]T, error) {
+	return QueryRecordsContext[T](context.Background(), d, name)
+}
+
+// QueryRecordsContext performs a DNS query for records of type T, stopping when ctx is done.
+func QueryRecordsContext[T dns.RR](ctx context.Context, d *DnsLookup, name string) ([]T, error) {
+	rrtype, err := rrtypeOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	msg, _, err := d.QueryContext(ctx, name, rrtype)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[T](msg.Answer), nil
+}
@@ -0,0 +1,45 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRrtypeOf(t *testing.T) {
+	rrtype, err := rrtypeOf[*dns.MX]()
+	require.NoError(t, err)
+	assert.Equal(t, dns.TypeMX, rrtype)
+
+	rrtype, err = rrtypeOf[*dns.HTTPS]()
+	require.NoError(t, err)
+	assert.Equal(t, dns.TypeHTTPS, rrtype)
+
+	rrtype, err = rrtypeOf[*dns.SVCB]()
+	require.NoError(t, err)
+	assert.Equal(t, dns.TypeSVCB, rrtype)
+
+	_, err = rrtypeOf[dns.RR]()
+	assert.ErrorContains(t, err, "unable to infer the record type of dns.RR")
+}
+
+func TestQueryRecords(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeCAA).Return(newAnswerMsg(t,
+		`example.com. 300 IN CAA 0 issue "letsencrypt.org"`,
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := QueryRecords[*dns.CAA](d, "example.com.")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "letsencrypt.org", records[0].Value)
+
+	_, err = QueryRecords[dns.RR](d, "example.com.")
+	assert.Error(t, err)
+	ns.AssertNumberOfCalls(t, "Query", 1)
+}
@@ -0,0 +1,163 @@
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NetResolver returns a *net.Resolver that answers its queries using the DnsLookup, so code written against the
+// standard library, e.g. net.Dialer, or http.Transport via its DialContext, gets answers from the DnsLookup's
+// nameservers, authenticated as they would be by QueryContext.
+//
+// The resolver still consults the hosts file, and applies the search domains in /etc/resolv.conf, as Go's own
+// resolver does. An answer that fails authentication is returned to it as SERVFAIL.
+func (d *DnsLookup) NetResolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &resolverConn{ctx: ctx, lookup: d}, nil
+		},
+	}
+}
+
+// resolverConn is the connection a NetResolver's queries are sent on. Rather than sending them to a nameserver, each
+// query is answered by the DnsLookup as it's written, and the response buffered to be read back. It doesn't implement
+// net.PacketConn, so messages are framed with a length prefix, as they are over TCP.
+type resolverConn struct {
+	ctx    context.Context
+	lookup *DnsLookup
+
+	mu       sync.Mutex
+	in       bytes.Buffer // Query bytes written, but not yet making up a whole message
+	out      bytes.Buffer // Responses waiting to be read
+	deadline time.Time
+	closed   bool
+}
+
+// Write answers each complete query written, buffering the responses.
+func (c *resolverConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	c.in.Write(b)
+
+	for c.in.Len() >= 2 {
+		length := int(binary.BigEndian.Uint16(c.in.Bytes()))
+		if c.in.Len() < 2+length {
+			break
+		}
+		c.in.Next(2)
+
+		response, err := c.answer(c.in.Next(length))
+		if err != nil {
+			return 0, err
+		}
+		c.out.Write(binary.BigEndian.AppendUint16(nil, uint16(len(response))))
+		c.out.Write(response)
+	}
+	return len(b), nil
+}
+
+// Read reads the buffered responses, returning io.EOF when there are none.
+func (c *resolverConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if c.out.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.out.Read(b)
+}
+
+// answer queries the DnsLookup for a packed query, returning the packed response. NXDOMAIN is passed back as such,
+// so the resolver reports the name as not found; every other failure is returned as SERVFAIL.
+func (c *resolverConn) answer(query []byte) ([]byte, error) {
+	request := new(dns.Msg)
+	if err := request.Unpack(query); err != nil {
+		return nil, err
+	}
+
+	response := new(dns.Msg)
+	response.SetReply(request)
+	response.RecursionAvailable = true
+
+	if len(request.Question) != 1 {
+		response.Rcode = dns.RcodeFormatError
+		return response.Pack()
+	}
+
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	question := request.Question[0]
+	msg, _, err := c.lookup.QueryContext(ctx, question.Name, question.Qtype)
+
+	switch {
+	case err == nil:
+		response.Answer, response.Ns = msg.Answer, msg.Ns
+	case errors.Is(err, ErrNXDomain):
+		response.Rcode = dns.RcodeNameError
+	default:
+		response.Rcode = dns.RcodeServerFailure
+	}
+	return response.Pack()
+}
+
+func (c *resolverConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *resolverConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *resolverConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *resolverConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+func (c *resolverConn) LocalAddr() net.Addr {
+	return resolverAddr{}
+}
+
+func (c *resolverConn) RemoteAddr() net.Addr {
+	return resolverAddr{}
+}
+
+// resolverAddr is the address of both ends of a resolverConn.
+type resolverAddr struct{}
+
+func (resolverAddr) Network() string {
+	return "dns-lookup"
+}
+
+func (resolverAddr) String() string {
+	return "dns-lookup"
+}
@@ -0,0 +1,60 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetResolver(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "host.example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "host.example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+	ns.On("Query", "host.example.com.", dns.TypeAAAA).Return(
+		newAnswerMsg(t, "host.example.com. 300 IN AAAA 2001:db8::1"), time.Millisecond, nil)
+	ns.On("Query", "1.2.0.192.in-addr.arpa.", dns.TypePTR).Return(
+		newAnswerMsg(t, "1.2.0.192.in-addr.arpa. 300 IN PTR host.example.com."), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+	resolver := d.NetResolver()
+
+	addrs, err := resolver.LookupHost(context.Background(), "host.example.com.")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"192.0.2.1", "2001:db8::1"}, addrs)
+
+	names, err := resolver.LookupAddr(context.Background(), "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host.example.com."}, names)
+}
+
+func TestNetResolver_Errors(t *testing.T) {
+	nxdomain := newLookupResponseMsgWithAD(dns.RcodeNameError, true)
+	unauthenticated := newAnswerMsg(t, "bogus.example.com. 300 IN A 192.0.2.1")
+	unauthenticated.AuthenticatedData = false
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "missing.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("", 0, "mock", dns.RcodeNameError))
+	ns.On("Query", "missing.example.com.", dns.TypeAAAA).Return(nxdomain, time.Millisecond, newRcodeError("", 0, "mock", dns.RcodeNameError))
+	ns.On("Query", "bogus.example.com.", dns.TypeA).Return(unauthenticated, time.Millisecond, nil)
+	ns.On("Query", "bogus.example.com.", dns.TypeAAAA).Return(unauthenticated, time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}, RemotelyAuthenticateData: true}
+	resolver := d.NetResolver()
+
+	var dnsErr *net.DNSError
+
+	_, err := resolver.LookupHost(context.Background(), "missing.example.com.")
+	require.True(t, errors.As(err, &dnsErr))
+	assert.True(t, dnsErr.IsNotFound)
+
+	// An answer that fails authentication isn't used.
+	_, err = resolver.LookupHost(context.Background(), "bogus.example.com.")
+	require.True(t, errors.As(err, &dnsErr))
+	assert.False(t, dnsErr.IsNotFound)
+}
@@ -0,0 +1,98 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Result is the outcome of a query, along with details of the response and where it came from. It's returned by
+// QueryResult, QueryAsync and QueryBatch.
+type Result struct {
+	Name       string           // The name queried, as it was given
+	Rrtype     uint16           // The record type queried
+	Msg        *dns.Msg         // The response; nil if Err is set
+	Duration   time.Duration    // How long the query took
+	Err        error            // Set if the query failed
+	Nameserver string           // The nameserver that answered, or "cache" or "hosts"
+	Rcode      int              // The response's rcode, or -1 if there wasn't a response
+	Flags      Flags            // The response's header flags, e.g. AD and TC; unset if Err is set
+	TTL        time.Duration    // The lowest TTL of the response's records
+	Validation ValidationStatus // The outcome of validating the response locally
+	Trace      *Trace           // The query's trace, when EnableTrace is set or ctx has one; nil otherwise
+}
+
+// queryInfo records where a query's answer came from, as QueryContext finds it.
+type queryInfo struct {
+	nameserver string
+	validation ValidationStatus
+}
+
+// setQueryInfo records where an answer came from, if the query is being made for a Result.
+func setQueryInfo(ctx context.Context, nameserver string, validation ValidationStatus) {
+	if info, ok := ctx.Value(contextInfo).(*queryInfo); ok {
+		info.nameserver, info.validation = nameserver, validation
+	}
+}
+
+// validationStatus returns the validation state of an answer that's been accepted. Without LocallyAuthenticateData,
+// the answer wasn't validated, so is ValidationIndeterminate. With AllowInsecure, an unsigned answer was accepted
+// because it's ValidationInsecure.
+func (d *DnsLookup) validationStatus(msg *dns.Msg) ValidationStatus {
+	switch {
+	case !d.LocallyAuthenticateData:
+		return ValidationIndeterminate
+	case d.AllowInsecure && !isSigned(msg):
+		return ValidationInsecure
+	default:
+		return ValidationSecure
+	}
+}
+
+// QueryResult performs a DNS query, as Query does, returning the outcome as a Result.
+func (d *DnsLookup) QueryResult(name string, rrtype uint16) Result {
+	return d.QueryResultContext(context.Background(), name, rrtype)
+}
+
+// QueryResultContext performs a DNS query, stopping when ctx is done, returning the outcome as a Result. When
+// EnableTrace is set, the query is traced to its own Trace, returned in the Result, so queries can be made concurrently
+// without their traces being lost; DnsLookup.Trace is left as it was.
+func (d *DnsLookup) QueryResultContext(ctx context.Context, name string, rrtype uint16) Result {
+	trace, _ := ctx.Value(contextQueryTrace).(*Trace)
+	if trace == nil && d.EnableTrace {
+		trace = new(Trace)
+		ctx = ContextWithTrace(ctx, trace)
+	}
+
+	info := &queryInfo{}
+	msg, duration, err := d.QueryContext(context.WithValue(ctx, contextInfo, info), name, rrtype)
+
+	result := Result{Name: name, Rrtype: rrtype, Msg: msg, Duration: duration, Err: err, Rcode: -1, Trace: trace}
+	if err != nil {
+		var queryErr *QueryError
+		if errors.As(err, &queryErr) {
+			result.Nameserver, result.Rcode = queryErr.Nameserver, queryErr.Rcode
+		}
+		if errors.Is(err, ErrDNSSECBogus) {
+			result.Validation = ValidationBogus
+		}
+		return result
+	}
+
+	result.Nameserver = info.nameserver
+	result.Validation = info.validation
+	result.Rcode = msg.Rcode
+	result.Flags = NewFlags(msg)
+	result.TTL = time.Duration(minTTL(msg)) * time.Second
+	return result
+}
+
+// Answers returns the records of type T from a Result's answer section, e.g. Answers[*dns.MX](result).
+func Answers[T dns.RR](r Result) []T {
+	if r.Msg == nil {
+		return nil
+	}
+	return extractRecordsOfType[T](r.Msg.Answer)
+}
@@ -0,0 +1,79 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDnsLookup_QueryResult(t *testing.T) {
+	response := newLookupResponseMsgWithAD(dns.RcodeSuccess, true)
+	response.Truncated = true
+	response.RecursionAvailable = true
+
+	ns := &namedMockNameServer{name: "udp://192.0.2.1:53"}
+	ns.On("Query", "example.com.", dns.TypeA).Return(response, time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.Cache = NewCache(10)
+
+	result := d.QueryResult("example.com.", dns.TypeA)
+	require.NoError(t, result.Err)
+	assert.Equal(t, "udp://192.0.2.1:53", result.Nameserver)
+	assert.Equal(t, dns.RcodeSuccess, result.Rcode)
+	assert.True(t, result.Flags.AuthenticatedData)
+	assert.True(t, result.Flags.Truncated)
+	assert.True(t, result.Flags.RecursionAvailable)
+	assert.False(t, result.Flags.Authoritative)
+	assert.Equal(t, "NOERROR", result.Flags.RcodeName)
+	assert.Equal(t, 300*time.Second, result.TTL)
+	assert.Equal(t, time.Millisecond, result.Duration)
+	assert.Equal(t, ValidationIndeterminate, result.Validation)
+
+	records := Answers[*dns.A](result)
+	require.Len(t, records, 1)
+	assert.Equal(t, "127.0.0.1", records[0].A.String())
+	assert.Empty(t, Answers[*dns.AAAA](result))
+
+	// The second answer comes from the cache.
+	result = d.QueryResult("example.com.", dns.TypeA)
+	require.NoError(t, result.Err)
+	assert.Equal(t, "cache", result.Nameserver)
+	ns.AssertNumberOfCalls(t, "Query", 1)
+}
+
+func TestDnsLookup_QueryResultError(t *testing.T) {
+	ns := &namedMockNameServer{name: "udp://192.0.2.1:53"}
+	response := newLookupResponseMsgWithAD(dns.RcodeNameError, false)
+	ns.On("Query", "example.com.", dns.TypeA).Return(response, time.Millisecond, newRcodeError("example.com.", dns.TypeA, ns.name, dns.RcodeNameError))
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	result := d.QueryResult("example.com.", dns.TypeA)
+	assert.ErrorIs(t, result.Err, ErrNXDomain)
+	assert.Nil(t, result.Msg)
+	assert.Nil(t, Answers[*dns.A](result))
+	assert.Equal(t, "udp://192.0.2.1:53", result.Nameserver)
+	assert.Equal(t, dns.RcodeNameError, result.Rcode)
+}
+
+func TestDnsLookup_ValidationStatus(t *testing.T) {
+	unsigned := newLookupResponseMsgWithAD(dns.RcodeSuccess, false)
+	signed := newLookupResponseMsgWithAD(dns.RcodeSuccess, false)
+	signed.Answer = append(signed.Answer, &dns.RRSIG{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeRRSIG, Class: dns.ClassINET}})
+
+	d := &DnsLookup{}
+	assert.Equal(t, ValidationIndeterminate, d.validationStatus(signed))
+
+	d.LocallyAuthenticateData = true
+	assert.Equal(t, ValidationSecure, d.validationStatus(signed))
+
+	d.AllowInsecure = true
+	assert.Equal(t, ValidationSecure, d.validationStatus(signed))
+	assert.Equal(t, ValidationInsecure, d.validationStatus(unsigned))
+}
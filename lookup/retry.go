@@ -0,0 +1,114 @@
+package lookup
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RetryPolicy sets how a query to a nameserver is retried before the next nameserver is tried. Queries are retried
+// when the nameserver didn't respond, or responded with SERVFAIL; other responses are final.
+type RetryPolicy struct {
+	Attempts       int           // The number of times to query each nameserver; 0 is treated as 1
+	Timeout        time.Duration // How long each attempt may take; 0 leaves it to the nameserver, and the query's context
+	InitialBackoff time.Duration // How long to wait before the second attempt
+	MaxBackoff     time.Duration // The longest to wait between attempts; 0 is no limit
+	Multiplier     float64       // How much the wait grows by after each attempt; 0 is treated as 2
+	Jitter         float64       // The fraction, from 0 to 1, of each wait that's randomised, so clients don't retry in step
+}
+
+// DefaultRetryPolicy makes three attempts, two seconds each, waiting 100ms and then 200ms, ±20%, between them.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:       3,
+	Timeout:        2 * time.Second,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// WithRetryPolicy sets the RetryPolicy for queries to the nameserver, in place of the DnsLookup's RetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) NameServerOption {
+	return func(n *NameServerConcrete) {
+		n.retry = &policy
+	}
+}
+
+// WithHttpRetryPolicy sets the RetryPolicy for queries to the DoH nameserver, in place of the DnsLookup's RetryPolicy.
+func WithHttpRetryPolicy(policy RetryPolicy) HttpsNameServerOption {
+	return func(n *HttpsNameServer) {
+		n.retry = &policy
+	}
+}
+
+func (n NameServerConcrete) retryPolicy() *RetryPolicy {
+	return n.retry
+}
+
+func (n HttpsNameServer) retryPolicy() *RetryPolicy {
+	return n.retry
+}
+
+// backoff returns how long to wait after the given attempt, counting from 1.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// retryPolicyFor returns the RetryPolicy for a nameserver: its own, if it has one, otherwise the DnsLookup's.
+func (d *DnsLookup) retryPolicyFor(nameserver NameServer) *RetryPolicy {
+	if n, ok := nameserver.(interface{ retryPolicy() *RetryPolicy }); ok && n.retryPolicy() != nil {
+		return n.retryPolicy()
+	}
+	return d.RetryPolicy
+}
+
+// queryWithRetries queries a nameserver, retrying as set by its RetryPolicy. The duration returned covers every
+// attempt, and the waits between them.
+func (d *DnsLookup) queryWithRetries(ctx context.Context, nameserver NameServer, name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	policy := d.retryPolicyFor(nameserver)
+	if policy == nil {
+		return queryNameserver(ctx, nameserver, name, rrtype)
+	}
+
+	logger := d.componentLogger(LogComponentQuery)
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		}
+		result, _, err := queryNameserver(attemptCtx, nameserver, name, rrtype)
+		cancel()
+
+		retryable := err != nil && (result == nil || result.Rcode == dns.RcodeServerFailure)
+		if !retryable || attempt >= policy.Attempts || ctx.Err() != nil {
+			return result, time.Since(start), err
+		}
+
+		wait := policy.backoff(attempt)
+		logger.Debug().Str("nameserver", nameserver.String()).Int("attempt", attempt).Dur("backoff", wait).Err(err).
+			Msg("Retrying query")
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, time.Since(start), ctx.Err()
+		}
+	}
+}
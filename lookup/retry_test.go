@@ -0,0 +1,113 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyNameServer fails a set number of times, with a response of rcode if it's set, before answering.
+type flakyNameServer struct {
+	failures int
+	rcode    int
+	calls    int
+}
+
+func (n *flakyNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	n.calls++
+	if n.calls <= n.failures {
+		if n.rcode != 0 {
+			return newLookupResponseMsgWithAD(n.rcode, true), time.Millisecond, errors.New("query error returned")
+		}
+		return nil, time.Millisecond, errors.New("connection refused")
+	}
+	return newLookupResponseMsgWithAD(dns.RcodeSuccess, true), time.Millisecond, nil
+}
+
+func (n *flakyNameServer) String() string {
+	return "flaky"
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(3))
+
+	policy.Multiplier, policy.MaxBackoff = 3, 0
+	assert.Equal(t, 900*time.Millisecond, policy.backoff(3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		wait := policy.backoff(1)
+		assert.GreaterOrEqual(t, wait, 50*time.Millisecond)
+		assert.LessOrEqual(t, wait, 150*time.Millisecond)
+	}
+}
+
+func TestDnsLookup_QueryRetries(t *testing.T) {
+	ns := &flakyNameServer{failures: 2}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+
+	// Without a policy, there's a single attempt.
+	_, _, err := d.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Equal(t, 1, ns.calls)
+
+	ns.calls = 0
+	d.RetryPolicy = &RetryPolicy{Attempts: 3, InitialBackoff: time.Millisecond}
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, ns.calls)
+
+	ns.calls, ns.failures = 0, 5
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Equal(t, 3, ns.calls)
+
+	// SERVFAIL is retried, but other errors from the nameserver aren't.
+	ns.calls, ns.failures, ns.rcode = 0, 1, dns.RcodeServerFailure
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ns.calls)
+
+	ns.calls, ns.rcode = 0, dns.RcodeRefused
+	_, _, err = d.Query("example.com.", dns.TypeA)
+	assert.Error(t, err)
+	assert.Equal(t, 1, ns.calls)
+}
+
+func TestDnsLookup_QueryRetryTimeout(t *testing.T) {
+	ns := &delayedNameServer{name: "slow", delay: time.Second, response: newLookupResponseMsgWithAD(dns.RcodeSuccess, true)}
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.RetryPolicy = &RetryPolicy{Attempts: 2, Timeout: 20 * time.Millisecond, InitialBackoff: time.Millisecond}
+
+	start := time.Now()
+	_, _, err := d.Query("example.com.", dns.TypeA)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestDnsLookup_NameserverRetryPolicy(t *testing.T) {
+	d := NewDnsLookup(nil)
+	d.RetryPolicy = &DefaultRetryPolicy
+
+	own := RetryPolicy{Attempts: 5}
+	udp := NewUdpNameserver("192.0.2.1", "53", WithRetryPolicy(own))
+	doh := NewHttpsNameserver("https://dns.example/dns-query", WithHttpRetryPolicy(own), WithHttpMethod(http.MethodPost))
+
+	require.NotNil(t, d.retryPolicyFor(udp))
+	assert.Equal(t, own, *d.retryPolicyFor(udp))
+	assert.Equal(t, own, *d.retryPolicyFor(doh))
+	assert.Equal(t, &DefaultRetryPolicy, d.retryPolicyFor(NewTcpNameserver("192.0.2.1", "53")))
+}
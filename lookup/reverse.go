@@ -0,0 +1,95 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxReverseCnameChain is the number of CNAMEs QueryPTR will follow before giving up.
+const maxReverseCnameChain = 8
+
+// QueryPTR performs a DNS query for PTR records.
+//
+// Classless reverse delegations (RFC 2317) alias each address in a slice smaller than a /24 to a name within the
+// delegated zone, e.g. 1.0.0.192.in-addr.arpa. to 1.0/25.0.0.192.in-addr.arpa. If the answer holds a CNAME but
+// no PTR records, the CNAME is followed and the query repeated against its target.
+func (d *DnsLookup) QueryPTR(name string) ([]*dns.PTR, error) {
+	return d.QueryPTRContext(context.Background(), name)
+}
+
+// QueryPTRContext performs a DNS query for PTR records, following classless delegations, stopping when ctx is done.
+func (d *DnsLookup) QueryPTRContext(ctx context.Context, name string) ([]*dns.PTR, error) {
+	seen := make(map[string]bool)
+	for i := 0; i <= maxReverseCnameChain; i++ {
+		msg, _, err := d.QueryContext(ctx, name, dns.TypePTR)
+		if err != nil {
+			return nil, err
+		}
+
+		if ptrs := extractRecordsOfType[*dns.PTR](msg.Answer); len(ptrs) > 0 {
+			return ptrs, nil
+		}
+
+		target := cnameTarget(name, msg.Answer)
+		if target == "" {
+			return []*dns.PTR{}, nil
+		}
+
+		seen[strings.ToLower(dns.Fqdn(name))] = true
+		if seen[strings.ToLower(target)] {
+			return nil, fmt.Errorf("CNAME loop detected following %s", target)
+		}
+		name = target
+	}
+	return nil, fmt.Errorf("more than %d CNAMEs followed looking up PTR records", maxReverseCnameChain)
+}
+
+// QueryPTRForIP performs a DNS query for the PTR records of an IPv4 or IPv6 address, using its in-addr.arpa. or
+// ip6.arpa. name. Classless delegations are followed, as they are by QueryPTR.
+func (d *DnsLookup) QueryPTRForIP(ip net.IP) ([]*dns.PTR, error) {
+	return d.QueryPTRForIPContext(context.Background(), ip)
+}
+
+// QueryPTRForIPContext performs a DNS query for the PTR records of an IP address, stopping when ctx is done.
+func (d *DnsLookup) QueryPTRForIPContext(ctx context.Context, ip net.IP) ([]*dns.PTR, error) {
+	name, err := reverseName(ip)
+	if err != nil {
+		return nil, err
+	}
+	return d.QueryPTRContext(ctx, name)
+}
+
+// reverseName returns the name PTR records are found at for an IP address, e.g. 1.2.0.192.in-addr.arpa. for
+// 192.0.2.1, with IPv6 addresses expanded to one label per nibble under ip6.arpa. IPv4-mapped IPv6 addresses are
+// treated as IPv4.
+func reverseName(ip net.IP) (string, error) {
+	if ip.To16() == nil {
+		return "", fmt.Errorf("invalid ip address %v", ip)
+	}
+	return dns.ReverseAddr(ip.String())
+}
+
+// cnameTarget returns the end of the CNAME chain that starts at name within the rrset, or an empty string if
+// there is no CNAME for name.
+func cnameTarget(name string, rrset []dns.RR) string {
+	target := ""
+	current := dns.Fqdn(name)
+	for range rrset {
+		next := ""
+		for _, cname := range extractRecordsOfType[*dns.CNAME](rrset) {
+			if strings.EqualFold(cname.Hdr.Name, current) {
+				next = cname.Target
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		target, current = next, next
+	}
+	return target
+}
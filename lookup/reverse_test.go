@@ -0,0 +1,104 @@
+package lookup
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAnswerMsg returns a successful response with the answer records given in presentation format.
+func newAnswerMsg(t *testing.T, records ...string) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.AuthenticatedData = true
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		require.NoError(t, err)
+		msg.Answer = append(msg.Answer, rr)
+	}
+	return msg
+}
+
+func TestQueryPTRFollowsClasslessDelegation(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "1.0.0.192.in-addr.arpa.", dns.TypePTR).Return(
+		newAnswerMsg(t, "1.0.0.192.in-addr.arpa. 300 IN CNAME 1.0/25.0.0.192.in-addr.arpa."), time.Millisecond, nil)
+	ns.On("Query", "1.0/25.0.0.192.in-addr.arpa.", dns.TypePTR).Return(
+		newAnswerMsg(t, "1.0/25.0.0.192.in-addr.arpa. 300 IN PTR host.example.com."), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	ptrs, err := d.QueryPTR("1.0.0.192.in-addr.arpa.")
+	require.NoError(t, err)
+	require.Len(t, ptrs, 1)
+	assert.Equal(t, "host.example.com.", ptrs[0].Ptr)
+}
+
+func TestQueryPTRUsesRecordsAlongsideCname(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "1.0.0.192.in-addr.arpa.", dns.TypePTR).Return(newAnswerMsg(t,
+		"1.0.0.192.in-addr.arpa. 300 IN CNAME 1.0/25.0.0.192.in-addr.arpa.",
+		"1.0/25.0.0.192.in-addr.arpa. 300 IN PTR host.example.com.",
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	ptrs, err := d.QueryPTR("1.0.0.192.in-addr.arpa.")
+	require.NoError(t, err)
+	require.Len(t, ptrs, 1)
+	ns.AssertNumberOfCalls(t, "Query", 1)
+}
+
+func TestQueryPTRDetectsCnameLoop(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "1.0.0.192.in-addr.arpa.", dns.TypePTR).Return(
+		newAnswerMsg(t, "1.0.0.192.in-addr.arpa. 300 IN CNAME 1.0/25.0.0.192.in-addr.arpa."), time.Millisecond, nil)
+	ns.On("Query", "1.0/25.0.0.192.in-addr.arpa.", dns.TypePTR).Return(
+		newAnswerMsg(t, "1.0/25.0.0.192.in-addr.arpa. 300 IN CNAME 1.0.0.192.in-addr.arpa."), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	_, err := d.QueryPTR("1.0.0.192.in-addr.arpa.")
+	assert.ErrorContains(t, err, "CNAME loop detected")
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{ip: "192.0.2.1", expected: "1.2.0.192.in-addr.arpa."},
+		{ip: "::ffff:192.0.2.1", expected: "1.2.0.192.in-addr.arpa."},
+		{ip: "2001:db8::567:89ab", expected: "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			name, err := reverseName(net.ParseIP(tt.ip))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+
+	_, err := reverseName(nil)
+	assert.ErrorContains(t, err, "invalid ip address")
+	_, err = reverseName(net.IP{1, 2, 3})
+	assert.ErrorContains(t, err, "invalid ip address")
+}
+
+func TestQueryPTRForIP(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "1.2.0.192.in-addr.arpa.", dns.TypePTR).Return(
+		newAnswerMsg(t, "1.2.0.192.in-addr.arpa. 300 IN PTR host.example.com."), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	ptrs, err := d.QueryPTRForIP(net.ParseIP("192.0.2.1"))
+	require.NoError(t, err)
+	require.Len(t, ptrs, 1)
+	assert.Equal(t, "host.example.com.", ptrs[0].Ptr)
+}
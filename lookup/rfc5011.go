@@ -0,0 +1,451 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultAddHoldDown and DefaultRemoveHoldDown are the hold-down times of RFC 5011, section 2.4.1.
+const (
+	DefaultAddHoldDown    = 30 * 24 * time.Hour
+	DefaultRemoveHoldDown = 30 * 24 * time.Hour
+)
+
+// AnchorState is the state of a managed trust anchor, as defined in RFC 5011, section 4.
+type AnchorState uint8
+
+const (
+	AnchorAddPend AnchorState = iota + 1 // The key has been seen, but the add hold-down time hasn't yet passed
+	AnchorValid                          // The key is a trust anchor
+	AnchorMissing                        // The key is a trust anchor, but is no longer in the root DNSKEY set
+	AnchorRevoked                        // The key has been revoked; it's no longer a trust anchor
+	AnchorRemoved                        // The key was revoked over the remove hold-down time ago
+)
+
+func (s AnchorState) String() string {
+	switch s {
+	case AnchorAddPend:
+		return "addpend"
+	case AnchorValid:
+		return "valid"
+	case AnchorMissing:
+		return "missing"
+	case AnchorRevoked:
+		return "revoked"
+	case AnchorRemoved:
+		return "removed"
+	}
+	return fmt.Sprintf("AnchorState(%d)", uint8(s))
+}
+
+// ManagedAnchor is a root Key Signing Key tracked by an AnchorManager.
+type ManagedAnchor struct {
+	Key         *dns.DNSKEY
+	State       AnchorState
+	FirstSeen   time.Time // When the key was first seen in the root DNSKEY set
+	LastSeen    time.Time // When the key was last seen in the root DNSKEY set
+	HoldDownEnd time.Time // When an AddPend key becomes valid, or a revoked key can be removed
+}
+
+// managedAnchorJSON is how a ManagedAnchor is persisted; the key is in zone file format.
+type managedAnchorJSON struct {
+	Key         string    `json:"key"`
+	State       string    `json:"state"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	HoldDownEnd time.Time `json:"hold_down_end"`
+}
+
+func (a ManagedAnchor) MarshalJSON() ([]byte, error) {
+	if a.Key == nil {
+		return nil, fmt.Errorf("managed anchor has no key")
+	}
+	return json.Marshal(managedAnchorJSON{
+		Key:         a.Key.String(),
+		State:       a.State.String(),
+		FirstSeen:   a.FirstSeen,
+		LastSeen:    a.LastSeen,
+		HoldDownEnd: a.HoldDownEnd,
+	})
+}
+
+func (a *ManagedAnchor) UnmarshalJSON(data []byte) error {
+	var v managedAnchorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	rr, err := dns.NewRR(v.Key)
+	if err != nil {
+		return fmt.Errorf("invalid managed anchor key: %w", err)
+	}
+	key, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return fmt.Errorf("managed anchor key %q is not a DNSKEY record", v.Key)
+	}
+
+	state := AnchorState(0)
+	for s := AnchorAddPend; s <= AnchorRemoved; s++ {
+		if s.String() == v.State {
+			state = s
+		}
+	}
+	if state == 0 {
+		return fmt.Errorf("unknown managed anchor state %q", v.State)
+	}
+
+	*a = ManagedAnchor{Key: key, State: state, FirstSeen: v.FirstSeen, LastSeen: v.LastSeen, HoldDownEnd: v.HoldDownEnd}
+	return nil
+}
+
+// id identifies a key regardless of its REVOKE flag, which changes its key tag.
+func (a *ManagedAnchor) id() string {
+	return anchorID(a.Key)
+}
+
+func anchorID(key *dns.DNSKEY) string {
+	return fmt.Sprintf("%d %s", key.Algorithm, key.PublicKey)
+}
+
+//-----
+
+// AnchorStore persists the state of an AnchorManager, so it survives restarts.
+type AnchorStore interface {
+	Load() ([]ManagedAnchor, error) // Returns no anchors, and no error, if nothing has been saved yet
+	Save([]ManagedAnchor) error
+}
+
+// FileAnchorStore is an AnchorStore that keeps the state as JSON in a file.
+type FileAnchorStore struct {
+	Path string
+}
+
+// NewFileAnchorStore creates a FileAnchorStore for the file at path.
+func NewFileAnchorStore(path string) *FileAnchorStore {
+	return &FileAnchorStore{Path: path}
+}
+
+// Load reads the state from the file. A missing file is treated as no state.
+func (s *FileAnchorStore) Load() ([]ManagedAnchor, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var anchors []ManagedAnchor
+	if err = json.Unmarshal(data, &anchors); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", s.Path, err)
+	}
+	return anchors, nil
+}
+
+// Save writes the state to a temporary file, then renames it over the file, so the file is never left incomplete.
+func (s *FileAnchorStore) Save(anchors []ManagedAnchor) error {
+	data, err := json.MarshalIndent(anchors, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+//-----
+
+// AnchorManager keeps the root trust anchors up to date by following the root DNSKEY set, as described in RFC 5011.
+// New Key Signing Keys become trust anchors once they've been seen, signed by an existing trust anchor, for the add
+// hold-down time; revoked keys stop being trust anchors straight away. So a long-running DnsLookup survives a root
+// KSK roll without being redeployed.
+type AnchorManager struct {
+	AddHoldDown    time.Duration // How long a new key must be seen before it becomes a trust anchor
+	RemoveHoldDown time.Duration // How long a revoked key is remembered, so it isn't added again
+
+	lookup  *DnsLookup
+	store   AnchorStore
+	seeds   []*dns.DS // The DS records trusted until the first refresh
+	onError func(error)
+	now     func() time.Time
+
+	mu      sync.RWMutex
+	anchors map[string]*ManagedAnchor
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// ManageTrustAnchors loads the trust anchor state from the store, and uses it in place of RootDNSSECRecords from
+// then on. If the store is empty, RootDNSSECRecords is trusted until the first refresh, which adopts the root keys
+// matching it as valid trust anchors.
+//
+// The root DNSKEY set is refreshed every interval, starting an interval from now, until Stop is called or the
+// DnsLookup is shut down; with an interval of zero, it's only refreshed when Refresh is called. RFC 5011 suggests
+// refreshing at least every 15 days, and no more than hourly. onError, if not nil, is called when a refresh fails.
+func (d *DnsLookup) ManageTrustAnchors(store AnchorStore, interval time.Duration, onError func(error)) (*AnchorManager, error) {
+	saved, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the trust anchor state: %w", err)
+	}
+
+	m := &AnchorManager{
+		AddHoldDown:    DefaultAddHoldDown,
+		RemoveHoldDown: DefaultRemoveHoldDown,
+		lookup:         d,
+		store:          store,
+		seeds:          d.RootDNSSECRecords,
+		onError:        onError,
+		now:            time.Now,
+		anchors:        make(map[string]*ManagedAnchor),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	for _, anchor := range saved {
+		m.anchors[anchor.id()] = &anchor
+	}
+
+	d.anchorManager.Store(m)
+
+	if interval <= 0 {
+		close(m.done)
+		return m, nil
+	}
+
+	go m.run(interval)
+
+	_, _ = d.lifecycle.onClose(func() error {
+		m.Stop()
+		return nil
+	})
+
+	return m, nil
+}
+
+// Stop stops the background refreshes, waiting for any refresh in progress to finish. The managed trust anchors
+// remain in use.
+func (m *AnchorManager) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+}
+
+func (m *AnchorManager) run(interval time.Duration) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := m.Refresh(context.Background()); err != nil && m.onError != nil {
+			m.onError(err)
+		}
+	}
+}
+
+// Anchors returns the state of every managed key.
+func (m *AnchorManager) Anchors() []ManagedAnchor {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := make([]ManagedAnchor, 0, len(m.anchors))
+	for _, anchor := range m.anchors {
+		results = append(results, *anchor)
+	}
+	return results
+}
+
+// DS returns the DS records of the current trust anchors: the valid and missing keys.
+func (m *AnchorManager) DS() []*dns.DS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.anchors) == 0 {
+		return m.seeds
+	}
+
+	results := make([]*dns.DS, 0)
+	for _, anchor := range m.anchors {
+		if anchor.State == AnchorValid || anchor.State == AnchorMissing {
+			results = append(results, anchor.Key.ToDS(dns.SHA256))
+		}
+	}
+	return results
+}
+
+// Refresh fetches the root DNSKEY set and, if it's signed by a current trust anchor, updates the state of each key
+// in it, then saves the state to the store.
+func (m *AnchorManager) Refresh(ctx context.Context) error {
+	msg, _, err := m.lookup.query(".", dns.TypeDNSKEY, context.WithValue(ctx, contextNoCache, true))
+	if err != nil {
+		return fmt.Errorf("unable to fetch the root DNSKEY set: %w", err)
+	}
+
+	keys := extractRecordsOfType[*dns.DNSKEY](msg.Answer)
+	signatures := extractRecordsOfType[*dns.RRSIG](msg.Answer)
+	now := m.now()
+
+	m.mu.Lock()
+	changed, err := m.update(keys, signatures, now)
+	anchors := make([]ManagedAnchor, 0, len(m.anchors))
+	for _, anchor := range m.anchors {
+		anchors = append(anchors, *anchor)
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	logger := m.lookup.componentLogger(LogComponentValidation)
+	for _, anchor := range changed {
+		logger.Info().Uint16("key-tag", anchor.Key.KeyTag()).Str("state", anchor.State.String()).
+			Msg("Root trust anchor state changed")
+	}
+
+	if err = m.store.Save(anchors); err != nil {
+		return fmt.Errorf("unable to save the trust anchor state: %w", err)
+	}
+	return nil
+}
+
+// update applies the timers and transitions of RFC 5011, section 4.4, to the keys in an authenticated root DNSKEY
+// set. The anchors whose state changed are returned. It must be called with m.mu held.
+func (m *AnchorManager) update(keys []*dns.DNSKEY, signatures []*dns.RRSIG, now time.Time) ([]ManagedAnchor, error) {
+	rrset := make([]dns.RR, len(keys))
+	for i, key := range keys {
+		rrset[i] = key
+	}
+
+	signedBy := func(key *dns.DNSKEY) bool {
+		for _, sig := range signatures {
+			if sig.TypeCovered == dns.TypeDNSKEY && sig.KeyTag == key.KeyTag() && sig.Algorithm == key.Algorithm &&
+				sig.ValidityPeriod(now) && sig.Verify(key, rrset) == nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Until there's state, the seed DS records identify the trusted keys.
+	bootstrapping := len(m.anchors) == 0
+	trusted := func(key *dns.DNSKEY) bool {
+		if bootstrapping {
+			for _, ds := range m.seeds {
+				if keyDS := key.ToDS(ds.DigestType); keyDS != nil && dns.IsDuplicate(keyDS, ds) {
+					return true
+				}
+			}
+			return false
+		}
+		anchor, ok := m.anchors[anchorID(key)]
+		return ok && (anchor.State == AnchorValid || anchor.State == AnchorMissing)
+	}
+
+	authenticated := false
+	for _, key := range keys {
+		if key.Flags&dns.SEP != 0 && key.Flags&dns.REVOKE == 0 && trusted(key) && signedBy(key) {
+			authenticated = true
+			break
+		}
+	}
+	if !authenticated {
+		return nil, fmt.Errorf("the root DNSKEY set is not signed by a current trust anchor")
+	}
+
+	changed := make([]ManagedAnchor, 0)
+	transition := func(anchor *ManagedAnchor, state AnchorState, holdDownEnd time.Time) {
+		anchor.State, anchor.HoldDownEnd = state, holdDownEnd
+		changed = append(changed, *anchor)
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if key.Flags&dns.SEP == 0 {
+			continue
+		}
+		id := anchorID(key)
+		seen[id] = true
+		anchor, known := m.anchors[id]
+
+		if key.Flags&dns.REVOKE != 0 {
+			// A revocation only counts if the revoked key itself signed the set (RFC 5011, section 2.1).
+			if known && anchor.State != AnchorRevoked && anchor.State != AnchorRemoved && signedBy(key) {
+				anchor.Key = key
+				transition(anchor, AnchorRevoked, now.Add(m.RemoveHoldDown))
+			}
+			if known {
+				anchor.LastSeen = now
+			}
+			continue
+		}
+
+		switch {
+		case !known && bootstrapping && trusted(key):
+			anchor = &ManagedAnchor{Key: key, FirstSeen: now}
+			m.anchors[id] = anchor
+			transition(anchor, AnchorValid, time.Time{})
+		case !known:
+			anchor = &ManagedAnchor{Key: key, FirstSeen: now}
+			m.anchors[id] = anchor
+			transition(anchor, AnchorAddPend, now.Add(m.AddHoldDown))
+		case anchor.State == AnchorAddPend && !now.Before(anchor.HoldDownEnd):
+			transition(anchor, AnchorValid, time.Time{})
+		case anchor.State == AnchorMissing:
+			transition(anchor, AnchorValid, time.Time{})
+		}
+		anchor.LastSeen = now
+	}
+
+	for id, anchor := range m.anchors {
+		switch {
+		case anchor.State == AnchorRevoked && !now.Before(anchor.HoldDownEnd):
+			transition(anchor, AnchorRemoved, time.Time{})
+		case seen[id]:
+		case anchor.State == AnchorAddPend:
+			// A pending key that disappears must start its hold-down again if it returns.
+			delete(m.anchors, id)
+		case anchor.State == AnchorValid:
+			transition(anchor, AnchorMissing, time.Time{})
+		}
+	}
+
+	return changed, nil
+}
+
+// rootDNSSECRecords returns the root trust anchors: those of the AnchorManager, if there is one, otherwise
+// RootDNSSECRecords.
+func (d *DnsLookup) rootDNSSECRecords() []*dns.DS {
+	if m := d.anchorManager.Load(); m != nil {
+		return m.DS()
+	}
+	return d.RootDNSSECRecords
+}
@@ -0,0 +1,144 @@
+package lookup
+
+import (
+	"context"
+	"crypto"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publishRootKeys replaces the root DNSKEY set with the zone's ZSK and the given KSKs, signed by each KSK.
+func publishRootKeys(t *testing.T, root *dnssectest.Zone, ksks []*dns.DNSKEY, signers []crypto.Signer) {
+	records := []dns.RR{root.ZSK}
+	for _, ksk := range ksks {
+		records = append(records, ksk)
+	}
+	signed := append([]dns.RR{}, records...)
+	for i, ksk := range ksks {
+		rrsig, err := dnssectest.Sign(records, ksk, signers[i], root.Inception, root.Expiration)
+		require.NoError(t, err)
+		signed = append(signed, rrsig)
+	}
+	root.Set(".", dns.TypeDNSKEY, signed...)
+}
+
+// anchorState returns the state of the managed anchor for a key, or zero if it isn't managed.
+func anchorState(m *AnchorManager, key *dns.DNSKEY) AnchorState {
+	for _, anchor := range m.Anchors() {
+		if anchor.id() == anchorID(key) {
+			return anchor.State
+		}
+	}
+	return 0
+}
+
+func TestAnchorManager_Rollover(t *testing.T) {
+	zones := newTestChain(t)
+	root := zones[0]
+
+	// Signatures must remain valid across the hold-down times.
+	start := time.Now()
+	root.Expiration = start.Add(90 * 24 * time.Hour)
+	require.NoError(t, root.Resign())
+
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = root.TrustAnchors()
+
+	store := NewFileAnchorStore(filepath.Join(t.TempDir(), "anchors.json"))
+	m, err := d.ManageTrustAnchors(store, 0, nil)
+	require.NoError(t, err)
+
+	now := start
+	m.now = func() time.Time { return now }
+
+	// The first refresh adopts the key matching the configured DS records.
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, AnchorValid, anchorState(m, root.KSK))
+	assert.Len(t, m.DS(), 1)
+
+	// A new KSK is published, signed by both.
+	newKSK, newSigner, err := dnssectest.GenerateKey(".", dnssectest.FlagKSK, dns.ECDSAP256SHA256, 0)
+	require.NoError(t, err)
+	publishRootKeys(t, root, []*dns.DNSKEY{root.KSK, newKSK}, []crypto.Signer{root.KSKSigner, newSigner})
+
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, AnchorAddPend, anchorState(m, newKSK))
+	assert.Len(t, m.DS(), 1)
+
+	now = now.Add(DefaultAddHoldDown / 2)
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, AnchorAddPend, anchorState(m, newKSK))
+
+	now = now.Add(DefaultAddHoldDown / 2)
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, AnchorValid, anchorState(m, newKSK))
+	assert.Len(t, m.DS(), 2)
+
+	// Queries are validated against the managed anchors.
+	records, err := d.QueryA("test.example.com.")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	// The old KSK is revoked; it must sign the set itself for the revocation to count.
+	revoked := dns.Copy(root.KSK).(*dns.DNSKEY)
+	revoked.Flags |= dns.REVOKE
+	publishRootKeys(t, root, []*dns.DNSKEY{revoked, newKSK}, []crypto.Signer{root.KSKSigner, newSigner})
+
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, AnchorRevoked, anchorState(m, root.KSK))
+	assert.Equal(t, []*dns.DS{newKSK.ToDS(dns.SHA256)}, m.DS())
+
+	// The state survives a restart.
+	restarted := NewDnsLookup(d.nameservers)
+	m2, err := restarted.ManageTrustAnchors(store, 0, nil)
+	require.NoError(t, err)
+	assert.Len(t, m2.Anchors(), 2)
+	assert.Equal(t, AnchorRevoked, anchorState(m2, root.KSK))
+	assert.Equal(t, AnchorValid, anchorState(m2, newKSK))
+	assert.Equal(t, m.DS(), restarted.rootDNSSECRecords())
+
+	now = now.Add(DefaultRemoveHoldDown)
+	publishRootKeys(t, root, []*dns.DNSKEY{newKSK}, []crypto.Signer{newSigner})
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, AnchorRemoved, anchorState(m, root.KSK))
+}
+
+func TestAnchorManager_RejectsUntrustedKeys(t *testing.T) {
+	zones := newTestChain(t)
+	root := zones[0]
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = root.TrustAnchors()
+
+	m, err := d.ManageTrustAnchors(NewFileAnchorStore(filepath.Join(t.TempDir(), "anchors.json")), 0, nil)
+	require.NoError(t, err)
+	require.NoError(t, m.Refresh(context.Background()))
+
+	// A set signed only by an unknown key changes nothing.
+	otherKSK, otherSigner, err := dnssectest.GenerateKey(".", dnssectest.FlagKSK, dns.ECDSAP256SHA256, 0)
+	require.NoError(t, err)
+	publishRootKeys(t, root, []*dns.DNSKEY{otherKSK}, []crypto.Signer{otherSigner})
+
+	assert.ErrorContains(t, m.Refresh(context.Background()), "not signed by a current trust anchor")
+	assert.Equal(t, AnchorValid, anchorState(m, root.KSK))
+	assert.Equal(t, AnchorState(0), anchorState(m, otherKSK))
+
+	// A revocation not signed by the revoked key is ignored.
+	revoked := dns.Copy(root.KSK).(*dns.DNSKEY)
+	revoked.Flags |= dns.REVOKE
+	publishRootKeys(t, root, []*dns.DNSKEY{root.KSK, revoked}, []crypto.Signer{root.KSKSigner, otherSigner})
+
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, AnchorValid, anchorState(m, root.KSK))
+}
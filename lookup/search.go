@@ -0,0 +1,74 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultNdots is the number of dots a name needs before it's tried as given ahead of the search domains, as in
+// resolv.conf.
+const DefaultNdots = 1
+
+// searchCandidates returns the names to query, in order, for a name that may be relative. A name ending in a dot, or
+// any name when no search domains are set, is only queried as given. Otherwise, names with at least Ndots dots are
+// tried as given first, then with each search domain appended; names with fewer are tried with the search domains first.
+func (d *DnsLookup) searchCandidates(name string) []string {
+	if len(d.SearchDomains) == 0 || strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+
+	candidates := make([]string, 0, len(d.SearchDomains)+1)
+	for _, domain := range d.SearchDomains {
+		domain = strings.Trim(domain, ".")
+		if domain != "" {
+			candidates = append(candidates, dns.Fqdn(name+"."+domain))
+		}
+	}
+
+	if strings.Count(name, ".") >= d.Ndots {
+		return append([]string{dns.Fqdn(name)}, candidates...)
+	}
+	return append(candidates, dns.Fqdn(name))
+}
+
+// querySearch queries each candidate name in turn, returning the first answer with records in it. Only a candidate that
+// doesn't exist, or has no records of the type, moves the search on to the next; any other error is returned straight
+// away, so a failing nameserver isn't mistaken for a name that's missing. If no candidate has records, a response with
+// no records is returned if there was one; otherwise the last candidate's error is.
+func (d *DnsLookup) querySearch(ctx context.Context, candidates []string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	var nodata *dns.Msg
+	var total time.Duration
+	var err error
+
+	for _, candidate := range candidates {
+		var msg *dns.Msg
+		var latency time.Duration
+		msg, latency, err = d.queryContext(ctx, candidate, rrtype)
+		total += latency
+
+		if ctx.Err() != nil {
+			if err == nil {
+				err = ctx.Err()
+			}
+			return nil, total, err
+		}
+		if err != nil && !errors.Is(err, ErrNXDomain) && !errors.Is(err, ErrNoData) {
+			return nil, total, err
+		}
+		if err == nil && len(msg.Answer) > 0 {
+			return msg, total, nil
+		}
+		if err == nil && nodata == nil {
+			nodata = msg
+		}
+	}
+
+	if nodata != nil {
+		return nodata, total, nil
+	}
+	return nil, total, err
+}
@@ -0,0 +1,73 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchCandidates(t *testing.T) {
+	d := &DnsLookup{SearchDomains: []string{"corp.example.com", ".example.com."}, Ndots: 1}
+
+	assert.Equal(t, []string{"host.corp.example.com.", "host.example.com.", "host."}, d.searchCandidates("host"))
+	assert.Equal(t, []string{"www.host.", "www.host.corp.example.com.", "www.host.example.com."}, d.searchCandidates("www.host"))
+	assert.Equal(t, []string{"host."}, d.searchCandidates("host."))
+
+	d.Ndots = 2
+	assert.Equal(t, []string{"www.host.corp.example.com.", "www.host.example.com.", "www.host."}, d.searchCandidates("www.host"))
+
+	d.SearchDomains = nil
+	assert.Equal(t, []string{"host"}, d.searchCandidates("host"))
+}
+
+func TestDnsLookup_QuerySearch(t *testing.T) {
+	nxdomain := newLookupResponseMsgWithAD(dns.RcodeNameError, true)
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "host.corp.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("host.corp.example.com.", dns.TypeA, "mock", dns.RcodeNameError))
+	ns.On("Query", "host.example.com.", dns.TypeA).Return(
+		newAnswerMsg(t, "host.example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+	ns.On("Query", "printer.corp.example.com.", dns.TypeA).Return(newAnswerMsg(t), time.Millisecond, nil)
+	ns.On("Query", "printer.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("printer.example.com.", dns.TypeA, "mock", dns.RcodeNameError))
+	ns.On("Query", "printer.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("printer.", dns.TypeA, "mock", dns.RcodeNameError))
+	ns.On("Query", "missing.corp.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("missing.corp.example.com.", dns.TypeA, "mock", dns.RcodeNameError))
+	ns.On("Query", "missing.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("missing.example.com.", dns.TypeA, "mock", dns.RcodeNameError))
+	ns.On("Query", "missing.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("missing.", dns.TypeA, "mock", dns.RcodeNameError))
+
+	d := &DnsLookup{nameservers: []NameServer{ns}, SearchDomains: []string{"corp.example.com", "example.com"}, Ndots: 1}
+
+	// The first candidate with an answer is used.
+	answers, err := d.QueryA("host")
+	require.NoError(t, err)
+	require.Len(t, answers, 1)
+	assert.Equal(t, "host.example.com.", answers[0].Hdr.Name)
+	ns.AssertNotCalled(t, "Query", "host.", dns.TypeA)
+
+	// Without one, an empty answer is preferred to an error.
+	answers, err = d.QueryA("printer")
+	require.NoError(t, err)
+	assert.Empty(t, answers)
+
+	_, err = d.QueryA("missing")
+	assert.ErrorContains(t, err, "A query to mock returned NXDOMAIN")
+}
+
+func TestDnsLookup_QuerySearchStopsOnFailure(t *testing.T) {
+	nxdomain := newLookupResponseMsgWithAD(dns.RcodeNameError, true)
+	servfail := newLookupResponseMsgWithAD(dns.RcodeServerFailure, true)
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "host.corp.example.com.", dns.TypeA).Return(nxdomain, time.Millisecond, newRcodeError("host.corp.example.com.", dns.TypeA, "mock", dns.RcodeNameError))
+	ns.On("Query", "host.example.com.", dns.TypeA).Return(servfail, time.Millisecond, newRcodeError("host.example.com.", dns.TypeA, "mock", dns.RcodeServerFailure))
+
+	d := &DnsLookup{nameservers: []NameServer{ns}, SearchDomains: []string{"corp.example.com", "example.com"}, Ndots: 1}
+
+	// A SERVFAIL isn't taken to mean the name doesn't exist, so the remaining candidates aren't tried.
+	_, err := d.QueryA("host")
+	assert.ErrorIs(t, err, ErrServFail)
+	assert.NotErrorIs(t, err, ErrNXDomain)
+	ns.AssertNotCalled(t, "Query", "host.", dns.TypeA)
+}
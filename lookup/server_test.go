@@ -0,0 +1,94 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestChain generates a signed chain of zones, from a test root down to example.com., with an A record
+// for test.example.com. The zones are returned root first.
+func newTestChain(t *testing.T) []*dnssectest.Zone {
+	root, err := dnssectest.NewZone(".", nil, dns.RSASHA256, dns.ED25519)
+	require.NoError(t, err)
+	com, err := dnssectest.NewZone("com.", root, dns.ECDSAP384SHA384, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+	example, err := dnssectest.NewZone("example.com.", com, dns.ECDSAP256SHA256, dns.ED25519)
+	require.NoError(t, err)
+
+	require.NoError(t, example.AddString("test.example.com. 300 IN A 192.0.2.1"))
+
+	return []*dnssectest.Zone{root, com, example}
+}
+
+// newTestServer starts a dnssectest.Server for the zones, shutting it down when the test completes.
+func newTestServer(t *testing.T, zones []*dnssectest.Zone) *dnssectest.Server {
+	server, err := dnssectest.NewServer(zones...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return server
+}
+
+func TestTestServer_AuthenticateValid(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	for _, ns := range []NameServer{NewUdpNameserver(server.Address, server.Port), NewTcpNameserver(server.Address, server.Port)} {
+		d := NewDnsLookup([]NameServer{ns})
+		d.RemotelyAuthenticateData = false
+		d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+		answers, err := d.QueryA("test.example.com")
+		assert.NoError(t, err, ns.String())
+		assert.Len(t, answers, 1, ns.String())
+	}
+}
+
+func TestTestServer_AuthenticateExpired(t *testing.T) {
+	zones := newTestChain(t)
+	zones[1].Inception = time.Now().Add(-2 * time.Hour)
+	zones[1].Expiration = time.Now().Add(-time.Hour)
+	require.NoError(t, zones[1].Resign())
+
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	_, err := d.QueryA("test.example.com")
+	assert.ErrorContains(t, err, "signature outside of the allowed inception or expiration range")
+}
+
+func TestTestServer_AuthenticateWrongAnchor(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	other, err := dnssectest.NewZone(".", nil, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	require.NoError(t, err)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = other.TrustAnchors()
+
+	_, err = d.QueryA("test.example.com")
+	assert.ErrorIs(t, err, ErrDNSSECBogus)
+	assert.ErrorContains(t, err, ": unable to find a matching DS digest at the root")
+}
+
+func TestTestServer_NameError(t *testing.T) {
+	server := newTestServer(t, newTestChain(t))
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+
+	_, err := d.QueryA("missing.example.com")
+	assert.ErrorContains(t, err, "no answer found on any configured nameserver")
+	assert.ErrorContains(t, err, "missing.example.com. A query to udp://"+server.Address+":"+server.Port+" returned NXDOMAIN")
+}
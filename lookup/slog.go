@@ -0,0 +1,105 @@
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// SetSlogLogger sets a standard library *slog.Logger to log to, in place of a zerolog logger. Each event is passed to
+// the logger's handler with its level and message, and its fields as attributes. Component levels and samplers set
+// with SetLogLevel and SetLogSampler still apply, before the handler's own level.
+func (d *DnsLookup) SetSlogLogger(l *slog.Logger) {
+	handler := l.Handler()
+	d.SetLogger(zerolog.New(&slogWriter{handler: handler}).Level(slogMinLevel(handler)))
+}
+
+// slogMinLevel returns the lowest zerolog level the handler is enabled for, so events it would discard aren't built.
+func slogMinLevel(handler slog.Handler) zerolog.Level {
+	for _, level := range []zerolog.Level{zerolog.TraceLevel, zerolog.DebugLevel, zerolog.InfoLevel, zerolog.WarnLevel} {
+		if handler.Enabled(context.Background(), slogLevel(level)) {
+			return level
+		}
+	}
+	return zerolog.ErrorLevel
+}
+
+// slogLevel maps a zerolog level to the closest slog level.
+func slogLevel(level zerolog.Level) slog.Level {
+	switch level {
+	case zerolog.TraceLevel:
+		return slog.LevelDebug - 4
+	case zerolog.DebugLevel:
+		return slog.LevelDebug
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		return slog.LevelInfo
+	case zerolog.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// slogWriter receives the JSON events written by a zerolog logger, and passes them on to a slog handler.
+type slogWriter struct {
+	handler slog.Handler
+}
+
+func (w *slogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel decodes a zerolog event, and passes it to the handler as a record.
+func (w *slogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	ctx := context.Background()
+	if !w.handler.Enabled(ctx, slogLevel(level)) {
+		return len(p), nil
+	}
+
+	record := slog.NewRecord(time.Now(), slogLevel(level), "", 0)
+
+	// The fields are read in turn, rather than into a map, so the attributes keep the order they were logged in.
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if _, err := decoder.Token(); err != nil {
+		return 0, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, err
+		}
+		key, _ := token.(string)
+
+		var value any
+		if err = decoder.Decode(&value); err != nil {
+			return 0, err
+		}
+
+		switch key {
+		case zerolog.MessageFieldName:
+			record.Message, _ = value.(string)
+		case zerolog.LevelFieldName:
+		default:
+			record.AddAttrs(slog.Any(key, slogValue(value)))
+		}
+	}
+	return len(p), w.handler.Handle(ctx, record)
+}
+
+// slogValue converts JSON numbers to int64 or float64, as appropriate, so handlers format them as numbers.
+func slogValue(value any) any {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	f, _ := number.Float64()
+	return f
+}
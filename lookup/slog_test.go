@@ -0,0 +1,45 @@
+package lookup
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDnsLookup_SetSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeA).Return(newAnswerMsg(t, "example.com. 300 IN A 192.0.2.1"), time.Millisecond, nil)
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.SetSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	_, err := d.QueryA("example.com.")
+	require.NoError(t, err)
+
+	// Debug events are below the handler's level, so aren't passed on.
+	assert.NotContains(t, buf.String(), `"level":"DEBUG"`)
+	assert.Contains(t, buf.String(), `"level":"INFO","msg":"Performing DNS query","component":"query","domain":"example.com.","type":"A"}`)
+}
+
+func TestSlogWriter_Levels(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	assert.Equal(t, zerolog.DebugLevel, slogMinLevel(handler))
+
+	logger := zerolog.New(&slogWriter{handler: handler}).Level(slogMinLevel(handler))
+	logger.Warn().Int("attempt", 2).Dur("latency", time.Second).Msg("Retrying")
+	logger.Trace().Msg("Not logged")
+
+	assert.Contains(t, buf.String(), "level=WARN msg=Retrying")
+	assert.Contains(t, buf.String(), "attempt=2")
+	assert.Contains(t, buf.String(), "latency=1000")
+	assert.NotContains(t, buf.String(), "Not logged")
+}
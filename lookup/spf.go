@@ -0,0 +1,192 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// spfLookupLimit is the number of mechanisms and modifiers that cause DNS lookups an SPF evaluation may use, as
+// defined in RFC 7208, section 4.6.4.
+const spfLookupLimit = 10
+
+// Errors returned by QuerySPF.
+var (
+	ErrNoSPFRecord        = errors.New("no spf record found")
+	ErrSPFLookupLimit     = fmt.Errorf("spf evaluation exceeds the limit of %d dns lookups", spfLookupLimit)
+	ErrMultipleSPFRecords = errors.New("more than one spf record found")
+)
+
+// SPFMechanism is a single mechanism of an SPF policy, e.g. "-ip4:192.0.2.0/24".
+type SPFMechanism struct {
+	Qualifier byte   // One of '+', '-', '~' or '?'; '+' when the record didn't give one
+	Name      string // The mechanism, e.g. "ip4", "include" or "all", in lowercase
+	Value     string // Everything after the name, e.g. "192.0.2.0/24", or "/24" for "a/24"; macros aren't expanded
+	Domain    string // The domain whose record the mechanism came from
+}
+
+func (m SPFMechanism) String() string {
+	switch {
+	case m.Value == "":
+		return string(m.Qualifier) + m.Name
+	case strings.HasPrefix(m.Value, "/"):
+		return string(m.Qualifier) + m.Name + m.Value
+	default:
+		return string(m.Qualifier) + m.Name + ":" + m.Value
+	}
+}
+
+// SPFPolicy is a domain's SPF policy, with the records it includes, or is redirected to, resolved.
+type SPFPolicy struct {
+	Domain string // The domain the policy is for
+	Record string // The domain's own SPF record
+
+	// Mechanisms is the effective mechanism list, in the order they're evaluated. Each include mechanism is followed
+	// by the mechanisms of the record it includes. When a record has a redirect modifier and no "all" mechanism, the
+	// mechanisms of the record it redirects to follow its own.
+	Mechanisms []SPFMechanism
+
+	Lookups int // The number of DNS lookups evaluating the policy takes
+}
+
+// QuerySPF fetches a domain's SPF policy from its TXT records, following its include mechanisms and redirect modifier
+// to build the effective mechanism list. Each record is fetched, and validated, as any other query. An error is
+// returned if the policy needs more than 10 DNS lookups, as RFC 7208 limits it to.
+func (d *DnsLookup) QuerySPF(domain string) (*SPFPolicy, error) {
+	return d.QuerySPFContext(context.Background(), domain)
+}
+
+// QuerySPFContext fetches a domain's SPF policy, stopping when ctx is done.
+func (d *DnsLookup) QuerySPFContext(ctx context.Context, domain string) (*SPFPolicy, error) {
+	domain = dns.Fqdn(domain)
+	record, err := d.spfRecord(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &SPFPolicy{Domain: domain, Record: record}
+	if err = d.resolveSPF(ctx, policy, domain, record); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// resolveSPF appends the mechanisms of a domain's SPF record to the policy, following its includes and redirect.
+func (d *DnsLookup) resolveSPF(ctx context.Context, policy *SPFPolicy, domain, record string) error {
+	mechanisms, redirect := parseSPF(domain, record)
+
+	hasAll := false
+	for _, mechanism := range mechanisms {
+		policy.Mechanisms = append(policy.Mechanisms, mechanism)
+
+		switch mechanism.Name {
+		case "all":
+			hasAll = true
+		case "a", "mx", "ptr", "exists":
+			if err := policy.countLookup(); err != nil {
+				return err
+			}
+		case "include":
+			if err := policy.countLookup(); err != nil {
+				return err
+			}
+			if err := d.followSPF(ctx, policy, mechanism.Value); err != nil {
+				return fmt.Errorf("include:%s: %w", mechanism.Value, err)
+			}
+		}
+	}
+
+	// A redirect is ignored if the record has an "all" mechanism, as it would never be reached.
+	if redirect != "" && !hasAll {
+		if err := policy.countLookup(); err != nil {
+			return err
+		}
+		if err := d.followSPF(ctx, policy, redirect); err != nil {
+			return fmt.Errorf("redirect=%s: %w", redirect, err)
+		}
+	}
+	return nil
+}
+
+// followSPF fetches the SPF record of an included, or redirected to, domain and appends its mechanisms. Domains
+// containing macros depend on the message being checked, so aren't followed.
+func (d *DnsLookup) followSPF(ctx context.Context, policy *SPFPolicy, domain string) error {
+	if strings.Contains(domain, "%") {
+		return nil
+	}
+	domain = dns.Fqdn(domain)
+	record, err := d.spfRecord(ctx, domain)
+	if err != nil {
+		return err
+	}
+	return d.resolveSPF(ctx, policy, domain, record)
+}
+
+// countLookup counts a DNS lookup made evaluating the policy, returning an error once the limit is exceeded.
+func (p *SPFPolicy) countLookup() error {
+	p.Lookups++
+	if p.Lookups > spfLookupLimit {
+		return ErrSPFLookupLimit
+	}
+	return nil
+}
+
+// spfRecord returns a domain's SPF record: the TXT record starting "v=spf1". A TXT record's strings are joined
+// without spaces, as RFC 7208, section 3.3 requires.
+func (d *DnsLookup) spfRecord(ctx context.Context, domain string) (string, error) {
+	records, err := d.QueryTXTContext(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+
+	var spf []string
+	for _, record := range records {
+		txt := strings.Join(record.Txt, "")
+		if strings.EqualFold(txt, "v=spf1") || strings.HasPrefix(strings.ToLower(txt), "v=spf1 ") {
+			spf = append(spf, txt)
+		}
+	}
+
+	switch len(spf) {
+	case 0:
+		return "", fmt.Errorf("%w for %s", ErrNoSPFRecord, domain)
+	case 1:
+		return spf[0], nil
+	default:
+		return "", fmt.Errorf("%w for %s", ErrMultipleSPFRecords, domain)
+	}
+}
+
+// parseSPF splits an SPF record into its mechanisms, and the domain of its redirect modifier, if it has one. Other
+// modifiers, e.g. exp, have no effect on the mechanism list, so are dropped.
+func parseSPF(domain, record string) ([]SPFMechanism, string) {
+	var mechanisms []SPFMechanism
+	var redirect string
+
+	for _, term := range strings.Fields(record)[1:] {
+		// A modifier's name is followed by "=", which can't appear in a mechanism's name.
+		if i := strings.IndexAny(term, "=:/"); i > 0 && term[i] == '=' {
+			if strings.EqualFold(term[:i], "redirect") {
+				redirect = term[i+1:]
+			}
+			continue
+		}
+
+		mechanism := SPFMechanism{Qualifier: '+', Domain: domain}
+		if strings.ContainsRune("+-~?", rune(term[0])) {
+			mechanism.Qualifier, term = term[0], term[1:]
+		}
+
+		name, value := term, ""
+		if i := strings.IndexAny(term, ":/"); i >= 0 {
+			name, value = term[:i], term[i:]
+			value = strings.TrimPrefix(value, ":")
+		}
+		mechanism.Name, mechanism.Value = strings.ToLower(name), value
+		mechanisms = append(mechanisms, mechanism)
+	}
+	return mechanisms, redirect
+}
@@ -0,0 +1,104 @@
+package lookup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSPFLookup returns a DnsLookup whose nameserver answers TXT queries with the given records, keyed by domain.
+func newSPFLookup(t *testing.T, records map[string][]string) (*DnsLookup, *namedMockNameServer) {
+	ns := &namedMockNameServer{name: "mock"}
+	for domain, txts := range records {
+		var rrs []string
+		for _, txt := range txts {
+			rrs = append(rrs, fmt.Sprintf("%s 300 IN TXT %s", domain, txt))
+		}
+		ns.On("Query", domain, dns.TypeTXT).Return(newAnswerMsg(t, rrs...), time.Millisecond, nil)
+	}
+	return &DnsLookup{nameservers: []NameServer{ns}}, ns
+}
+
+func TestQuerySPF(t *testing.T) {
+	d, _ := newSPFLookup(t, map[string][]string{
+		"example.com.": {
+			`"google-site-verification=abc"`,
+			`"v=spf1 ip4:192.0.2.0/24 a/28 include:_spf.example.net " "~all exp=explain.example.com"`,
+		},
+		"_spf.example.net.": {`"v=spf1 -ip6:2001:db8::/32 mx ?all"`},
+	})
+
+	policy, err := d.QuerySPF("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com.", policy.Domain)
+	assert.Equal(t, "v=spf1 ip4:192.0.2.0/24 a/28 include:_spf.example.net ~all exp=explain.example.com", policy.Record)
+	assert.Equal(t, 3, policy.Lookups)
+
+	var mechanisms []string
+	for _, mechanism := range policy.Mechanisms {
+		mechanisms = append(mechanisms, mechanism.String())
+	}
+	assert.Equal(t, []string{
+		"+ip4:192.0.2.0/24", "+a/28", "+include:_spf.example.net",
+		"-ip6:2001:db8::/32", "+mx", "?all",
+		"~all",
+	}, mechanisms)
+	assert.Equal(t, "_spf.example.net.", policy.Mechanisms[3].Domain)
+	assert.Equal(t, "example.com.", policy.Mechanisms[6].Domain)
+}
+
+func TestQuerySPF_Redirect(t *testing.T) {
+	d, ns := newSPFLookup(t, map[string][]string{
+		"example.com.":      {`"v=spf1 mx redirect=_spf.example.com"`},
+		"_spf.example.com.": {`"v=spf1 ip4:192.0.2.1 -all"`},
+		"example.org.":      {`"v=spf1 -all redirect=_spf.example.com"`},
+	})
+
+	policy, err := d.QuerySPF("example.com")
+	require.NoError(t, err)
+	require.Len(t, policy.Mechanisms, 3)
+	assert.Equal(t, "ip4", policy.Mechanisms[1].Name)
+	assert.Equal(t, "192.0.2.1", policy.Mechanisms[1].Value)
+	assert.Equal(t, 2, policy.Lookups)
+
+	// The redirect is ignored when there's an "all" mechanism.
+	policy, err = d.QuerySPF("example.org")
+	require.NoError(t, err)
+	require.Len(t, policy.Mechanisms, 1)
+	assert.Equal(t, byte('-'), policy.Mechanisms[0].Qualifier)
+	ns.AssertNumberOfCalls(t, "Query", 3)
+}
+
+func TestQuerySPF_LookupLimit(t *testing.T) {
+	// Each record includes the next, so the chain needs more lookups than are allowed.
+	records := make(map[string][]string)
+	for i := 0; i < 12; i++ {
+		records[fmt.Sprintf("spf%d.example.com.", i)] = []string{fmt.Sprintf(`"v=spf1 include:spf%d.example.com -all"`, i+1)}
+	}
+	d, _ := newSPFLookup(t, records)
+
+	_, err := d.QuerySPF("spf0.example.com")
+	assert.ErrorIs(t, err, ErrSPFLookupLimit)
+}
+
+func TestQuerySPF_Errors(t *testing.T) {
+	d, _ := newSPFLookup(t, map[string][]string{
+		"example.com.": {`"v=spf10 -all"`},
+		"example.net.": {`"v=spf1 -all"`, `"v=spf1 +all"`},
+		"example.org.": {`"v=spf1 include:example.com -all"`},
+	})
+
+	_, err := d.QuerySPF("example.com")
+	assert.ErrorIs(t, err, ErrNoSPFRecord)
+
+	_, err = d.QuerySPF("example.net")
+	assert.ErrorIs(t, err, ErrMultipleSPFRecords)
+
+	_, err = d.QuerySPF("example.org")
+	assert.ErrorIs(t, err, ErrNoSPFRecord)
+	assert.ErrorContains(t, err, "include:example.com")
+}
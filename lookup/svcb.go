@@ -0,0 +1,78 @@
+package lookup
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// SvcbALPN returns the ALPN protocol IDs a SVCB or HTTPS record advertises, e.g. "h2" and "h3". For an HTTPS record,
+// pass &record.SVCB.
+func SvcbALPN(rr *dns.SVCB) []string {
+	if alpn, ok := svcbValue[*dns.SVCBAlpn](rr); ok {
+		return alpn.Alpn
+	}
+	return nil
+}
+
+// SvcbPort returns the port a SVCB or HTTPS record advertises, and false if it doesn't give one, in which case the
+// default port for the scheme is used.
+func SvcbPort(rr *dns.SVCB) (uint16, bool) {
+	if port, ok := svcbValue[*dns.SVCBPort](rr); ok {
+		return port.Port, true
+	}
+	return 0, false
+}
+
+// SvcbECH returns the Encrypted Client Hello configuration list a SVCB or HTTPS record advertises, or nil if it
+// doesn't give one.
+func SvcbECH(rr *dns.SVCB) []byte {
+	if ech, ok := svcbValue[*dns.SVCBECHConfig](rr); ok {
+		return ech.ECH
+	}
+	return nil
+}
+
+// svcbValue returns the record's SvcParam of type T.
+func svcbValue[T dns.SVCBKeyValue](rr *dns.SVCB) (T, bool) {
+	var zero T
+	if rr == nil {
+		return zero, false
+	}
+	for _, kv := range rr.Value {
+		if value, ok := kv.(T); ok {
+			return value, true
+		}
+	}
+	return zero, false
+}
+
+//-----
+
+// QuerySVCB performs a DNS query for SVCB records
+func (d *DnsLookup) QuerySVCB(name string) ([]*dns.SVCB, error) {
+	return d.QuerySVCBContext(context.Background(), name)
+}
+
+// QuerySVCBContext performs a DNS query for SVCB records, stopping when ctx is done
+func (d *DnsLookup) QuerySVCBContext(ctx context.Context, name string) ([]*dns.SVCB, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeSVCB)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.SVCB](msg.Answer), nil
+}
+
+// QueryHTTPS performs a DNS query for HTTPS records
+func (d *DnsLookup) QueryHTTPS(name string) ([]*dns.HTTPS, error) {
+	return d.QueryHTTPSContext(context.Background(), name)
+}
+
+// QueryHTTPSContext performs a DNS query for HTTPS records, stopping when ctx is done
+func (d *DnsLookup) QueryHTTPSContext(ctx context.Context, name string) ([]*dns.HTTPS, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeHTTPS)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.HTTPS](msg.Answer), nil
+}
@@ -0,0 +1,51 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHTTPS(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "example.com.", dns.TypeHTTPS).Return(newAnswerMsg(t,
+		`example.com. 300 IN HTTPS 1 . alpn="h3,h2" port=8443 ech="AEX+DQBB"`,
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QueryHTTPS("example.com.")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	assert.Equal(t, uint16(1), records[0].Priority)
+	assert.Equal(t, []string{"h3", "h2"}, SvcbALPN(&records[0].SVCB))
+	port, ok := SvcbPort(&records[0].SVCB)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(8443), port)
+	assert.Equal(t, []byte{0x00, 0x45, 0xfe, 0x0d, 0x00, 0x41}, SvcbECH(&records[0].SVCB))
+}
+
+func TestQuerySVCB(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "_dns.example.com.", dns.TypeSVCB).Return(newAnswerMsg(t,
+		`_dns.example.com. 300 IN SVCB 0 dns.example.com.`,
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QuerySVCB("_dns.example.com.")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	// An alias mode record has no SvcParams.
+	assert.Equal(t, "dns.example.com.", records[0].Target)
+	assert.Nil(t, SvcbALPN(records[0]))
+	_, ok := SvcbPort(records[0])
+	assert.False(t, ok)
+	assert.Nil(t, SvcbECH(records[0]))
+	assert.Nil(t, SvcbALPN(nil))
+}
@@ -0,0 +1,54 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TLSAName returns the owner name of the TLSA records for a service, as defined in RFC 6698, section 3: the port and
+// protocol as underscore-prefixed labels, followed by the host, e.g. _443._tcp.example.com. for HTTPS.
+func TLSAName(host string, port uint16, protocol string) (string, error) {
+	protocol = strings.ToLower(protocol)
+	switch protocol {
+	case "tcp", "udp", "sctp":
+	default:
+		return "", fmt.Errorf("invalid tlsa protocol %q", protocol)
+	}
+	if _, ok := dns.IsDomainName(host); !ok || host == "" {
+		return "", fmt.Errorf("invalid tlsa host %q", host)
+	}
+	return fmt.Sprintf("_%d._%s.%s", port, protocol, dns.Fqdn(host)), nil
+}
+
+//-----
+
+// QueryTLSA performs a DNS query for TLSA records
+func (d *DnsLookup) QueryTLSA(name string) ([]*dns.TLSA, error) {
+	return d.QueryTLSAContext(context.Background(), name)
+}
+
+// QueryTLSAContext performs a DNS query for TLSA records, stopping when ctx is done
+func (d *DnsLookup) QueryTLSAContext(ctx context.Context, name string) ([]*dns.TLSA, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeTLSA)
+	if err != nil {
+		return nil, err
+	}
+	return extractRecordsOfType[*dns.TLSA](msg.Answer), nil
+}
+
+// QueryTLSAForService performs a DNS query for the TLSA records of a service, e.g. ("example.com", 443, "tcp")
+func (d *DnsLookup) QueryTLSAForService(host string, port uint16, protocol string) ([]*dns.TLSA, error) {
+	return d.QueryTLSAForServiceContext(context.Background(), host, port, protocol)
+}
+
+// QueryTLSAForServiceContext performs a DNS query for the TLSA records of a service, stopping when ctx is done
+func (d *DnsLookup) QueryTLSAForServiceContext(ctx context.Context, host string, port uint16, protocol string) ([]*dns.TLSA, error) {
+	name, err := TLSAName(host, port, protocol)
+	if err != nil {
+		return nil, err
+	}
+	return d.QueryTLSAContext(ctx, name)
+}
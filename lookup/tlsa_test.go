@@ -0,0 +1,43 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSAName(t *testing.T) {
+	name, err := TLSAName("www.example.com", 443, "tcp")
+	require.NoError(t, err)
+	assert.Equal(t, "_443._tcp.www.example.com.", name)
+
+	name, err = TLSAName("mail.example.com.", 25, "TCP")
+	require.NoError(t, err)
+	assert.Equal(t, "_25._tcp.mail.example.com.", name)
+
+	_, err = TLSAName("example.com", 443, "icmp")
+	assert.ErrorContains(t, err, "invalid tlsa protocol")
+
+	_, err = TLSAName("", 443, "tcp")
+	assert.ErrorContains(t, err, "invalid tlsa host")
+}
+
+func TestQueryTLSAForService(t *testing.T) {
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", "_443._tcp.example.com.", dns.TypeTLSA).Return(newAnswerMsg(t,
+		"_443._tcp.example.com. 300 IN TLSA 3 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6",
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QueryTLSAForService("example.com", 443, "tcp")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, uint8(3), records[0].Usage)
+	assert.Equal(t, uint8(1), records[0].Selector)
+	assert.Equal(t, uint8(1), records[0].MatchingType)
+	assert.Equal(t, "0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6", records[0].Certificate)
+}
@@ -1,16 +1,39 @@
 package lookup
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/miekg/dns"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Trace struct {
-	Records []traceRecord
+	Records []traceRecord `json:"records"`
+	mu      sync.Mutex
+}
+
+// MarshalJSON encodes the trace's records, each with a "type" field of "lookup", "signature-validation" or
+// "delegation-check" saying which it is.
+func (t *Trace) MarshalJSON() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Marshal(struct {
+		Records []traceRecord `json:"records"`
+	}{t.Records})
+}
+
+// ContextWithTrace returns a copy of ctx that has queries made with it, by QueryContext or any of the helpers that
+// take a context, record their trace to the given Trace, whether or not EnableTrace is set. Unlike DnsLookup.Trace,
+// it isn't replaced by other queries, so is safe to use when queries are made concurrently.
+func ContextWithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, contextQueryTrace, trace)
 }
 
 func (t *Trace) Add(r traceRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if t.Records == nil {
 		t.Records = make([]traceRecord, 0)
 	}
@@ -20,37 +43,76 @@ func (t *Trace) Add(r traceRecord) {
 type traceRecord interface{}
 
 type TraceLookup struct {
-	Domain     string
-	Rrtype     string
-	Nameserver string
-	Latency    time.Duration
-	Answers    []string
+	Domain         string        `json:"domain"`
+	Rrtype         string        `json:"rrtype"`
+	Nameserver     string        `json:"nameserver"`
+	Latency        time.Duration `json:"latency"` // In nanoseconds, when encoded as JSON
+	Answers        []string      `json:"answers"`
+	ExtendedErrors []string      `json:"extended_errors,omitempty"`
+	Err            error         `json:"-"`
 }
 
-func newtTraceLookup(domain string, rrtype uint16, nameserver string, latency time.Duration, answers []dns.RR) TraceLookup {
+// MarshalJSON encodes the lookup, with its error as a string.
+func (r TraceLookup) MarshalJSON() ([]byte, error) {
+	type fields TraceLookup
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		fields
+		Err string `json:"error,omitempty"`
+	}{"lookup", fields(r), errorString(r.Err)})
+}
+
+func newtTraceLookup(domain string, rrtype uint16, nameserver string, latency time.Duration, result *dns.Msg) TraceLookup {
 	return TraceLookup{
+		Domain:         domain,
+		Rrtype:         rrtypeToString(rrtype),
+		Nameserver:     nameserver,
+		Latency:        latency,
+		Answers:        rrsetToStrings(result.Answer),
+		ExtendedErrors: extendedErrorStrings(extendedErrors(result)),
+	}
+}
+
+// newTraceFailedLookup records a lookup that failed. The result is nil if the nameserver didn't respond.
+func newTraceFailedLookup(domain string, rrtype uint16, nameserver string, latency time.Duration, result *dns.Msg, err error) TraceLookup {
+	record := TraceLookup{
 		Domain:     domain,
 		Rrtype:     rrtypeToString(rrtype),
 		Nameserver: nameserver,
 		Latency:    latency,
-		Answers:    rrsetToStrings(answers),
+		Err:        err,
+	}
+	if result != nil {
+		record.Answers = rrsetToStrings(result.Answer)
+		record.ExtendedErrors = extendedErrorStrings(extendedErrors(result))
 	}
+	return record
 }
 
 //---
 
 type TraceSignatureValidation struct {
-	Depth     uint8
-	KeyType   string
-	Domain    string
-	Zone      string
-	Key       string
-	KeySha256 string
-	Algorithm string
-	Signature string
-	Records   []string
-	Err       error
-	Valid     bool
+	Depth     uint8    `json:"depth"`
+	KeyType   string   `json:"key_type"`
+	Domain    string   `json:"domain"`
+	Zone      string   `json:"zone"`
+	Key       string   `json:"key"`
+	KeySha256 string   `json:"key_sha256"`
+	Algorithm string   `json:"algorithm"`
+	Signature string   `json:"signature"`
+	Records   []string `json:"records"`
+	Err       error    `json:"-"`
+	Valid     bool     `json:"valid"`
+}
+
+// MarshalJSON encodes the signature validation, with its error as a string.
+func (r TraceSignatureValidation) MarshalJSON() ([]byte, error) {
+	type fields TraceSignatureValidation
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		fields
+		Err string `json:"error,omitempty"`
+	}{"signature-validation", fields(r), errorString(r.Err)})
 }
 
 func newTraceSignatureValidation(depth uint8, domain, zone, keyType string, key *dns.DNSKEY, signature *dns.RRSIG, records []dns.RR, err error) TraceSignatureValidation {
@@ -72,10 +134,19 @@ func newTraceSignatureValidation(depth uint8, domain, zone, keyType string, key
 //---
 
 type TraceDelegationSignerCheck struct {
-	Depth  uint8
-	Child  string
-	Parent string
-	Hash   string
+	Depth  uint8  `json:"depth"`
+	Child  string `json:"child"`
+	Parent string `json:"parent"`
+	Hash   string `json:"hash"`
+}
+
+// MarshalJSON encodes the delegation check.
+func (r TraceDelegationSignerCheck) MarshalJSON() ([]byte, error) {
+	type fields TraceDelegationSignerCheck
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		fields
+	}{"delegation-check", fields(r)})
 }
 
 func newTraceDelegationSignerCheck(depth uint8, child, parent, hash string) TraceDelegationSignerCheck {
@@ -86,3 +157,27 @@ func newTraceDelegationSignerCheck(depth uint8, child, parent, hash string) Trac
 		Hash:   strings.ToLower(hash),
 	}
 }
+
+//---
+
+// OnLookup adds the lookup to the trace. Trace is the Observer used by EnableTrace, collecting a query's events to be
+// read once it completes.
+func (t *Trace) OnLookup(r TraceLookup) {
+	t.Add(r)
+}
+
+func (t *Trace) OnSignatureValidation(r TraceSignatureValidation) {
+	t.Add(r)
+}
+
+func (t *Trace) OnDelegationCheck(r TraceDelegationSignerCheck) {
+	t.Add(r)
+}
+
+// errorString returns the error's message, or an empty string if it's nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,101 @@
+package lookup
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the trace's chain of trust as a Graphviz DOT graph, e.g. for rendering with `dot -Tsvg`. Zones and
+// the RRsets signed by them are nodes; DS records link parents to children, and RRSIGs link zones to the RRsets they
+// sign. Signatures that failed to verify are drawn in red, as are the RRsets they cover.
+func (t *Trace) WriteDOT(w io.Writer) error {
+	t.mu.Lock()
+	records := append([]traceRecord(nil), t.Records...)
+	t.mu.Unlock()
+
+	g := &dotGraph{nodes: make(map[string]string)}
+	for _, record := range records {
+		switch r := record.(type) {
+		case TraceDelegationSignerCheck:
+			g.node(r.Parent, "ellipse", false)
+			g.node(r.Child, "ellipse", false)
+			g.edge(r.Parent, r.Child, "DS "+shortHash(r.Hash), false)
+		case TraceSignatureValidation:
+			g.node(r.Zone, "ellipse", false)
+			label := fmt.Sprintf("RRSIG (%s)", strings.ToUpper(r.KeyType))
+			if r.KeyType == "ksk" {
+				g.edge(r.Zone, r.Zone, "DNSKEY "+label, !r.Valid)
+				continue
+			}
+			rrset := rrsetName(r.Records)
+			g.node(rrset, "box", !r.Valid)
+			g.edge(r.Zone, rrset, label, !r.Valid)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph trust {\n\trankdir=TB;\n")
+	for _, name := range g.order {
+		b.WriteString(g.nodes[name])
+	}
+	for _, edge := range g.edges {
+		b.WriteString(edge)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotGraph collects a DOT graph's nodes, in the order they're first seen, and its edges.
+type dotGraph struct {
+	order []string
+	nodes map[string]string
+	edges []string
+}
+
+// node adds a node, once. A failed node is drawn in red, even if it was first added as not failed.
+func (g *dotGraph) node(name, shape string, failed bool) {
+	existing, ok := g.nodes[name]
+	if ok && (!failed || strings.Contains(existing, "color=red")) {
+		return
+	}
+	if !ok {
+		g.order = append(g.order, name)
+	}
+	g.nodes[name] = fmt.Sprintf("\t%s [shape=%s%s];\n", strconv.Quote(name), shape, dotFailure(failed))
+}
+
+func (g *dotGraph) edge(from, to, label string, failed bool) {
+	g.edges = append(g.edges, fmt.Sprintf("\t%s -> %s [label=%s%s];\n", strconv.Quote(from), strconv.Quote(to), strconv.Quote(label), dotFailure(failed)))
+}
+
+// dotFailure returns the attributes that highlight a failure.
+func dotFailure(failed bool) string {
+	if failed {
+		return ", color=red, fontcolor=red, style=bold"
+	}
+	return ""
+}
+
+// rrsetName names an RRset by its owner and type, taken from the first of its records, e.g. "example.com. A".
+func rrsetName(records []string) string {
+	if len(records) == 0 {
+		return "(no records)"
+	}
+	fields := strings.Fields(records[0])
+	if len(fields) < 4 {
+		return records[0]
+	}
+	return fields[0] + " " + fields[3]
+}
+
+// shortHash shortens a DS digest for use as a label.
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8] + "…"
+	}
+	return hash
+}
@@ -0,0 +1,87 @@
+package lookup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Render writes the trace in a form like `dig +trace` and delv's, one event per line, with the steps of validation
+// indented by how far up the chain of trust they are.
+func (t *Trace) Render(w io.Writer) error {
+	t.mu.Lock()
+	records := append([]traceRecord(nil), t.Records...)
+	t.mu.Unlock()
+
+	for _, record := range records {
+		var err error
+		switch r := record.(type) {
+		case TraceLookup:
+			err = renderLookup(w, r)
+		case TraceSignatureValidation:
+			err = renderSignatureValidation(w, r)
+		case TraceDelegationSignerCheck:
+			_, err = fmt.Fprintf(w, "%s;; %s DS %s found at %s: matches key\n", traceIndent(r.Depth), r.Child, r.Hash, r.Parent)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Trace) String() string {
+	var b bytes.Buffer
+	t.Render(&b)
+	return b.String()
+}
+
+// renderLookup writes the answers to a query, followed by where they came from, as dig does.
+func renderLookup(w io.Writer, r TraceLookup) error {
+	for _, answer := range r.Answers {
+		if _, err := fmt.Fprintln(w, answer); err != nil {
+			return err
+		}
+	}
+	for _, ede := range r.ExtendedErrors {
+		if _, err := fmt.Fprintf(w, "; EDE: %s\n", ede); err != nil {
+			return err
+		}
+	}
+
+	summary := fmt.Sprintf(";; Received %d records for %s %s from %s in %s", len(r.Answers), r.Domain, r.Rrtype, r.Nameserver, r.Latency)
+	if r.Err != nil {
+		summary = fmt.Sprintf(";; Query for %s %s to %s failed in %s: %s", r.Domain, r.Rrtype, r.Nameserver, r.Latency, r.Err)
+	}
+	_, err := fmt.Fprintf(w, "%s\n\n", summary)
+	return err
+}
+
+// renderSignatureValidation writes a signature, and the outcome of verifying it, as delv does.
+func renderSignatureValidation(w io.Writer, r TraceSignatureValidation) error {
+	indent := traceIndent(r.Depth)
+	outcome := "fully validated"
+	if !r.Valid {
+		outcome = "validation failed"
+		if r.Err != nil {
+			outcome += ": " + r.Err.Error()
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s; %s %s (%s, key sha256 %s): %s\n", indent, r.Zone, strings.ToUpper(r.KeyType), r.Algorithm, r.KeySha256, outcome)
+	for _, record := range r.Records {
+		fmt.Fprintf(&b, "%s%s\n", indent, record)
+	}
+	if r.Signature != "" {
+		fmt.Fprintf(&b, "%s%s\n", indent, r.Signature)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// traceIndent returns the indentation for a validation step at depth.
+func traceIndent(depth uint8) string {
+	return strings.Repeat("  ", int(depth)+1)
+}
@@ -0,0 +1,177 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace_MarshalJSON(t *testing.T) {
+	trace := new(Trace)
+	trace.Add(TraceLookup{
+		Domain:     "example.com.",
+		Rrtype:     "A",
+		Nameserver: "udp://192.0.2.1:53",
+		Latency:    time.Millisecond,
+		Answers:    []string{"example.com. 300 IN A 192.0.2.1"},
+	})
+	trace.Add(TraceSignatureValidation{Depth: 1, KeyType: "zsk", Zone: "example.com.", Err: errors.New("bad signature")})
+	trace.Add(TraceDelegationSignerCheck{Depth: 1, Child: "example.com.", Parent: "com.", Hash: "abcd"})
+
+	encoded, err := json.Marshal(trace)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"records": [
+		{
+			"type": "lookup", "domain": "example.com.", "rrtype": "A", "nameserver": "udp://192.0.2.1:53",
+			"latency": 1000000, "answers": ["example.com. 300 IN A 192.0.2.1"]
+		},
+		{
+			"type": "signature-validation", "depth": 1, "key_type": "zsk", "domain": "", "zone": "example.com.",
+			"key": "", "key_sha256": "", "algorithm": "", "signature": "", "records": null, "valid": false,
+			"error": "bad signature"
+		},
+		{"type": "delegation-check", "depth": 1, "child": "example.com.", "parent": "com.", "hash": "abcd"}
+	]}`, string(encoded))
+}
+
+func TestTrace_Render(t *testing.T) {
+	trace := new(Trace)
+	trace.Add(TraceLookup{
+		Domain:     "example.com.",
+		Rrtype:     "A",
+		Nameserver: "udp://192.0.2.1:53",
+		Latency:    time.Millisecond,
+		Answers:    []string{"example.com. 300 IN A 192.0.2.1"},
+	})
+	trace.Add(TraceLookup{Domain: "example.com.", Rrtype: "DS", Nameserver: "udp://192.0.2.2:53", Err: errors.New("i/o timeout")})
+	trace.Add(TraceSignatureValidation{
+		Depth:     1,
+		KeyType:   "zsk",
+		Zone:      "com.",
+		Algorithm: "ECDSAP256SHA256",
+		KeySha256: "abcd",
+		Records:   []string{"example.com. 300 IN DS 1 13 2 ABCD"},
+		Signature: "example.com. 300 IN RRSIG DS 13 2 300 ...",
+		Valid:     true,
+	})
+	trace.Add(TraceDelegationSignerCheck{Depth: 1, Child: "example.com.", Parent: "com.", Hash: "abcd"})
+
+	assert.Equal(t, `example.com. 300 IN A 192.0.2.1
+;; Received 1 records for example.com. A from udp://192.0.2.1:53 in 1ms
+
+;; Query for example.com. DS to udp://192.0.2.2:53 failed in 0s: i/o timeout
+
+    ; com. ZSK (ECDSAP256SHA256, key sha256 abcd): fully validated
+    example.com. 300 IN DS 1 13 2 ABCD
+    example.com. 300 IN RRSIG DS 13 2 300 ...
+    ;; example.com. DS abcd found at com.: matches key
+`, trace.String())
+}
+
+func TestTrace_WriteDOT(t *testing.T) {
+	trace := new(Trace)
+	trace.Add(TraceSignatureValidation{
+		Depth: 0, KeyType: "zsk", Zone: "example.com.", Records: []string{"example.com.\t300\tIN\tA\t192.0.2.1"},
+		Err: errors.New("bad signature"),
+	})
+	trace.Add(TraceSignatureValidation{Depth: 0, KeyType: "ksk", Zone: "example.com.", Valid: true})
+	trace.Add(TraceDelegationSignerCheck{Depth: 0, Child: "example.com.", Parent: "com.", Hash: "0123456789abcdef"})
+
+	var b strings.Builder
+	require.NoError(t, trace.WriteDOT(&b))
+	assert.Equal(t, `digraph trust {
+	rankdir=TB;
+	"example.com." [shape=ellipse];
+	"example.com. A" [shape=box, color=red, fontcolor=red, style=bold];
+	"com." [shape=ellipse];
+	"example.com." -> "example.com. A" [label="RRSIG (ZSK)", color=red, fontcolor=red, style=bold];
+	"example.com." -> "example.com." [label="DNSKEY RRSIG (KSK)"];
+	"com." -> "example.com." [label="DS 01234567…"];
+}
+`, b.String())
+}
+
+// traceDomains returns the domains looked up in a trace.
+func traceDomains(trace *Trace) []string {
+	var domains []string
+	for _, r := range trace.Records {
+		if lookup, ok := r.(TraceLookup); ok {
+			domains = append(domains, lookup.Domain)
+		}
+	}
+	return domains
+}
+
+func TestDnsLookup_QueryResultTrace(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	// Without EnableTrace, results have no trace.
+	result := d.QueryResult("test.example.com.", dns.TypeA)
+	require.NoError(t, result.Err)
+	assert.Nil(t, result.Trace)
+
+	// Concurrent queries each get their own trace, and DnsLookup.Trace is left alone.
+	d.EnableTrace = true
+	var wg sync.WaitGroup
+	results := make([]Result, 2)
+	for i, rrtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = d.QueryResult("test.example.com.", rrtype)
+		}()
+	}
+	wg.Wait()
+
+	for i, rrtype := range []string{"A", "AAAA"} {
+		require.NotNil(t, results[i].Trace)
+		lookup, ok := results[i].Trace.Records[0].(TraceLookup)
+		require.True(t, ok)
+		assert.Equal(t, "test.example.com.", lookup.Domain)
+		assert.Equal(t, rrtype, lookup.Rrtype)
+		assert.NotEmpty(t, results[i].Trace.String())
+	}
+	assert.NotSame(t, results[0].Trace, results[1].Trace)
+	assert.Nil(t, d.Trace)
+
+	// Query still sets DnsLookup.Trace, for backwards compatibility.
+	_, _, err := d.Query("test.example.com.", dns.TypeA)
+	require.NoError(t, err)
+	require.NotNil(t, d.Trace)
+	assert.Contains(t, traceDomains(d.Trace), "test.example.com.")
+}
+
+func TestContextWithTrace(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	// The trace is recorded to even without EnableTrace, and is the one returned in a Result.
+	trace := new(Trace)
+	ctx := ContextWithTrace(context.Background(), trace)
+	_, err := d.QueryAContext(ctx, "test.example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, "test.example.com.", traceDomains(trace)[0])
+	assert.Nil(t, d.Trace)
+
+	result := d.QueryResultContext(ctx, "test.example.com.", dns.TypeA)
+	require.NoError(t, result.Err)
+	assert.Same(t, trace, result.Trace)
+}
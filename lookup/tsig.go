@@ -0,0 +1,106 @@
+package lookup
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tsigFudge is how many seconds a signed message's time may differ from the server's clock.
+const tsigFudge = 300
+
+// TsigKey is a key shared with a nameserver, used to sign queries to it with TSIG (RFC 8945), as private and internal
+// servers often require.
+type TsigKey struct {
+	Name      string // The key's name, as configured on the server, e.g. "internal-key."
+	Algorithm string // The HMAC algorithm, e.g. dns.HmacSHA256; defaults to HMAC-SHA256 when empty
+	Secret    string // The base64 encoded secret
+}
+
+// WithTsig signs queries to a UDP, TCP or TLS nameserver with a TSIG key, and checks the signature on responses.
+// A response that isn't signed, or whose signature doesn't verify, is returned as an error.
+func WithTsig(key TsigKey) NameServerOption {
+	return func(n *NameServerConcrete) {
+		key.Name = dns.CanonicalName(key.Name)
+		if key.Algorithm == "" {
+			key.Algorithm = dns.HmacSHA256
+		}
+		key.Algorithm = dns.CanonicalName(key.Algorithm)
+
+		if err := key.validate(); err != nil {
+			n.err = err
+			return
+		}
+
+		for _, client := range []DNSClient{n.client, n.fallback} {
+			if pool, ok := client.(*pooledClient); ok {
+				client = pool.client
+			}
+			if c, ok := client.(*dns.Client); ok {
+				c.TsigSecret = map[string]string{key.Name: key.Secret}
+			} else if client != nil {
+				n.err = fmt.Errorf("tsig isn't supported by %s nameservers", n.protocol)
+				return
+			}
+		}
+		n.tsig = &key
+	}
+}
+
+// validate checks the key has a name, a supported algorithm, and a base64 encoded secret.
+func (k TsigKey) validate() error {
+	if _, ok := dns.IsDomainName(k.Name); !ok || k.Name == "." {
+		return fmt.Errorf("invalid tsig key name %s", k.Name)
+	}
+	switch k.Algorithm {
+	case dns.HmacSHA1, dns.HmacSHA224, dns.HmacSHA256, dns.HmacSHA384, dns.HmacSHA512:
+	default:
+		return fmt.Errorf("unsupported tsig algorithm %s", strings.TrimSuffix(k.Algorithm, "."))
+	}
+	if _, err := base64.StdEncoding.DecodeString(k.Secret); err != nil || k.Secret == "" {
+		return fmt.Errorf("tsig secret for %s is not valid base64", k.Name)
+	}
+	return nil
+}
+
+// signedLen returns the length of the TSIG record a message with the given id is signed with, which has the
+// algorithm's full length MAC. The record is appended uncompressed.
+func (k TsigKey) signedLen(id uint16) int {
+	var size int
+	switch k.Algorithm {
+	case dns.HmacSHA1:
+		size = sha1.Size
+	case dns.HmacSHA224:
+		size = sha256.Size224
+	case dns.HmacSHA256:
+		size = sha256.Size
+	case dns.HmacSHA384:
+		size = sha512.Size384
+	case dns.HmacSHA512:
+		size = sha512.Size
+	}
+
+	tsig := &dns.TSIG{
+		Hdr:        dns.RR_Header{Name: k.Name, Rrtype: dns.TypeTSIG, Class: dns.ClassANY},
+		Algorithm:  k.Algorithm,
+		Fudge:      tsigFudge,
+		MACSize:    uint16(size),
+		MAC:        strings.Repeat("00", size),
+		OrigId:     id,
+		TimeSigned: uint64(time.Now().Unix()),
+	}
+	return dns.Len(tsig)
+}
+
+// addTsig adds a TSIG record to a message, to be signed by the client when it's sent. It has no effect if key is nil.
+func addTsig(msg *dns.Msg, key *TsigKey) {
+	if key != nil {
+		msg.SetTsig(key.Name, key.Algorithm, tsigFudge, time.Now().Unix())
+	}
+}
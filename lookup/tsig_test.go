@@ -0,0 +1,95 @@
+package lookup
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTsigSecret = "c2VjcmV0LWtleS1mb3ItdGVzdGluZy10c2ln"
+
+// newTsigTestServer starts a UDP server that only answers queries signed with the key, signing its responses.
+// If sign is false, responses are left unsigned.
+func newTsigTestServer(t *testing.T, name string, sign bool) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &dns.Server{
+		PacketConn: conn,
+		TsigSecret: map[string]string{name: testTsigSecret},
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			msg := new(dns.Msg)
+			msg.SetReply(r)
+			if r.IsTsig() == nil || w.TsigStatus() != nil {
+				msg.Rcode = dns.RcodeNotAuth
+			} else {
+				rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A 192.0.2.1")
+				msg.Answer = []dns.RR{rr}
+			}
+			if sign && r.IsTsig() != nil {
+				msg.SetTsig(name, dns.HmacSHA256, 300, time.Now().Unix())
+			}
+			w.WriteMsg(msg)
+		}),
+	}
+	go server.ActivateAndServe()
+	t.Cleanup(func() {
+		server.Shutdown()
+	})
+
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	return port
+}
+
+func TestWithTsig(t *testing.T) {
+	port := newTsigTestServer(t, "test-key.", true)
+
+	ns := NewUdpNameserver("127.0.0.1", port, WithTsig(TsigKey{Name: "Test-Key", Secret: testTsigSecret}))
+	msg, _, err := ns.Query("example.com.", dns.TypeA)
+	require.NoError(t, err)
+	assert.Len(t, msg.Answer, 1)
+	assert.NotNil(t, msg.IsTsig())
+
+	// Without the key, or with the wrong secret, the query is refused.
+	_, _, err = NewUdpNameserver("127.0.0.1", port).Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "returned NOTAUTH")
+
+	ns = NewUdpNameserver("127.0.0.1", port, WithTsig(TsigKey{Name: "test-key.", Secret: "d3Jvbmc="}))
+	_, _, err = ns.Query("example.com.", dns.TypeA)
+	assert.Error(t, err)
+}
+
+func TestWithTsig_UnsignedResponse(t *testing.T) {
+	port := newTsigTestServer(t, "test-key.", false)
+
+	ns := NewUdpNameserver("127.0.0.1", port, WithTsig(TsigKey{Name: "test-key.", Secret: testTsigSecret}))
+	_, _, err := ns.Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "response was not signed with tsig key test-key.")
+}
+
+func TestWithTsig_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         TsigKey
+		expectedErr string
+	}{
+		{name: "No name", key: TsigKey{Secret: testTsigSecret}, expectedErr: "invalid tsig key name"},
+		{name: "Bad secret", key: TsigKey{Name: "key.", Secret: "not base64!"}, expectedErr: "not valid base64"},
+		{name: "Unsupported algorithm", key: TsigKey{Name: "key.", Algorithm: dns.HmacMD5, Secret: testTsigSecret}, expectedErr: "unsupported tsig algorithm hmac-md5.sig-alg.reg.int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewTcpNameserver("127.0.0.1", "53", WithTsig(tt.key)).Query("example.com.", dns.TypeA)
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+
+	_, _, err := NewQuicNameserver("127.0.0.1", "853", "dns.example", WithTsig(TsigKey{Name: "key.", Secret: testTsigSecret})).
+		Query("example.com.", dns.TypeA)
+	assert.ErrorContains(t, err, "tsig isn't supported by quic nameservers")
+}
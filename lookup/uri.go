@@ -0,0 +1,34 @@
+package lookup
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/miekg/dns"
+)
+
+// QueryURI performs a DNS query for URI records, returning them in the order they're to be tried
+func (d *DnsLookup) QueryURI(name string) ([]*dns.URI, error) {
+	return d.QueryURIContext(context.Background(), name)
+}
+
+// QueryURIContext performs a DNS query for URI records, stopping when ctx is done
+func (d *DnsLookup) QueryURIContext(ctx context.Context, name string) ([]*dns.URI, error) {
+	msg, _, err := d.QueryContext(ctx, name, dns.TypeURI)
+	if err != nil {
+		return nil, err
+	}
+	records := extractRecordsOfType[*dns.URI](msg.Answer)
+	sortURI(records)
+	return records, nil
+}
+
+// sortURI sorts records by their Priority, lowest first, then by their Weight, highest first. RFC 7553 has records of
+// the same priority chosen at random in proportion to their weight; those wanting that can shuffle each priority's
+// records themselves, but most callers just want the preferred target first.
+func sortURI(records []*dns.URI) {
+	slices.SortStableFunc(records, func(a, b *dns.URI) int {
+		return cmp.Or(cmp.Compare(a.Priority, b.Priority), cmp.Compare(b.Weight, a.Weight))
+	})
+}
@@ -0,0 +1,31 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryURI(t *testing.T) {
+	name := "_ftp._tcp.example.com."
+
+	ns := &namedMockNameServer{name: "mock"}
+	ns.On("Query", name, dns.TypeURI).Return(newAnswerMsg(t,
+		name+` 300 IN URI 20 1 "ftp://ftp3.example.com/public"`,
+		name+` 300 IN URI 10 1 "ftp://ftp2.example.com/public"`,
+		name+` 300 IN URI 10 5 "ftp://ftp1.example.com/public"`,
+	), time.Millisecond, nil)
+
+	d := &DnsLookup{nameservers: []NameServer{ns}}
+
+	records, err := d.QueryURI(name)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "ftp://ftp1.example.com/public", records[0].Target)
+	assert.Equal(t, "ftp://ftp2.example.com/public", records[1].Target)
+	assert.Equal(t, "ftp://ftp3.example.com/public", records[2].Target)
+}
@@ -0,0 +1,128 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ValidationStatus is the outcome of validating a response, as defined in RFC 4033, section 5.
+type ValidationStatus uint8
+
+const (
+	ValidationIndeterminate ValidationStatus = iota // There were no trust anchors, or the chain was incomplete
+	ValidationSecure                                // A chain of trust from a trust anchor to the response was verified
+	ValidationBogus                                 // The chain of trust was present but failed to verify
+	ValidationInsecure                              // The response is from an unsigned zone, proven to be below an insecure delegation
+)
+
+func (s ValidationStatus) String() string {
+	switch s {
+	case ValidationSecure:
+		return "secure"
+	case ValidationBogus:
+		return "bogus"
+	case ValidationInsecure:
+		return "insecure"
+	default:
+		return "indeterminate"
+	}
+}
+
+// errNotInChain is returned by a chainNameServer when it's asked for records it wasn't given.
+var errNotInChain = errors.New("records not found in the chain")
+
+// Verify validates a response against the records of its chain of trust, obtained elsewhere, without querying any
+// nameservers. The chain holds the DNSKEY and DS RRsets, with their RRSIGs, of every zone from the response's zone
+// up to the root; the anchors are the root's DS records, e.g. from anchors.GetValidFromEmbedded().
+//
+// An unsigned response is ValidationInsecure if the chain also holds the parent's signed NSEC or NSEC3 denial of the
+// DS records for its zone, at any name from the response's up to the delegation. The returned error explains why a
+// response is ValidationBogus or ValidationIndeterminate.
+func Verify(msg *dns.Msg, chain []dns.RR, anchors []*dns.DS) (ValidationStatus, error) {
+	if msg == nil || len(msg.Question) == 0 {
+		return ValidationIndeterminate, fmt.Errorf("no DNS message provided")
+	}
+	if len(anchors) == 0 {
+		return ValidationIndeterminate, fmt.Errorf("no trust anchors provided")
+	}
+
+	d := NewDnsLookup([]NameServer{newChainNameServer(chain)})
+	d.RootDNSSECRecords = anchors
+	d.RemotelyAuthenticateData = false
+	d.RandomNameserver = false
+
+	return d.Validate(msg, context.Background())
+}
+
+//-----
+
+// chainNameServer is a NameServer that answers from a fixed set of records. Records it wasn't given are answered
+// with a NODATA response if the chain holds an NSEC or NSEC3 record denying them, as a nameserver would.
+type chainNameServer struct {
+	rrsets map[string][]dns.RR
+	nsec3  []*dns.NSEC3
+}
+
+func newChainNameServer(chain []dns.RR) *chainNameServer {
+	n := &chainNameServer{rrsets: make(map[string][]dns.RR)}
+	for _, rr := range chain {
+		rrtype := rr.Header().Rrtype
+		if rrsig, ok := rr.(*dns.RRSIG); ok {
+			rrtype = rrsig.TypeCovered
+		}
+		if nsec3, ok := rr.(*dns.NSEC3); ok {
+			n.nsec3 = append(n.nsec3, nsec3)
+		}
+		k := chainKey(rr.Header().Name, rrtype)
+		n.rrsets[k] = append(n.rrsets[k], rr)
+	}
+	return n
+}
+
+func chainKey(name string, rrtype uint16) string {
+	return fmt.Sprintf("%s %d", strings.ToLower(dns.Fqdn(name)), rrtype)
+}
+
+func (n *chainNameServer) Query(name string, rrtype uint16) (*dns.Msg, time.Duration, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), rrtype)
+	msg.Response = true
+	msg.SetEdns0(4096, true)
+
+	if records, ok := n.rrsets[chainKey(name, rrtype)]; ok {
+		msg.Answer = records
+		return msg, 0, nil
+	}
+	if denial := n.denial(name, rrtype); len(denial) > 0 {
+		msg.Ns = denial
+		return msg, 0, nil
+	}
+	return nil, 0, fmt.Errorf("%s %s: %w", dns.Fqdn(name), rrtypeToString(rrtype), errNotInChain)
+}
+
+// denial returns the NSEC or NSEC3 records matching name whose type bitmaps don't include rrtype, with their RRSIGs.
+func (n *chainNameServer) denial(name string, rrtype uint16) []dns.RR {
+	var denial []dns.RR
+	for _, rr := range n.rrsets[chainKey(name, dns.TypeNSEC)] {
+		if nsec, ok := rr.(*dns.NSEC); ok && !slices.Contains(nsec.TypeBitMap, rrtype) {
+			denial = append(denial, n.rrsets[chainKey(name, dns.TypeNSEC)]...)
+			break
+		}
+	}
+	for _, nsec3 := range n.nsec3 {
+		if nsec3.Match(dns.Fqdn(name)) && !slices.Contains(nsec3.TypeBitMap, rrtype) {
+			denial = append(denial, n.rrsets[chainKey(nsec3.Hdr.Name, dns.TypeNSEC3)]...)
+		}
+	}
+	return denial
+}
+
+func (n *chainNameServer) String() string {
+	return "chain"
+}
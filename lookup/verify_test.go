@@ -0,0 +1,116 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/dnssectest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVerifyInputs returns a response for test.example.com., and the chain of trust for it, from the test chain.
+func newVerifyInputs(t *testing.T, zones []*dnssectest.Zone) (*dns.Msg, []dns.RR) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("test.example.com.", dns.TypeA)
+	msg.Response = true
+	msg.Answer = zones[2].Get("test.example.com.", dns.TypeA)
+
+	chain := make([]dns.RR, 0)
+	for _, zone := range zones {
+		chain = append(chain, zone.Get(zone.Name, dns.TypeDNSKEY)...)
+		if zone.Parent != nil {
+			chain = append(chain, zone.Parent.Get(zone.Name, dns.TypeDS)...)
+		}
+	}
+	return msg, chain
+}
+
+func TestVerify(t *testing.T) {
+	zones := newTestChain(t)
+	msg, chain := newVerifyInputs(t, zones)
+
+	status, err := Verify(msg, chain, zones[0].TrustAnchors())
+	assert.NoError(t, err)
+	assert.Equal(t, ValidationSecure, status)
+}
+
+func TestVerify_Indeterminate(t *testing.T) {
+	zones := newTestChain(t)
+	msg, chain := newVerifyInputs(t, zones)
+
+	status, err := Verify(msg, chain, nil)
+	assert.ErrorContains(t, err, "no trust anchors provided")
+	assert.Equal(t, ValidationIndeterminate, status)
+
+	// Drop the DS records for com.
+	incomplete := make([]dns.RR, 0)
+	for _, rr := range chain {
+		if rr.Header().Name != "com." || (rr.Header().Rrtype != dns.TypeDS && !isRRSIGCovering(rr, dns.TypeDS)) {
+			incomplete = append(incomplete, rr)
+		}
+	}
+
+	status, err = Verify(msg, incomplete, zones[0].TrustAnchors())
+	assert.ErrorContains(t, err, "com. DS: records not found in the chain")
+	assert.Equal(t, ValidationIndeterminate, status)
+}
+
+func TestVerify_Bogus(t *testing.T) {
+	zones := newTestChain(t)
+	msg, chain := newVerifyInputs(t, zones)
+
+	other, err := dnssectest.NewZone(".", nil, dns.ECDSAP256SHA256, dns.ECDSAP256SHA256)
+	assert.NoError(t, err)
+
+	status, err := Verify(msg, chain, other.TrustAnchors())
+	assert.ErrorContains(t, err, "unable to find a matching DS digest at the root")
+	assert.Equal(t, ValidationBogus, status)
+}
+
+func isRRSIGCovering(rr dns.RR, rrtype uint16) bool {
+	rrsig, ok := rr.(*dns.RRSIG)
+	return ok && rrsig.TypeCovered == rrtype
+}
+
+func TestVerify_Insecure(t *testing.T) {
+	zones := newTestChain(t)
+	_, chain := newVerifyInputs(t, zones)
+	com := zones[1]
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.unsigned.com.", dns.TypeA)
+	msg.Response = true
+	rr, err := dns.NewRR("www.unsigned.com. 300 IN A 192.0.2.1")
+	require.NoError(t, err)
+	msg.Answer = []dns.RR{rr}
+
+	// Without the denial of DS records for unsigned.com., the chain can't show whether it's signed.
+	status, err := Verify(msg, chain, zones[0].TrustAnchors())
+	assert.ErrorContains(t, err, "unable to check for an insecure delegation at unsigned.com.")
+	assert.Equal(t, ValidationIndeterminate, status)
+
+	nsec := &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: "unsigned.com.", Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+		NextDomain: "zzz.com.",
+		TypeBitMap: []uint16{dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC},
+	}
+	rrsig, err := dnssectest.Sign([]dns.RR{nsec}, com.ZSK, com.ZSKSigner, com.Inception, com.Expiration)
+	require.NoError(t, err)
+
+	status, err = Verify(msg, append(chain, nsec, rrsig), zones[0].TrustAnchors())
+	assert.NoError(t, err)
+	assert.Equal(t, ValidationInsecure, status)
+
+	// A denial without the parent's signature proves nothing.
+	status, _ = Verify(msg, append(chain, nsec), zones[0].TrustAnchors())
+	assert.Equal(t, ValidationBogus, status)
+
+	// Nor does one showing the delegation is signed.
+	signed := dns.Copy(nsec).(*dns.NSEC)
+	signed.TypeBitMap = []uint16{dns.TypeNS, dns.TypeDS, dns.TypeRRSIG, dns.TypeNSEC}
+	rrsig, err = dnssectest.Sign([]dns.RR{signed}, com.ZSK, com.ZSKSigner, com.Inception, com.Expiration)
+	require.NoError(t, err)
+	status, _ = Verify(msg, append(chain, signed, rrsig), zones[0].TrustAnchors())
+	assert.NotEqual(t, ValidationInsecure, status)
+}
@@ -0,0 +1,101 @@
+package lookup
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WalkZone enumerates the names within an NSEC signed zone by following the Next Domain field of each NSEC
+// record, starting at the zone's apex, until the chain leads back to the apex. The NSEC records are returned
+// in chain order, so their type bitmaps show which record types exist at each name.
+//
+// Delegations to child zones are returned as the parent's NSEC record for the delegation, with the NS bit set and the
+// SOA bit clear; the child zones themselves aren't walked.
+//
+// Queries are spaced at least ZoneWalkInterval apart, to avoid overloading the nameservers.
+// Zones signed using NSEC3 can't be walked.
+func (d *DnsLookup) WalkZone(zone string) ([]*dns.NSEC, error) {
+	apex := strings.ToLower(dns.Fqdn(zone))
+
+	logger := d.componentLogger(LogComponentQuery).With().Str("zone", apex).Logger()
+	logger.Info().Msg("Walking zone")
+
+	var throttle <-chan time.Time
+	if d.ZoneWalkInterval > 0 {
+		ticker := time.NewTicker(d.ZoneWalkInterval)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	results := make([]*dns.NSEC, 0)
+	seen := make(map[string]bool)
+
+	name := apex
+	for {
+		seen[name] = true
+
+		records, err := d.QueryNSEC(name)
+		if err != nil {
+			return results, err
+		}
+
+		var nsec *dns.NSEC
+		for _, record := range records {
+			if !strings.EqualFold(record.Header().Name, name) {
+				continue
+			}
+			// At a delegation, the child zone's apex has an NSEC record too; the parent's is the one in this zone's chain.
+			if nsec == nil || (name != apex && isZoneApexNSEC(nsec) && !isZoneApexNSEC(record)) {
+				nsec = record
+			}
+		}
+		if nsec == nil {
+			return results, fmt.Errorf("no NSEC record found for %s; the zone may not be signed, or may use NSEC3", name)
+		}
+		if name != apex && isZoneApexNSEC(nsec) {
+			// Following this record would walk the child zone's chain, rather than this zone's.
+			return results, fmt.Errorf("%s is delegated to a child zone, and only the child zone's NSEC record was returned for it", name)
+		}
+
+		results = append(results, nsec)
+
+		next := strings.ToLower(dns.Fqdn(nsec.NextDomain))
+		logger.Debug().Str("name", name).Str("next", next).Msg("NSEC record found")
+
+		if name != apex && slices.Contains(nsec.TypeBitMap, dns.TypeNS) {
+			// The names below a delegation belong to the child zone, so the parent's chain should skip past them.
+			logger.Debug().Str("name", name).Msg("Skipping delegated child zone")
+			if next != name && dns.IsSubDomain(name, next) {
+				return results, fmt.Errorf("NSEC record for the delegation %s points into the child zone, to %s", name, next)
+			}
+		}
+
+		if next == apex {
+			break
+		}
+		if !dns.IsSubDomain(apex, next) {
+			return results, fmt.Errorf("NSEC record for %s points outside of the zone, to %s", name, next)
+		}
+		if seen[next] {
+			return results, fmt.Errorf("NSEC record for %s points back to %s, which has already been seen", name, next)
+		}
+
+		name = next
+
+		if throttle != nil {
+			<-throttle
+		}
+	}
+
+	logger.Info().Int("number-of-names", len(results)).Msg("Zone walk complete")
+
+	return results, nil
+}
+
+// isZoneApexNSEC reports whether an NSEC record is at the apex of a zone, which is the only place a SOA record exists.
+func isZoneApexNSEC(nsec *dns.NSEC) bool {
+	return slices.Contains(nsec.TypeBitMap, dns.TypeSOA)
+}
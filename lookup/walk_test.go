@@ -0,0 +1,138 @@
+package lookup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWalkResponseMsg creates a new dns.Msg containing an NSEC record for name, pointing to next.
+func newWalkResponseMsg(name, next string) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, dns.TypeNSEC)
+	msg.Answer = []dns.RR{
+		&dns.NSEC{
+			Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+			NextDomain: next,
+			TypeBitMap: []uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC},
+		},
+	}
+	return msg
+}
+
+func TestDnsLookup_WalkZone(t *testing.T) {
+	ns := new(OriginalMockNameServer)
+	ns.On("Query", "example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("example.com.", "a.example.com."), time.Millisecond, nil).Once()
+	ns.On("Query", "a.example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("a.example.com.", "b.example.com."), time.Millisecond, nil).Once()
+	ns.On("Query", "b.example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("b.example.com.", "example.com."), time.Millisecond, nil).Once()
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.RemotelyAuthenticateData = false
+	d.ZoneWalkInterval = time.Millisecond
+
+	records, err := d.WalkZone("Example.com")
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, "a.example.com.", records[0].NextDomain)
+	assert.Equal(t, "b.example.com.", records[1].NextDomain)
+	assert.Equal(t, "example.com.", records[2].NextDomain)
+
+	ns.AssertExpectations(t)
+}
+
+func TestDnsLookup_WalkZoneSignedDelegation(t *testing.T) {
+	// The parent's NSEC record for a signed delegation has the NS and DS bits set; the child's, at its apex, has SOA.
+	delegation := newWalkResponseMsg("child.example.com.", "z.example.com.")
+	delegation.Answer[0].(*dns.NSEC).TypeBitMap = []uint16{dns.TypeNS, dns.TypeDS, dns.TypeRRSIG, dns.TypeNSEC}
+	childApex := &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: "child.example.com.", Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+		NextDomain: "www.child.example.com.",
+		TypeBitMap: []uint16{dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeDNSKEY},
+	}
+
+	tests := []struct {
+		name   string
+		answer []dns.RR
+	}{
+		{name: "Parent's NSEC record only", answer: delegation.Answer},
+		{name: "Child's NSEC record first", answer: []dns.RR{childApex, delegation.Answer[0]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := delegation.Copy()
+			response.Answer = tt.answer
+
+			ns := new(OriginalMockNameServer)
+			ns.On("Query", "example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("example.com.", "a.example.com."), time.Millisecond, nil).Once()
+			ns.On("Query", "a.example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("a.example.com.", "child.example.com."), time.Millisecond, nil).Once()
+			ns.On("Query", "child.example.com.", dns.TypeNSEC).Return(response, time.Millisecond, nil).Once()
+			ns.On("Query", "z.example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("z.example.com.", "example.com."), time.Millisecond, nil).Once()
+
+			d := NewDnsLookup([]NameServer{ns})
+			d.LocallyAuthenticateData = false
+			d.RemotelyAuthenticateData = false
+			d.ZoneWalkInterval = 0
+
+			records, err := d.WalkZone("example.com.")
+			require.NoError(t, err)
+			require.Len(t, records, 4)
+			assert.Equal(t, "child.example.com.", records[2].Hdr.Name)
+			assert.Equal(t, "z.example.com.", records[2].NextDomain)
+
+			ns.AssertExpectations(t)
+			ns.AssertNotCalled(t, "Query", "www.child.example.com.", dns.TypeNSEC)
+		})
+	}
+}
+
+func TestDnsLookup_WalkZoneChildApex(t *testing.T) {
+	childApex := newWalkResponseMsg("child.example.com.", "www.child.example.com.")
+	childApex.Answer[0].(*dns.NSEC).TypeBitMap = []uint16{dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeDNSKEY}
+
+	ns := new(OriginalMockNameServer)
+	ns.On("Query", "example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("example.com.", "child.example.com."), time.Millisecond, nil).Once()
+	ns.On("Query", "child.example.com.", dns.TypeNSEC).Return(childApex, time.Millisecond, nil).Once()
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.RemotelyAuthenticateData = false
+	d.ZoneWalkInterval = 0
+
+	// Only the child zone's NSEC record was returned, so the walk can't continue without following the child's chain.
+	records, err := d.WalkZone("example.com.")
+	assert.EqualError(t, err, "child.example.com. is delegated to a child zone, and only the child zone's NSEC record was returned for it")
+	assert.Len(t, records, 1)
+	ns.AssertNotCalled(t, "Query", "www.child.example.com.", dns.TypeNSEC)
+}
+
+func TestDnsLookup_WalkZoneLoop(t *testing.T) {
+	ns := new(OriginalMockNameServer)
+	ns.On("Query", "example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("example.com.", "a.example.com."), time.Millisecond, nil).Once()
+	ns.On("Query", "a.example.com.", dns.TypeNSEC).Return(newWalkResponseMsg("a.example.com.", "a.example.com."), time.Millisecond, nil).Once()
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.RemotelyAuthenticateData = false
+	d.ZoneWalkInterval = 0
+
+	records, err := d.WalkZone("example.com.")
+	assert.EqualError(t, err, "NSEC record for a.example.com. points back to a.example.com., which has already been seen")
+	assert.Len(t, records, 2)
+}
+
+func TestDnsLookup_WalkZoneNoNSEC(t *testing.T) {
+	ns := new(OriginalMockNameServer)
+	ns.On("Query", "example.com.", dns.TypeNSEC).Return(newLookupResponseMsgWithAD(dns.RcodeSuccess, false), time.Millisecond, nil).Once()
+
+	d := NewDnsLookup([]NameServer{ns})
+	d.LocallyAuthenticateData = false
+	d.RemotelyAuthenticateData = false
+
+	_, err := d.WalkZone("example.com.")
+	assert.ErrorContains(t, err, "no NSEC record found for example.com.")
+}
@@ -0,0 +1,62 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultWarmupZones are the zones whose DNSKEY sets Warmup fetches when none are given.
+var DefaultWarmupZones = []string{".", "com.", "net.", "org."}
+
+// Warmup fetches, and validates, the DNSKEY sets of the given zones, or DefaultWarmupZones if none are given. Doing
+// so at startup checks the nameservers can be reached, and that answers can be authenticated: hostnames of nameservers
+// are resolved, their EDNS(0) support is determined, and the trust anchors are checked against the root keys.
+//
+// When a Cache is set, the DNSKEY and DS sets fetched are added to it, so later queries in those zones are
+// authenticated without fetching them again. Without one, only the nameservers' state is kept, so the first queries
+// still fetch the chain of trust.
+//
+// The zones are fetched concurrently. If ctx is done before they've all been fetched, its error is returned;
+// otherwise an error is returned for each zone that couldn't be fetched or validated.
+func (d *DnsLookup) Warmup(ctx context.Context, zones ...string) error {
+	if len(zones) == 0 {
+		zones = DefaultWarmupZones
+	}
+
+	logger := d.componentLogger(LogComponentQuery)
+	logger.Info().Strs("zones", zones).Msg("Warming up")
+
+	errs := make([]error, len(zones))
+	var wg sync.WaitGroup
+	for i, zone := range zones {
+		wg.Add(1)
+		go func(i int, zone string) {
+			defer wg.Done()
+			if _, _, err := d.QueryContext(ctx, zone, dns.TypeDNSKEY); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", dns.Fqdn(zone), err)
+			}
+		}(i, zone)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		logger.Warn().Err(err).Msg("Warm up incomplete")
+		return fmt.Errorf("warm up incomplete: %w", err)
+	}
+	return nil
+}
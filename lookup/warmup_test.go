@@ -0,0 +1,48 @@
+package lookup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmup(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	d := NewDnsLookup([]NameServer{NewUdpNameserver(server.Address, server.Port)})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+
+	assert.NoError(t, d.Warmup(context.Background(), ".", "com.", "example.com."))
+
+	err := d.Warmup(context.Background(), "com.", "missing.")
+	assert.ErrorContains(t, err, "warm up incomplete")
+	assert.ErrorContains(t, err, "missing.: ")
+	assert.NotContains(t, err.Error(), "com.: ")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, d.Warmup(ctx), context.Canceled)
+}
+
+func TestWarmup_Cache(t *testing.T) {
+	zones := newTestChain(t)
+	server := newTestServer(t, zones)
+
+	ns := &recordingNameServer{NameServer: NewUdpNameserver(server.Address, server.Port)}
+	d := NewDnsLookup([]NameServer{ns})
+	d.RemotelyAuthenticateData = false
+	d.RootDNSSECRecords = zones[0].TrustAnchors()
+	d.Cache = NewCache(DefaultCacheSize)
+
+	require.NoError(t, d.Warmup(context.Background(), "example.com."))
+
+	// The first query's answer is authenticated with the keys fetched by the warm up.
+	ns.questions = nil
+	_, err := d.QueryA("test.example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test.example.com. A"}, ns.questions)
+}
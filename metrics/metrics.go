@@ -0,0 +1,132 @@
+// Package metrics exposes the queries made by a lookup.DnsLookup as Prometheus metrics.
+//
+// A Collector is both a prometheus.Collector, to be registered on any prometheus.Registerer, and a
+// lookup.ValidationObserver, to be set as the DnsLookup's Observer:
+//
+//	collector := metrics.NewCollector(client.Cache)
+//	prometheus.MustRegister(collector)
+//	client.Observer = collector
+package metrics
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/lookup"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector records the lookups and validation steps it observes as Prometheus metrics.
+type Collector struct {
+	queries     *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	signatures  *prometheus.CounterVec
+	validations *prometheus.CounterVec
+	delegations prometheus.Counter
+	cacheHits   prometheus.CounterFunc
+	cacheMisses prometheus.CounterFunc
+}
+
+var _ lookup.ValidationObserver = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector creates a Collector. If cache isn't nil, its hits and misses are exposed too.
+func NewCollector(cache *lookup.Cache) *Collector {
+	c := &Collector{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dns_lookup_queries_total",
+			Help: "Queries sent to nameservers, by record type and the rcode of the response, or NONE if there wasn't one.",
+		}, []string{"rrtype", "rcode"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dns_lookup_nameserver_latency_seconds",
+			Help:    "How long nameservers took to respond to queries.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"nameserver"}),
+		signatures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dns_lookup_signature_validations_total",
+			Help: "DNSSEC signatures verified, by the type of key used and whether they were valid.",
+		}, []string{"key_type", "result"}),
+		validations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dns_lookup_validations_total",
+			Help: "Queries whose answer was validated, by the outcome: secure, insecure or bogus.",
+		}, []string{"result"}),
+		delegations: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "dns_lookup_delegation_checks_total",
+			Help: "DNSSEC zone keys matched with a DS record from their parent.",
+		}),
+	}
+
+	if cache != nil {
+		c.cacheHits = prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "dns_lookup_cache_hits_total",
+			Help: "Queries answered from the cache.",
+		}, func() float64 { return float64(cache.Stats().Hits) })
+		c.cacheMisses = prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "dns_lookup_cache_misses_total",
+			Help: "Queries not found in the cache.",
+		}, func() float64 { return float64(cache.Stats().Misses) })
+	}
+	return c
+}
+
+// collectors returns the metrics the Collector exposes.
+func (c *Collector) collectors() []prometheus.Collector {
+	collectors := []prometheus.Collector{c.queries, c.latency, c.signatures, c.validations, c.delegations}
+	if c.cacheHits != nil {
+		collectors = append(collectors, c.cacheHits, c.cacheMisses)
+	}
+	return collectors
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// OnLookup counts a query sent to a nameserver, and records its latency.
+func (c *Collector) OnLookup(r lookup.TraceLookup) {
+	c.queries.WithLabelValues(r.Rrtype, rcodeLabel(r.Err)).Inc()
+	c.latency.WithLabelValues(r.Nameserver).Observe(r.Latency.Seconds())
+}
+
+// OnSignatureValidation counts a signature verified.
+func (c *Collector) OnSignatureValidation(r lookup.TraceSignatureValidation) {
+	result := "valid"
+	if !r.Valid {
+		result = "invalid"
+	}
+	c.signatures.WithLabelValues(r.KeyType, result).Inc()
+}
+
+// OnValidation counts a query's answer validated, by its outcome.
+func (c *Collector) OnValidation(r lookup.QueryValidation) {
+	c.validations.WithLabelValues(r.Status.String()).Inc()
+}
+
+// OnDelegationCheck counts a zone key matched with its DS record.
+func (c *Collector) OnDelegationCheck(lookup.TraceDelegationSignerCheck) {
+	c.delegations.Inc()
+}
+
+// rcodeLabel returns the name of the rcode a lookup's error was returned with.
+func rcodeLabel(err error) string {
+	if err == nil {
+		return dns.RcodeToString[dns.RcodeSuccess]
+	}
+	var queryErr *lookup.QueryError
+	if !errors.As(err, &queryErr) || queryErr.Rcode < 0 {
+		return "NONE"
+	}
+	if name, ok := dns.RcodeToString[queryErr.Rcode]; ok {
+		return name
+	}
+	return strconv.Itoa(queryErr.Rcode)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nsmithuk/dns-lookup-go/lookup"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	cache := lookup.NewCache(10)
+	cache.Get("example.com.", dns.TypeA)
+
+	collector := NewCollector(cache)
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	collector.OnLookup(lookup.TraceLookup{Rrtype: "A", Nameserver: "udp://192.0.2.1:53", Latency: 5 * time.Millisecond})
+	collector.OnLookup(lookup.TraceLookup{Rrtype: "A", Nameserver: "udp://192.0.2.1:53", Err: &lookup.QueryError{Rcode: dns.RcodeNameError}})
+	collector.OnLookup(lookup.TraceLookup{Rrtype: "AAAA", Nameserver: "udp://192.0.2.2:53", Err: &lookup.QueryError{Rcode: -1, Err: errors.New("i/o timeout")}})
+	collector.OnSignatureValidation(lookup.TraceSignatureValidation{KeyType: "zsk", Valid: true})
+	collector.OnSignatureValidation(lookup.TraceSignatureValidation{KeyType: "ksk", Err: errors.New("bad signature")})
+	collector.OnDelegationCheck(lookup.TraceDelegationSignerCheck{})
+	collector.OnValidation(lookup.QueryValidation{Rrtype: "A", Status: lookup.ValidationSecure})
+	collector.OnValidation(lookup.QueryValidation{Rrtype: "A", Status: lookup.ValidationSecure})
+	collector.OnValidation(lookup.QueryValidation{Rrtype: "TLSA", Status: lookup.ValidationBogus, Err: errors.New("bad signature")})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.queries.WithLabelValues("A", "NOERROR")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.queries.WithLabelValues("A", "NXDOMAIN")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.queries.WithLabelValues("AAAA", "NONE")))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector.latency))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.signatures.WithLabelValues("zsk", "valid")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.signatures.WithLabelValues("ksk", "invalid")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.delegations))
+	assert.Equal(t, 2.0, testutil.ToFloat64(collector.validations.WithLabelValues("secure")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.validations.WithLabelValues("bogus")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(collector.cacheHits))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.cacheMisses))
+
+	count, err := testutil.GatherAndCount(registry)
+	require.NoError(t, err)
+	assert.Equal(t, 12, count)
+}
+
+func TestCollector_WithoutCache(t *testing.T) {
+	collector := NewCollector(nil)
+	assert.NoError(t, prometheus.NewRegistry().Register(collector))
+	assert.Nil(t, collector.cacheHits)
+}